@@ -34,7 +34,9 @@ func main() {
 
 	logger.Info().Msg("Stopping bot...")
 
-	app.Shutdown()
+	if err := app.Shutdown(); err != nil {
+		logger.Error().Msgf("Bot stopping error: %s", err.Error())
+	}
 
 	logger.Info().Msg("Bot was stopped.")
 }
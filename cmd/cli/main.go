@@ -13,6 +13,8 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/urfave/cli/v2"
 
+	"bybarcode/internal/auth"
+	"bybarcode/internal/barcode"
 	"bybarcode/internal/config"
 	"bybarcode/internal/db"
 	"bybarcode/internal/products"
@@ -48,6 +50,23 @@ func main() {
 				},
 				Action: loadCommand,
 			},
+			{
+				Name:  "role",
+				Usage: "Set account role (user, moderator or admin).",
+				Flags: []cli.Flag{
+					&cli.Int64Flag{
+						Name:     "account",
+						Required: true,
+						Usage:    "Telegram id of the account",
+					},
+					&cli.StringFlag{
+						Name:     "role",
+						Required: true,
+						Usage:    "New role of the account",
+					},
+				},
+				Action: roleCommand,
+			},
 		},
 	}
 
@@ -139,11 +158,45 @@ func loadCommand(c *cli.Context) error {
 	}
 }
 
+func roleCommand(c *cli.Context) error {
+	role := c.String("role")
+	switch role {
+	case auth.RoleUser, auth.RoleModerator, auth.RoleAdmin:
+	default:
+		return fmt.Errorf("unknown role %s", role)
+	}
+
+	cfg := config.NewCliConfig()
+
+	conn, err := db.NewConnect("pgx", cfg.DBDsn)
+	if err != nil {
+		return err
+	}
+	defer func(conn *db.Connect) {
+		err = conn.Close()
+	}(&conn)
+
+	if err = conn.UpdateAccountRole(c.Context, c.Int64("account"), role); err != nil {
+		return err
+	}
+
+	fmt.Printf("Account %d now has role %s\n", c.Int64("account"), role)
+
+	return nil
+}
+
 func handleLine(
 	ctx context.Context,
 	conn *db.Connect,
 	line []string,
 ) error {
+	// lines with invalid barcodes are skipped, the rest of the file is still loaded
+	code, err := barcode.ParseGTIN(line[1])
+	if err != nil {
+		fmt.Printf("Skipped product %s: %s\n", line[1], err)
+		return nil
+	}
+
 	brandName := line[6]
 	if brandName == "" {
 		brandName = "unknown brand"
@@ -169,14 +222,9 @@ func handleLine(
 		return err
 	}
 
-	barcode, err := strconv.ParseInt(line[1], 10, 64)
-	if err != nil {
-		return err
-	}
-
 	p := products.Product{
 		ID:         id,
-		Upcean:     barcode,
+		Upcean:     code,
 		Name:       line[2],
 		CategoryId: int64(categoryId),
 		BrandId:    int64(brandId),
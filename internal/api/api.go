@@ -1,12 +1,17 @@
 package api
 
 import (
+	"bybarcode/internal/auth"
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/bot"
 	"bybarcode/internal/listener"
+	"bybarcode/internal/notifier"
 	"context"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog"
 	"net/http"
+	"time"
 
 	"bybarcode/internal/config"
 	"bybarcode/internal/db"
@@ -17,6 +22,7 @@ type AppApi struct {
 	cfg      *config.ApiConfig
 	logger   zerolog.Logger
 	listener *listener.EventListener
+	notifier *notifier.Notifier
 	router   *chi.Mux
 	server   *http.Server
 }
@@ -25,41 +31,84 @@ type handlers struct {
 	db       db.Connect
 	logger   zerolog.Logger
 	listener *listener.EventListener
+	notifier *notifier.Notifier
+	sender   *bot.Sender
 	response response
+	// botUsername is used to build invitation links to shopping lists.
+	botUsername string
+	botToken    string
+	// webAppAuthMaxAge limits how old web app init data can be.
+	webAppAuthMaxAge time.Duration
+	tokens           auth.TokenHasher
+	layouts          barcode.Layouts
+	// restoreWindow is how long a deleted list can be restored.
+	restoreWindow time.Duration
 }
 
 type middlewares struct {
-	db     db.Connect
-	logger zerolog.Logger
+	db       db.Connect
+	logger   zerolog.Logger
+	tokens   auth.TokenHasher
+	response response
 }
 
 func NewAppApi(cfg *config.ApiConfig, logger zerolog.Logger) *AppApi {
+	if cfg.TokenHashKey == "" {
+		logger.Fatal().Msg("API_TOKEN_HASH_KEY is not set")
+	}
+	tokens := auth.NewTokenHasher(cfg.TokenHashKey)
+
 	conn, err := db.NewConnect("pgx", cfg.DBDsn)
 	if err != nil {
 		logger.Fatal().Msg(err.Error())
 	}
 
+	layouts, err := barcode.LoadLayoutsOrDefault(cfg.BarcodeLayoutsFile)
+	if err != nil {
+		logger.Fatal().Msg(err.Error())
+	}
+
 	l := listener.NewEventListener(&conn)
 
-	r := chi.NewRouter()
+	sender, err := bot.NewSender(cfg.BotToken, cfg.TgApiUrl)
+	if err != nil {
+		logger.Fatal().Msg(err.Error())
+	}
+
+	n := notifier.NewNotifier(&conn, sender, logger, cfg.NotifyDebounce, cfg.NotifyMaxDelay)
+
 	h := handlers{
 		db:       conn,
 		logger:   logger,
 		listener: l,
+		notifier: n,
+		sender:   sender,
 		response: response{
 			logger: logger,
 		},
+		botUsername:      cfg.BotUsername,
+		botToken:         cfg.BotToken,
+		webAppAuthMaxAge: cfg.WebAppAuthMaxAge,
+		tokens:           tokens,
+		layouts:          layouts,
+		restoreWindow:    cfg.ListRestoreWindow,
 	}
 	m := middlewares{
 		db:     conn,
 		logger: logger,
+		tokens: tokens,
+		response: response{
+			logger: logger,
+		},
 	}
 
+	r := newRouter(&h, &m)
 	api := &AppApi{
 		db:       &conn,
 		cfg:      cfg,
 		logger:   logger,
 		listener: l,
+		notifier: n,
 		router:   r,
 		server: &http.Server{
 			Addr:    cfg.Address,
@@ -67,37 +116,106 @@ func NewAppApi(cfg *config.ApiConfig, logger zerolog.Logger) *AppApi {
 		},
 	}
 
-	api.router.Use(middleware.RequestID)
-	api.router.Use(middleware.RealIP)
-	api.router.Use(middleware.Logger)
-	api.router.Use(middleware.Recoverer)
-	api.router.Use(m.authMiddleware)
+	return api
+}
+
+// newRouter routes the endpoints of the api to the handlers.
+func newRouter(h *handlers, m *middlewares) *chi.Mux {
+	r := chi.NewRouter()
 
-	api.router.Get("/api/v1/ping", h.pingHandler)
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
 
-	api.router.Route("/api/v1/product", func(r chi.Router) {
-		r.Get("/{barcode}", h.findProductByBarcode)
-		r.Post("/", h.addProduct)
-		r.Put("/", h.updateProduct)
-		r.Delete("/{id}", h.deleteProduct)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		h.response.error(w, http.StatusNotFound, "endpoint not found")
 	})
-
-	api.router.Route("/api/v1/shopping-list", func(r chi.Router) {
-		r.Get("/{account_id}", h.getShoppingListsByAccount)
-		r.Post("/", h.addShoppingList)
-		r.Put("/", h.updateShoppingList)
-		r.Delete("/{id}", h.deleteShoppingList)
-		r.Get("/{id}/product", h.getShoppingListProducts)
-		r.Post("/{sl_id}/product/{barcode_or_id}", h.addProductToShoppingList)
-		r.Delete("/{sl_id}/product/{barcode_or_id}", h.deleteProductFromShoppingList)
-		r.Post("/{sl_id}/product/{product_id}/check", h.toggleProductStateInShoppingList)
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		h.response.error(w, http.StatusMethodNotAllowed, "method not allowed")
 	})
 
-	api.router.Route("/api/v1/statistic", func(r chi.Router) {
-		r.Get("/{date_from}/{date_to}", h.getStatistic)
+	// Sessions are issued and refreshed without the access token.
+	r.Post("/api/v1/auth/webapp", h.webAppAuth)
+	r.Post("/api/v1/auth/refresh", h.refreshSession)
+
+	r.Group(func(r chi.Router) {
+		r.Use(m.authMiddleware)
+
+		r.Get("/api/v1/ping", h.pingHandler)
+
+		r.Route("/api/v1/product", func(r chi.Router) {
+			r.Use(m.scopeMiddleware(auth.ScopeCatalogRead, ""))
+			r.Get("/{barcode}", h.findProductByBarcode)
+			r.Group(func(r chi.Router) {
+				r.Use(m.moderatorMiddleware)
+				r.Post("/", h.addProduct)
+				r.Put("/", h.updateProduct)
+				r.Delete("/{id}", h.deleteProduct)
+			})
+		})
+
+		r.Route("/api/v1/shopping-list", func(r chi.Router) {
+			r.Use(m.scopeMiddleware(auth.ScopeListsRead, auth.ScopeListsWrite))
+			r.Get("/", h.getOwnShoppingLists)
+			r.Get("/{account_id}", h.getShoppingListsByAccount)
+			r.Post("/", h.addShoppingList)
+			r.Put("/", h.updateShoppingList)
+			r.Delete("/{id}", h.deleteShoppingList)
+			r.Post("/{id}/restore", h.restoreShoppingList)
+			r.Post("/{id}/archive", h.archiveShoppingList)
+			r.Delete("/{id}/archive", h.unarchiveShoppingList)
+			r.Post("/{id}/duplicate", h.duplicateShoppingList)
+			r.Post("/{id}/merge/{source_id}", h.mergeShoppingLists)
+			r.Post("/{id}/split", h.splitShoppingList)
+			r.Get("/{id}/product", h.getShoppingListProducts)
+			r.Post("/{sl_id}/product/{barcode_or_id}", h.addProductToShoppingList)
+			r.Delete("/{sl_id}/product/{barcode_or_id}", h.deleteProductFromShoppingList)
+			r.Patch("/{sl_id}/product/{product_id}", h.updateShoppingListItem)
+			r.Post("/{sl_id}/product/{product_id}/check", h.toggleProductStateInShoppingList)
+			r.Post("/{sl_id}/item", h.addTextItemToShoppingList)
+			r.Delete("/{sl_id}/item/{item_id}", h.deleteShoppingListItem)
+			r.Patch("/{sl_id}/item/{item_id}", h.updateShoppingListItemById)
+			r.Post("/{sl_id}/item/{item_id}/check", h.toggleProductStateInShoppingList)
+			r.Post("/{id}/mute", h.muteShoppingList)
+			r.Delete("/{id}/mute", h.unmuteShoppingList)
+			r.Get("/{id}/member", h.getShoppingListMembers)
+			r.Post("/{id}/invite", h.createShoppingListInvite)
+			r.Delete("/{id}/member/{account_id}", h.removeShoppingListMember)
+			r.Post("/{id}/owner/{account_id}", h.transferShoppingListOwnership)
+		})
+
+		r.Route("/api/v1/submission", func(r chi.Router) {
+			r.Use(m.sessionOnlyMiddleware)
+			r.Post("/", h.addSubmission)
+			r.Group(func(r chi.Router) {
+				r.Use(m.moderatorMiddleware)
+				r.Get("/", h.getPendingSubmissions)
+				r.Put("/", h.updateSubmission)
+				r.Post("/{id}/approve", h.approveSubmission)
+				r.Post("/{id}/reject", h.rejectSubmission)
+			})
+		})
+
+		r.Route("/api/v1/statistic", func(r chi.Router) {
+			r.Use(m.sessionOnlyMiddleware)
+			r.Use(m.moderatorMiddleware)
+			r.Get("/{date_from}/{date_to}", h.getStatistic)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(m.sessionOnlyMiddleware)
+			r.Post("/api/v1/auth/logout", h.logout)
+			r.Get("/api/v1/auth/sessions", h.getSessions)
+			r.Delete("/api/v1/auth/sessions", h.revokeOtherSessions)
+			r.Delete("/api/v1/auth/sessions/{id}", h.revokeSession)
+			r.Get("/api/v1/auth/keys", h.getApiKeys)
+			r.Post("/api/v1/auth/keys", h.createApiKey)
+			r.Delete("/api/v1/auth/keys/{id}", h.revokeApiKey)
+		})
 	})
 
-	return api
+	return r
 }
 
 func (aa *AppApi) Run(ctx context.Context) error {
@@ -107,6 +225,13 @@ func (aa *AppApi) Run(ctx context.Context) error {
 			aa.logger.Fatal().Msg(err.Error())
 		}
 	}()
+	go func() {
+		if err := aa.notifier.Run(ctx); err != nil {
+			aa.logger.Error().Msg(err.Error())
+		}
+	}()
+	go aa.purgeSessions(ctx)
+	go aa.purgeDeletedLists(ctx)
 
 	aa.logger.Info().Msgf("Api was started on host %s \n", aa.cfg.Address)
 	err = aa.server.ListenAndServe()
@@ -114,6 +239,62 @@ func (aa *AppApi) Run(ctx context.Context) error {
 	return err
 }
 
+// purgeSessions deletes expired sessions every SessionPurgeInterval until ctx
+// is done. A zero interval disables purging.
+func (aa *AppApi) purgeSessions(ctx context.Context) {
+	if aa.cfg.SessionPurgeInterval <= 0 {
+		aa.logger.Info().Msg("Expired sessions purging is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(aa.cfg.SessionPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := aa.db.PurgeExpiredSessions(ctx)
+			if err != nil {
+				aa.logger.Error().Msg(err.Error())
+				continue
+			}
+			if purged > 0 {
+				aa.logger.Info().Msgf("%d expired sessions were purged", purged)
+			}
+		}
+	}
+}
+
+// purgeDeletedLists deletes the lists which can't be restored anymore every
+// ListPurgeInterval until ctx is done. A zero interval disables purging.
+func (aa *AppApi) purgeDeletedLists(ctx context.Context) {
+	if aa.cfg.ListPurgeInterval <= 0 {
+		aa.logger.Info().Msg("Deleted shopping lists purging is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(aa.cfg.ListPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := aa.db.PurgeDeletedShoppingLists(ctx, time.Now().Add(-aa.cfg.ListRestoreWindow))
+			if err != nil {
+				aa.logger.Error().Msg(err.Error())
+				continue
+			}
+			if purged > 0 {
+				aa.logger.Info().Msgf("%d deleted shopping lists were purged", purged)
+			}
+		}
+	}
+}
+
 func (aa *AppApi) ShoutDown(ctx context.Context) error {
 	if err := aa.db.Close(); err != nil {
 		return err
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"bybarcode/internal/auth"
+)
+
+func (h *handlers) getApiKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.db.GetApiKeysByAccount(r.Context(), sessionFromContext(r.Context()).AccountID)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	b, err := json.Marshal(keys)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+// createApiKey returns the new key with the key itself. It is the only time
+// the key is shown, only its hash is stored.
+func (h *handlers) createApiKey(w http.ResponseWriter, r *http.Request) {
+	var req auth.APIKeyRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	scopes, _ := auth.NormalizeScopes(req.Scopes)
+
+	key, err := h.tokens.IssueAPIKey(sessionFromContext(r.Context()).AccountID, name, scopes, req.ExpireAt)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	key, err = h.db.CreateApiKey(r.Context(), key)
+	if err != nil {
+		h.response.dbError(w, err, "account not found")
+		return
+	}
+
+	b, err := key.Encode()
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) revokeApiKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	err := h.db.RevokeApiKey(r.Context(), sessionFromContext(r.Context()).AccountID, id)
+	if err != nil {
+		h.response.dbError(w, err, "api key not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"bybarcode/internal/auth"
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/db/dbtest"
+	"bybarcode/internal/listener"
+	"bybarcode/internal/notifier"
+)
+
+const (
+	testAccountId = 42
+	testListId    = 3
+	testToken     = "session-token"
+	testHashKey   = "test-hash-key"
+)
+
+// Queries of db.Connect, matched by a part of their text.
+const (
+	querySessionByToken   = "WHERE token_hash = $1"
+	querySessionByRefresh = "WHERE refresh_token_hash = $1"
+	queryRotateSession    = "SET rotated_at = now()"
+	queryRevokeFamily     = "WHERE account_id = $1 AND family_id = $2 AND revoked_at IS NULL"
+	queryInsertSession    = "INSERT INTO sessions"
+	queryAccount          = "FROM account where id = $1"
+	queryApiKeyByHash     = "WHERE key_hash = $1"
+	queryListForAccount   = "WHERE sl.id = $1 AND m.account_id = $2 AND sl.deleted_at IS NULL"
+	queryAddTextItem      = "VALUES ($1, $2, NULLIF($3, 0)"
+	queryDeleteList       = "SET deleted_at = now()"
+	queryArchiveList      = "SET archived_at = COALESCE(archived_at, now())"
+	queryUnarchiveList    = "SET archived_at = NULL"
+	queryDeleteMember     = "DELETE FROM shopping_list_members"
+)
+
+type testApi struct {
+	db      *dbtest.DB
+	handler http.Handler
+	tokens  auth.TokenHasher
+}
+
+// newTestApi routes requests to the handlers backed by the fake database. The
+// statistic listener and the notifier run until the test ends.
+func newTestApi(t *testing.T) testApi {
+	t.Helper()
+
+	fdb, conn := dbtest.New(t)
+	tokens := auth.NewTokenHasher(testHashKey)
+	logger := zerolog.Nop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	l := listener.NewEventListener(&conn)
+	go func() { _ = l.Listen(ctx) }()
+
+	n := notifier.NewNotifier(&conn, nopSender{}, logger, time.Hour, time.Hour)
+	go func() { _ = n.Run(ctx) }()
+
+	h := handlers{
+		db:               conn,
+		logger:           logger,
+		listener:         l,
+		notifier:         n,
+		response:         response{logger: logger},
+		webAppAuthMaxAge: time.Hour,
+		tokens:           tokens,
+		layouts:          barcode.DefaultLayouts,
+		restoreWindow:    time.Hour,
+	}
+	m := middlewares{
+		db:       conn,
+		logger:   logger,
+		tokens:   tokens,
+		response: response{logger: logger},
+	}
+
+	return testApi{db: fdb, handler: newRouter(&h, &m), tokens: tokens}
+}
+
+type nopSender struct{}
+
+func (nopSender) SendMessage(int64, string) error {
+	return nil
+}
+
+// signIn makes requests with testToken authorized as the account with the
+// role.
+func (a testApi) signIn(role string) {
+	a.db.On(querySessionByToken, sessionRow(uuid.New(), time.Now().Add(time.Hour)))
+	a.db.On(queryAccount, []driver.Value{int64(testAccountId), "Test", "", "test", role})
+}
+
+// withApiKey makes requests with the key authorized with the scopes.
+func (a testApi) withApiKey(key string, scopes ...string) {
+	a.db.On(queryApiKeyByHash, []driver.Value{
+		int64(1), int64(testAccountId), "script", a.tokens.Hash(key), auth.JoinScopes(scopes), nil, nil, time.Now(),
+	})
+	a.db.On(queryAccount, []driver.Value{int64(testAccountId), "Test", "", "test", auth.RoleUser})
+}
+
+// memberOf makes the session account a member of testListId with the role.
+func (a testApi) memberOf(role string) {
+	a.db.On(queryListForAccount, []driver.Value{int64(testListId), "Продукты", int64(testAccountId), role, nil, nil})
+}
+
+func (a testApi) do(t *testing.T, method string, target string, token string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var b io.Reader
+	if body != "" {
+		b = strings.NewReader(body)
+	}
+
+	r := httptest.NewRequest(method, target, b)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	a.handler.ServeHTTP(w, r)
+
+	return w
+}
+
+// sessionRow is a row of the sessions table.
+func sessionRow(familyId uuid.UUID, refreshExpireAt time.Time) []driver.Value {
+	now := time.Now()
+
+	return []driver.Value{
+		uuid.NewString(), "token-hash", "refresh-token-hash", int64(testAccountId), familyId.String(),
+		now.Add(time.Hour), refreshExpireAt, now, now,
+	}
+}
+
+// refreshRow is sessionRow with whether the session was rotated and revoked.
+func refreshRow(familyId uuid.UUID, rotated bool, revoked bool, refreshExpireAt time.Time) []driver.Value {
+	return append(sessionRow(familyId, refreshExpireAt), rotated, revoked)
+}
+
+// assertError checks the status and the error envelope of a response.
+func assertError(t *testing.T, w *httptest.ResponseRecorder, status int, code string, message string) errorBody {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, status, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var env errorEnvelope
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatalf("body %s is not an error envelope: %v", w.Body, err)
+	}
+	if env.Error.Code != code {
+		t.Errorf("code = %q, want %q", env.Error.Code, code)
+	}
+	if message != "" && env.Error.Message != message {
+		t.Errorf("message = %q, want %q", env.Error.Message, message)
+	}
+
+	return env.Error
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"bybarcode/internal/auth"
+	"bybarcode/internal/db"
+)
+
+// webAppAuth issues a session for the Telegram user who opened the web app.
+// The user is proven by the init data Telegram signs with the bot token.
+func (h *handlers) webAppAuth(w http.ResponseWriter, r *http.Request) {
+	var req auth.WebAppAuthRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	user, err := auth.ValidateWebAppInitData(req.InitData, h.botToken, h.webAppAuthMaxAge, time.Now())
+	if errors.Is(err, auth.ErrInitDataInvalid) || errors.Is(err, auth.ErrInitDataExpired) {
+		h.response.error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	err = h.db.CreateAccountIfNotExist(r.Context(), int(user.ID), user.Username, user.FirstName, user.LastName)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	session, err := h.tokens.IssueSession(user.ID)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	session, err = h.db.CreateSession(r.Context(), session)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	b, err := session.Encode()
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+// refreshSession exchanges a refresh token for a new pair of tokens. It works
+// without the access token, which is usually expired by then.
+func (h *handlers) refreshSession(w http.ResponseWriter, r *http.Request) {
+	var req auth.RefreshRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	next, err := h.tokens.IssueSession(0)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	session, err := h.db.RefreshSession(r.Context(), h.tokens.Hash(req.RefreshToken), next)
+	if errors.Is(err, db.ErrRefreshTokenReused) {
+		h.logger.Warn().Msg(err.Error())
+		h.response.error(w, http.StatusUnauthorized, "refresh token was already used, the session is revoked")
+		return
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		h.response.error(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	b, err := session.Encode()
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) logout(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+
+	err := h.db.RevokeSessionFamily(r.Context(), session.AccountID, session.FamilyID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) getSessions(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+
+	sessions, err := h.db.GetActiveSessions(r.Context(), session.AccountID)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == session.FamilyID
+	}
+
+	b, err := json.Marshal(sessions)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) revokeSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.uuidParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	err := h.db.RevokeSessionFamily(r.Context(), sessionFromContext(r.Context()).AccountID, id)
+	if err != nil {
+		h.response.dbError(w, err, "session not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeOtherSessions logs out every device except the one making the request.
+func (h *handlers) revokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+
+	err := h.db.RevokeOtherSessionFamilies(r.Context(), session.AccountID, session.FamilyID)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
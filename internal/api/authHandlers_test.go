@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"bybarcode/internal/auth"
+)
+
+const testRefreshToken = "refresh-token"
+
+var refreshBody = `{"refresh_token":"` + testRefreshToken + `"}`
+
+func TestRefreshSessionRotates(t *testing.T) {
+	a := newTestApi(t)
+	family := uuid.New()
+	a.db.On(querySessionByRefresh, refreshRow(family, false, false, time.Now().Add(time.Hour)))
+
+	w := a.do(t, http.MethodPost, "/api/v1/auth/refresh", "", refreshBody)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var session auth.Session
+	if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil {
+		t.Fatal(err)
+	}
+	if session.Token == "" || session.RefreshToken == "" {
+		t.Fatalf("session %s has no tokens", w.Body)
+	}
+	if session.RefreshToken == testRefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	lookups := a.db.Executed(querySessionByRefresh)
+	if len(lookups) != 1 || lookups[0][0] != a.tokens.Hash(testRefreshToken) {
+		t.Fatalf("sessions looked up by %v, want the hash of the refresh token", lookups)
+	}
+	if rotated := a.db.Executed(queryRotateSession); len(rotated) != 1 {
+		t.Fatalf("rotated %d sessions, want 1", len(rotated))
+	}
+
+	inserted := a.db.Executed(queryInsertSession)
+	if len(inserted) != 1 {
+		t.Fatalf("inserted %d sessions, want 1", len(inserted))
+	}
+	next := inserted[0]
+	if next[1] != a.tokens.Hash(session.Token) || next[2] != a.tokens.Hash(session.RefreshToken) {
+		t.Error("new session is not stored by the hashes of its tokens")
+	}
+	if next[3] != int64(testAccountId) {
+		t.Errorf("new session account = %v, want %d", next[3], testAccountId)
+	}
+	if next[4] != family.String() {
+		t.Errorf("new session family = %v, want %s", next[4], family)
+	}
+}
+
+func TestRefreshSessionReuse(t *testing.T) {
+	a := newTestApi(t)
+	family := uuid.New()
+	a.db.On(querySessionByRefresh, refreshRow(family, true, true, time.Now().Add(time.Hour)))
+
+	w := a.do(t, http.MethodPost, "/api/v1/auth/refresh", "", refreshBody)
+	assertError(t, w, http.StatusUnauthorized, codeUnauthorized, "refresh token was already used, the session is revoked")
+
+	revoked := a.db.Executed(queryRevokeFamily)
+	if len(revoked) != 1 {
+		t.Fatalf("revoked %d families, want 1", len(revoked))
+	}
+	if revoked[0][0] != int64(testAccountId) || revoked[0][1] != family.String() {
+		t.Errorf("revoked family %v, want %s of account %d", revoked[0], family, testAccountId)
+	}
+	if inserted := a.db.Executed(queryInsertSession); len(inserted) != 0 {
+		t.Fatal("a session was issued for a reused refresh token")
+	}
+}
+
+func TestRefreshSessionInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		row  bool
+		// revoked and expired describe the found session
+		revoked bool
+		expired bool
+	}{
+		{name: "unknown"},
+		{name: "revoked", row: true, revoked: true},
+		{name: "expired", row: true, expired: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApi(t)
+			if tt.row {
+				refreshExpireAt := time.Now().Add(time.Hour)
+				if tt.expired {
+					refreshExpireAt = time.Now().Add(-time.Hour)
+				}
+				a.db.On(querySessionByRefresh, refreshRow(uuid.New(), false, tt.revoked, refreshExpireAt))
+			}
+
+			w := a.do(t, http.MethodPost, "/api/v1/auth/refresh", "", refreshBody)
+			assertError(t, w, http.StatusUnauthorized, codeUnauthorized, "invalid refresh token")
+
+			if inserted := a.db.Executed(queryInsertSession); len(inserted) != 0 {
+				t.Fatal("a session was issued for an invalid refresh token")
+			}
+			if revoked := a.db.Executed(queryRevokeFamily); len(revoked) != 0 {
+				t.Fatal("an invalid refresh token revoked the session")
+			}
+		})
+	}
+}
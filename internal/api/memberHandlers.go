@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"bybarcode/internal/message"
+	"bybarcode/internal/products"
+)
+
+func (h *handlers) getOwnShoppingLists(w http.ResponseWriter, r *http.Request) {
+	include, ok := h.listInclude(w, r)
+	if !ok {
+		return
+	}
+
+	lists, err := h.db.GetAllShoppingListsByAccount(r.Context(), sessionFromContext(r.Context()).AccountID, include)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	b, err := json.Marshal(lists)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) getShoppingListMembers(w http.ResponseWriter, r *http.Request) {
+	sl, ok := h.memberShoppingList(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.db.GetShoppingListMembers(r.Context(), sl.ID)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) createShoppingListInvite(w http.ResponseWriter, r *http.Request) {
+	sl, ok := h.memberShoppingList(w, r)
+	if !ok {
+		return
+	}
+
+	if !sl.IsOwner() {
+		h.response.error(w, http.StatusForbidden, "only the owner can invite members")
+		return
+	}
+
+	var req products.ListInvite
+	// the body is optional, without it the invitee becomes an editor
+	if err := req.Decode(r.Body); err != nil && !errors.Is(err, io.EOF) {
+		h.decodeError(w, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.validationError(w, err)
+		return
+	}
+
+	role := defaultIfEmpty(req.Role, products.ListRoleEditor)
+
+	invite, err := products.NewListInvite(sl.ID, role, sessionFromContext(r.Context()).AccountID)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if err = h.db.CreateListInvite(r.Context(), invite); err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	invite.Link = invite.DeepLink(h.botUsername)
+
+	b, err := invite.Encode()
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+// removeShoppingListMember lets the owner remove any other member and members
+// leave the list themselves.
+func (h *handlers) removeShoppingListMember(w http.ResponseWriter, r *http.Request) {
+	sl, ok := h.memberShoppingList(w, r)
+	if !ok {
+		return
+	}
+
+	accountId, ok := h.intParam(w, r, "account_id")
+	if !ok {
+		return
+	}
+
+	callerId := sessionFromContext(r.Context()).AccountID
+	if accountId == callerId && sl.IsOwner() {
+		h.response.error(w, http.StatusConflict, "the owner has to transfer the list before leaving it")
+		return
+	}
+	if accountId != callerId && !sl.IsOwner() {
+		h.response.error(w, http.StatusForbidden, "only the owner can remove members")
+		return
+	}
+
+	err := h.db.RemoveShoppingListMember(r.Context(), sl.ID, accountId)
+	if err != nil {
+		h.response.dbError(w, err, "member not found")
+		return
+	}
+
+	if accountId != callerId {
+		if err := h.sender.SendMessage(accountId, message.RemovedFromListMessage(sl.Name)); err != nil {
+			h.logger.Error().Msg(err.Error())
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) transferShoppingListOwnership(w http.ResponseWriter, r *http.Request) {
+	sl, ok := h.memberShoppingList(w, r)
+	if !ok {
+		return
+	}
+
+	if !sl.IsOwner() {
+		h.response.error(w, http.StatusForbidden, "only the owner can transfer the list")
+		return
+	}
+
+	accountId, ok := h.intParam(w, r, "account_id")
+	if !ok {
+		return
+	}
+
+	callerId := sessionFromContext(r.Context()).AccountID
+	if accountId == callerId {
+		h.response.errorWithDetails(
+			w,
+			http.StatusBadRequest,
+			"invalid url parameter account_id",
+			map[string]string{"account_id": "must be another member of the list"},
+		)
+		return
+	}
+
+	err := h.db.TransferShoppingListOwnership(r.Context(), sl.ID, callerId, accountId)
+	if err != nil {
+		h.response.dbError(w, err, "member not found")
+		return
+	}
+
+	if err = h.sender.SendMessage(accountId, message.ListTransferredToYouMessage(sl.Name)); err != nil {
+		h.logger.Error().Msg(err.Error())
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// memberShoppingList loads the list from the id url param for any member.
+func (h *handlers) memberShoppingList(w http.ResponseWriter, r *http.Request) (products.ShoppingList, bool) {
+	slId, ok := h.intParam(w, r, "id")
+	if !ok {
+		return products.ShoppingList{}, false
+	}
+
+	return h.callerShoppingList(w, r, slId, anyMember)
+}
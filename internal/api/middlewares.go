@@ -1,32 +1,134 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"strings"
+
+	"bybarcode/internal/auth"
+)
+
+type contextKey string
+
+const (
+	sessionContextKey contextKey = "session"
+	accountContextKey contextKey = "account"
+	apiKeyContextKey  contextKey = "api_key"
 )
 
 func (m *middlewares) authMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			w.WriteHeader(http.StatusUnauthorized)
+			m.response.error(w, http.StatusUnauthorized, "bearer token is required")
 			return
 		}
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
-		_, err := m.db.FindNotExpiredSession(r.Context(), token)
+		ctx := r.Context()
+		var session auth.Session
+		if strings.HasPrefix(token, auth.APIKeyPrefix) {
+			key, err := m.db.FindNotExpiredApiKey(r.Context(), m.tokens.Hash(token))
+			if err != nil {
+				m.logger.Error().Msg(err.Error())
+				m.response.error(w, http.StatusUnauthorized, "invalid or expired token")
+				return
+			}
+
+			if err = m.db.TouchApiKey(r.Context(), key.ID); err != nil {
+				m.logger.Error().Msg(err.Error())
+			}
+
+			// Handlers take the account from the session, so requests with a key
+			// get a session without tokens.
+			session = auth.Session{AccountID: key.AccountID}
+			ctx = context.WithValue(ctx, apiKeyContextKey, key)
+		} else {
+			var err error
+			session, err = m.db.FindNotExpiredSession(r.Context(), m.tokens.Hash(token))
+			if err != nil {
+				m.logger.Error().Msg(err.Error())
+				m.response.error(w, http.StatusUnauthorized, "invalid or expired token")
+				return
+			}
+		}
+
+		acc, err := m.db.FindAccountById(r.Context(), session.AccountID)
 		if err != nil {
 			m.logger.Error().Msg(err.Error())
-			w.WriteHeader(http.StatusUnauthorized)
+			m.response.error(w, http.StatusUnauthorized, "invalid or expired token")
+			return
+		}
+
+		ctx = context.WithValue(ctx, sessionContextKey, session)
+		ctx = context.WithValue(ctx, accountContextKey, acc)
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// moderatorMiddleware allows the catalog and moderation endpoints only for
+// moderators and admins.
+func (m *middlewares) moderatorMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !accountFromContext(r.Context()).IsModerator() {
+			m.response.error(w, http.StatusForbidden, "moderator role is required")
 			return
 		}
 
 		h.ServeHTTP(w, r)
 	})
 }
+
+// scopeMiddleware limits what requests with an API key can do: reading needs
+// the read scope and anything else the write scope. An empty scope closes the
+// routes to API keys. Session tokens are not limited.
+func (m *middlewares) scopeMiddleware(read string, write string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := apiKeyFromContext(r.Context())
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			scope := write
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = read
+			}
+
+			if scope == "" || !key.HasScope(scope) {
+				m.response.error(w, http.StatusForbidden, "api key has no access to this endpoint")
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sessionOnlyMiddleware closes the routes to API keys, e.g. managing sessions
+// and keys themselves.
+func (m *middlewares) sessionOnlyMiddleware(h http.Handler) http.Handler {
+	return m.scopeMiddleware("", "")(h)
+}
+
+func sessionFromContext(ctx context.Context) auth.Session {
+	session, _ := ctx.Value(sessionContextKey).(auth.Session)
+	return session
+}
+
+// accountFromContext returns the account of the session the request was
+// authorized with.
+func accountFromContext(ctx context.Context) auth.Account {
+	acc, _ := ctx.Value(accountContextKey).(auth.Account)
+	return acc
+}
+
+// apiKeyFromContext returns the API key the request was authorized with, if
+// it wasn't a session token.
+func apiKeyFromContext(ctx context.Context) (auth.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(auth.APIKey)
+	return key, ok
+}
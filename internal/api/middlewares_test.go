@@ -0,0 +1,148 @@
+package api
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"testing"
+
+	"bybarcode/internal/auth"
+	"bybarcode/internal/products"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	t.Run("no token", func(t *testing.T) {
+		a := newTestApi(t)
+
+		w := a.do(t, http.MethodGet, "/api/v1/ping", "", "")
+		assertError(t, w, http.StatusUnauthorized, codeUnauthorized, "bearer token is required")
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		a := newTestApi(t)
+
+		w := a.do(t, http.MethodGet, "/api/v1/ping", testToken, "")
+		assertError(t, w, http.StatusUnauthorized, codeUnauthorized, "invalid or expired token")
+	})
+
+	t.Run("session token is looked up by its hash", func(t *testing.T) {
+		a := newTestApi(t)
+		a.signIn(auth.RoleUser)
+
+		if w := a.do(t, http.MethodGet, "/api/v1/ping", testToken, ""); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body)
+		}
+
+		lookups := a.db.Executed(querySessionByToken)
+		if len(lookups) != 1 || lookups[0][0] != a.tokens.Hash(testToken) {
+			t.Fatalf("sessions looked up by %v, want the hash of the token", lookups)
+		}
+		if lookups[0][0] == testToken {
+			t.Fatal("session was looked up by the plain token")
+		}
+	})
+
+	t.Run("api key is looked up by its hash", func(t *testing.T) {
+		a := newTestApi(t)
+		key := auth.APIKeyPrefix + "key"
+		a.withApiKey(key, auth.ScopeListsRead)
+
+		if w := a.do(t, http.MethodGet, "/api/v1/ping", key, ""); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body)
+		}
+
+		lookups := a.db.Executed(queryApiKeyByHash)
+		if len(lookups) != 1 || lookups[0][0] != a.tokens.Hash(key) {
+			t.Fatalf("api keys looked up by %v, want the hash of the key", lookups)
+		}
+		if sessions := a.db.Executed(querySessionByToken); len(sessions) != 0 {
+			t.Fatalf("api key was looked up as a session token: %v", sessions)
+		}
+	})
+
+	t.Run("unknown api key", func(t *testing.T) {
+		a := newTestApi(t)
+
+		w := a.do(t, http.MethodGet, "/api/v1/ping", auth.APIKeyPrefix+"key", "")
+		assertError(t, w, http.StatusUnauthorized, codeUnauthorized, "invalid or expired token")
+	})
+}
+
+func TestScopeMiddleware(t *testing.T) {
+	const key = auth.APIKeyPrefix + "key"
+	listItems := "/api/v1/shopping-list/3/product"
+	addItem := "/api/v1/shopping-list/3/item"
+	itemBody := `{"name":"хлеб"}`
+
+	tests := []struct {
+		name   string
+		scopes []string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{name: "read with read scope", scopes: []string{auth.ScopeListsRead}, method: http.MethodGet, target: listItems, status: http.StatusOK},
+		{name: "read without scopes", method: http.MethodGet, target: listItems, status: http.StatusForbidden},
+		{name: "read with write scope only", scopes: []string{auth.ScopeListsWrite}, method: http.MethodGet, target: listItems, status: http.StatusForbidden},
+		{name: "write with read scope", scopes: []string{auth.ScopeListsRead}, method: http.MethodPost, target: addItem, body: itemBody, status: http.StatusForbidden},
+		{name: "write with write scope", scopes: []string{auth.ScopeListsWrite}, method: http.MethodPost, target: addItem, body: itemBody, status: http.StatusOK},
+		{name: "catalog without catalog scope", scopes: []string{auth.ScopeListsRead}, method: http.MethodGet, target: "/api/v1/product/4006381333931", status: http.StatusForbidden},
+		{name: "catalog with catalog scope", scopes: []string{auth.ScopeCatalogRead}, method: http.MethodGet, target: "/api/v1/product/4006381333931", status: http.StatusNotFound},
+		{name: "catalog changes", scopes: []string{auth.ScopeCatalogRead, auth.ScopeListsWrite}, method: http.MethodDelete, target: "/api/v1/product/7", status: http.StatusForbidden},
+		{name: "sessions", scopes: []string{auth.ScopeCatalogRead, auth.ScopeListsRead, auth.ScopeListsWrite}, method: http.MethodGet, target: "/api/v1/auth/sessions", status: http.StatusForbidden},
+		{name: "api keys", scopes: []string{auth.ScopeCatalogRead, auth.ScopeListsRead, auth.ScopeListsWrite}, method: http.MethodPost, target: "/api/v1/auth/keys", body: `{"name":"x"}`, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApi(t)
+			a.withApiKey(key, tt.scopes...)
+			a.memberOf(products.ListRoleOwner)
+			a.db.On(queryAddTextItem, []driver.Value{int64(11)})
+
+			w := a.do(t, tt.method, tt.target, key, tt.body)
+			if tt.status == http.StatusForbidden {
+				assertError(t, w, http.StatusForbidden, codeForbidden, "api key has no access to this endpoint")
+				return
+			}
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestScopeMiddlewareIgnoresSessions(t *testing.T) {
+	a := newTestApi(t)
+	a.signIn(auth.RoleUser)
+
+	if w := a.do(t, http.MethodGet, "/api/v1/auth/sessions", testToken, ""); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+func TestModeratorMiddleware(t *testing.T) {
+	tests := []struct {
+		role   string
+		status int
+	}{
+		{role: auth.RoleUser, status: http.StatusForbidden},
+		// the product does not exist, so passing the middleware ends with 404
+		{role: auth.RoleModerator, status: http.StatusNotFound},
+		{role: auth.RoleAdmin, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			a := newTestApi(t)
+			a.signIn(tt.role)
+
+			w := a.do(t, http.MethodDelete, "/api/v1/product/7", testToken, "")
+			if tt.status == http.StatusForbidden {
+				assertError(t, w, http.StatusForbidden, codeForbidden, "moderator role is required")
+				return
+			}
+			assertError(t, w, tt.status, codeNotFound, "")
+		})
+	}
+}
@@ -1,40 +1,42 @@
 package api
 
 import (
-	"errors"
+	"encoding/json"
 	"net/http"
-	"strconv"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 
+	"bybarcode/internal/barcode"
 	"bybarcode/internal/products"
+	"bybarcode/internal/validation"
 )
 
+// measuredProduct is a product found by a variable measure code.
+type measuredProduct struct {
+	products.Product
+	Measure *barcode.Measured `json:"measure,omitempty"`
+}
+
 func (h *handlers) findProductByBarcode(w http.ResponseWriter, r *http.Request) {
-	barcode, err := strconv.ParseInt(chi.URLParam(r, "barcode"), 10, 64)
-	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+	code, ok := h.barcodeParam(w, r, "barcode")
+	if !ok {
 		return
 	}
 
-	product, err := h.db.FindProductByBarcode(r.Context(), barcode)
-	if errors.As(err, &pgx.ErrNoRows) {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusNotFound, []byte("product not found"))
-		return
+	var found measuredProduct
+	if m, ok := h.layouts.Match(code); ok {
+		code, found.Measure = m.Item, &m
 	}
+
+	product, err := h.db.FindProductByBarcode(r.Context(), code)
 	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.dbError(w, err, "product not found")
 		return
 	}
 
-	b, err := product.Encode()
+	found.Product = product
+	b, err := json.Marshal(found)
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
@@ -43,15 +45,13 @@ func (h *handlers) findProductByBarcode(w http.ResponseWriter, r *http.Request)
 
 func (h *handlers) addProduct(w http.ResponseWriter, r *http.Request) {
 	var p products.Product
-	if err := p.Decode(r.Body); err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusBadRequest, []byte("bad request"))
+	if !h.decodeRequest(w, r, &p) || !h.productReferencesExist(w, r, p) {
+		return
 	}
 
 	productId, err := h.db.CreateProduct(r.Context(), p)
 	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.dbError(w, err, "product not found")
 		return
 	}
 
@@ -59,7 +59,7 @@ func (h *handlers) addProduct(w http.ResponseWriter, r *http.Request) {
 	b, err := p.Encode()
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
@@ -68,27 +68,20 @@ func (h *handlers) addProduct(w http.ResponseWriter, r *http.Request) {
 
 func (h *handlers) updateProduct(w http.ResponseWriter, r *http.Request) {
 	var p products.Product
-	if err := p.Decode(r.Body); err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusBadRequest, []byte("bad request"))
+	if !h.decodeRequest(w, r, &p) || !h.validateId(w, p.ID) || !h.productReferencesExist(w, r, p) {
+		return
 	}
 
 	updP, err := h.db.UpdateProduct(r.Context(), p)
-	if errors.As(err, &pgx.ErrNoRows) {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusNotFound, []byte("product not found"))
-		return
-	}
 	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.dbError(w, err, "product not found")
 		return
 	}
 
 	b, err := updP.Encode()
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
@@ -96,24 +89,41 @@ func (h *handlers) updateProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handlers) deleteProduct(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
-	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+	id, ok := h.intParam(w, r, "id")
+	if !ok {
 		return
 	}
 
-	err = h.db.DeleteProduct(r.Context(), id)
-	if errors.As(err, &pgx.ErrNoRows) {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusNotFound, []byte("product not found"))
+	err := h.db.DeleteProduct(r.Context(), id)
+	if err != nil {
+		h.response.dbError(w, err, "product not found")
 		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// productReferencesExist answers with 422 when the category or the brand of
+// the product doesn't exist.
+func (h *handlers) productReferencesExist(w http.ResponseWriter, r *http.Request, p products.Product) bool {
+	categoryExists, brandExists, err := h.db.ProductReferencesExist(r.Context(), p.CategoryId, p.BrandId)
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
-		return
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return false
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	errs := validation.Errors{}
+	if !categoryExists {
+		errs["category_id"] = "does not exist"
+	}
+	if !brandExists {
+		errs["brand_id"] = "does not exist"
+	}
+	if len(errs) > 0 {
+		h.validationError(w, errs)
+		return false
+	}
+
+	return true
 }
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/validation"
+)
+
+// request is a body a handler accepts.
+type request interface {
+	Decode(r io.Reader) error
+	Validate() error
+}
+
+// decodeRequest decodes and validates the body into req. Malformed bodies are
+// answered with 400 and invalid values with 422, both with the problems of
+// every field in details.
+func (h *handlers) decodeRequest(w http.ResponseWriter, r *http.Request, req request) bool {
+	if err := req.Decode(r.Body); err != nil {
+		h.decodeError(w, err)
+		return false
+	}
+
+	if err := req.Validate(); err != nil {
+		h.validationError(w, err)
+		return false
+	}
+
+	return true
+}
+
+func (h *handlers) decodeError(w http.ResponseWriter, err error) {
+	var fields validation.Errors
+	switch {
+	case errors.As(err, &fields):
+		h.response.errorWithDetails(w, http.StatusBadRequest, "invalid request body", fields)
+	case errors.Is(err, io.EOF):
+		h.response.error(w, http.StatusBadRequest, "request body is required")
+	default:
+		h.response.error(w, http.StatusBadRequest, "request body is not valid json")
+	}
+}
+
+func (h *handlers) validationError(w http.ResponseWriter, err error) {
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		h.response.errorWithDetails(w, http.StatusUnprocessableEntity, "validation failed", fields)
+		return
+	}
+
+	h.logger.Error().Msg(err.Error())
+	h.response.error(w, http.StatusInternalServerError, "internal server error")
+}
+
+// validateId checks the id of the row an update body refers to.
+func (h *handlers) validateId(w http.ResponseWriter, id int64) bool {
+	if err := validation.Validate(validation.Positive("id", id)); err != nil {
+		h.validationError(w, err)
+		return false
+	}
+
+	return true
+}
+
+// intParam parses an integer url param. A malformed param is the client's
+// mistake, so it is answered with 400.
+func (h *handlers) intParam(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	value, err := strconv.ParseInt(chi.URLParam(r, name), 10, 64)
+	if err != nil {
+		h.response.errorWithDetails(
+			w,
+			http.StatusBadRequest,
+			fmt.Sprintf("invalid url parameter %s", name),
+			map[string]string{name: "must be an integer"},
+		)
+		return 0, false
+	}
+
+	return value, true
+}
+
+// barcodeParam parses a barcode url param in any format barcode.ParseGTIN
+// accepts.
+func (h *handlers) barcodeParam(w http.ResponseWriter, r *http.Request, name string) (barcode.GTIN, bool) {
+	code, err := barcode.ParseGTIN(chi.URLParam(r, name))
+	if err != nil {
+		h.response.errorWithDetails(
+			w,
+			http.StatusBadRequest,
+			fmt.Sprintf("invalid url parameter %s", name),
+			map[string]string{name: err.Error()},
+		)
+		return "", false
+	}
+
+	return code, true
+}
+
+func (h *handlers) uuidParam(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	value, err := uuid.Parse(chi.URLParam(r, name))
+	if err != nil {
+		h.response.errorWithDetails(
+			w,
+			http.StatusBadRequest,
+			fmt.Sprintf("invalid url parameter %s", name),
+			map[string]string{name: "must be a uuid"},
+		)
+		return uuid.UUID{}, false
+	}
+
+	return value, true
+}
@@ -1,11 +1,50 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/rs/zerolog"
+
+	"bybarcode/internal/db"
+)
+
+// Codes of the error envelope, one per status the api responds with.
+const (
+	codeBadRequest       = "bad_request"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeConflict         = "conflict"
+	codeValidation       = "validation_failed"
+	codeInternal         = "internal_error"
 )
 
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          codeBadRequest,
+	http.StatusUnauthorized:        codeUnauthorized,
+	http.StatusForbidden:           codeForbidden,
+	http.StatusNotFound:            codeNotFound,
+	http.StatusMethodNotAllowed:    codeMethodNotAllowed,
+	http.StatusConflict:            codeConflict,
+	http.StatusUnprocessableEntity: codeValidation,
+	http.StatusInternalServerError: codeInternal,
+}
+
+// errorEnvelope is the body of every error response:
+// {"error":{"code":"not_found","message":"product not found"}}.
+type errorEnvelope struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
 type response struct {
 	logger zerolog.Logger
 }
@@ -25,3 +64,42 @@ func (r response) json(w http.ResponseWriter, status int, body []byte) {
 
 	r.logger.Debug().Msgf("Send response with headers %s and body %s", w.Header(), string(body))
 }
+
+// error writes the error envelope with the code of the status.
+func (r response) error(w http.ResponseWriter, status int, message string) {
+	r.errorWithDetails(w, status, message, nil)
+}
+
+func (r response) errorWithDetails(w http.ResponseWriter, status int, message string, details map[string]string) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = codeInternal
+	}
+
+	b, err := json.Marshal(errorEnvelope{Error: errorBody{Code: code, Message: message, Details: details}})
+	if err != nil {
+		r.logger.Error().Msg(err.Error())
+		b = []byte(`{"error":{"code":"internal_error","message":"internal server error"}}`)
+		status = http.StatusInternalServerError
+	}
+
+	r.json(w, status, b)
+}
+
+// dbError writes the error of a db call. Missing rows are reported with the
+// notFound message, conflicts and rejected values with the message of the db
+// error, anything else is logged and hidden behind an internal server error.
+func (r response) dbError(w http.ResponseWriter, err error, notFound string) {
+	var dbErr *db.Error
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		r.error(w, http.StatusNotFound, notFound)
+	case errors.As(err, &dbErr) && errors.Is(dbErr, db.ErrConflict):
+		r.errorWithDetails(w, http.StatusConflict, dbErr.Message, dbErr.Details)
+	case errors.As(err, &dbErr) && errors.Is(dbErr, db.ErrValidation):
+		r.errorWithDetails(w, http.StatusUnprocessableEntity, dbErr.Message, dbErr.Details)
+	default:
+		r.logger.Error().Msg(err.Error())
+		r.error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
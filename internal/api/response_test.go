@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+
+	"bybarcode/internal/auth"
+	"bybarcode/internal/db"
+	"bybarcode/internal/products"
+)
+
+func TestDbError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+		details map[string]string
+	}{
+		{name: "not found", err: db.ErrNotFound, status: http.StatusNotFound, code: codeNotFound, message: "list not found"},
+		{name: "wrapped not found", err: fmt.Errorf("find: %w", db.ErrNotFound), status: http.StatusNotFound, code: codeNotFound, message: "list not found"},
+		{
+			name:    "conflict",
+			err:     &db.Error{Kind: db.ErrConflict, Message: "a list with the same name already exists", Details: map[string]string{"constraint": "uniq_name"}},
+			status:  http.StatusConflict,
+			code:    codeConflict,
+			message: "a list with the same name already exists",
+			details: map[string]string{"constraint": "uniq_name"},
+		},
+		{
+			name:    "validation",
+			err:     &db.Error{Kind: db.ErrValidation, Message: "value too long", Details: map[string]string{"column": "name"}},
+			status:  http.StatusUnprocessableEntity,
+			code:    codeValidation,
+			message: "value too long",
+			details: map[string]string{"column": "name"},
+		},
+		{name: "other", err: errors.New("connection refused"), status: http.StatusInternalServerError, code: codeInternal, message: "internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			response{logger: zerolog.Nop()}.dbError(w, tt.err, "list not found")
+
+			body := assertError(t, w, tt.status, tt.code, tt.message)
+			if len(body.Details) != len(tt.details) {
+				t.Fatalf("details = %v, want %v", body.Details, tt.details)
+			}
+			for k, v := range tt.details {
+				if body.Details[k] != v {
+					t.Errorf("details[%s] = %q, want %q", k, body.Details[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		status  int
+		code    string
+		message string
+		// detail is a key expected in the details
+		detail string
+	}{
+		{name: "unknown endpoint", method: http.MethodGet, target: "/api/v1/nothing", status: http.StatusNotFound, code: codeNotFound, message: "endpoint not found"},
+		{name: "unknown method", method: http.MethodPatch, target: "/api/v1/ping", status: http.StatusMethodNotAllowed, code: codeMethodNotAllowed, message: "method not allowed"},
+		{name: "no body", method: http.MethodPost, target: "/api/v1/shopping-list/3/item", status: http.StatusBadRequest, code: codeBadRequest, message: "request body is required"},
+		{name: "malformed body", method: http.MethodPost, target: "/api/v1/shopping-list/3/item", body: "{", status: http.StatusBadRequest, code: codeBadRequest, message: "request body is not valid json"},
+		{name: "malformed url param", method: http.MethodDelete, target: "/api/v1/shopping-list/three", status: http.StatusBadRequest, code: codeBadRequest, message: "invalid url parameter id", detail: "id"},
+		{name: "invalid body", method: http.MethodPost, target: "/api/v1/shopping-list/3/item", body: `{"name":""}`, status: http.StatusUnprocessableEntity, code: codeValidation, message: "validation failed", detail: "name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApi(t)
+			a.signIn(auth.RoleUser)
+			a.memberOf(products.ListRoleOwner)
+
+			w := a.do(t, tt.method, tt.target, testToken, tt.body)
+			body := assertError(t, w, tt.status, tt.code, tt.message)
+			if _, ok := body.Details[tt.detail]; tt.detail != "" && !ok {
+				t.Errorf("details %v have no %s", body.Details, tt.detail)
+			}
+		})
+	}
+}
+
+func TestErrorEnvelopeHidesDatabaseErrors(t *testing.T) {
+	a := newTestApi(t)
+	a.signIn(auth.RoleUser)
+	a.memberOf(products.ListRoleOwner)
+	a.db.Fail(queryAddTextItem, &pgconn.PgError{Code: "08006", Message: "connection failure on 10.0.0.5"})
+
+	w := a.do(t, http.MethodPost, "/api/v1/shopping-list/3/item", testToken, `{"name":"хлеб"}`)
+	body := assertError(t, w, http.StatusInternalServerError, codeInternal, "internal server error")
+	if body.Details != nil {
+		t.Errorf("details = %v, want none", body.Details)
+	}
+}
+
+func TestErrorEnvelopeConflict(t *testing.T) {
+	a := newTestApi(t)
+	a.signIn(auth.RoleUser)
+	a.memberOf(products.ListRoleOwner)
+	a.db.Fail(queryAddTextItem, &pgconn.PgError{Code: "23505", ConstraintName: "shopping_list__products_name_key"})
+
+	w := a.do(t, http.MethodPost, "/api/v1/shopping-list/3/item", testToken, `{"name":"хлеб"}`)
+	body := assertError(t, w, http.StatusConflict, codeConflict, "")
+	if body.Details["constraint"] != "shopping_list__products_name_key" {
+		t.Errorf("details = %v, want the constraint", body.Details)
+	}
+}
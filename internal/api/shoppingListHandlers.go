@@ -1,45 +1,47 @@
 package api
 
 import (
-	"bybarcode/internal/db"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/db"
+	"bybarcode/internal/notifier"
 	"bybarcode/internal/products"
+	"bybarcode/internal/validation"
 )
 
 func (h *handlers) addShoppingList(w http.ResponseWriter, r *http.Request) {
 	var sl products.ShoppingList
-	if err := sl.Decode(r.Body); err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusBadRequest, []byte("bad request"))
+	if !h.decodeRequest(w, r, &sl) {
 		return
 	}
 
-	slId, err := h.db.CreateShoppingList(r.Context(), sl)
-	fmt.Println(err)
-	if errors.Is(err, db.ErrDuplicateKey) {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusBadRequest, []byte(err.Error()))
+	callerId := sessionFromContext(r.Context()).AccountID
+	if sl.AccountId != 0 && sl.AccountId != callerId {
+		h.response.error(w, http.StatusForbidden, "forbidden")
 		return
 	}
+	sl.AccountId = callerId
+
+	slId, err := h.db.CreateShoppingList(r.Context(), sl)
 	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.dbError(w, err, "account not found")
 		return
 	}
 
 	sl.ID = slId
+	sl.Role = products.ListRoleOwner
 	b, err := sl.Encode()
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
@@ -48,29 +50,26 @@ func (h *handlers) addShoppingList(w http.ResponseWriter, r *http.Request) {
 
 func (h *handlers) updateShoppingList(w http.ResponseWriter, r *http.Request) {
 	var sl products.ShoppingList
-	if err := sl.Decode(r.Body); err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusBadRequest, []byte("bad request"))
+	if !h.decodeRequest(w, r, &sl) || !h.validateId(w, sl.ID) {
 		return
 	}
 
-	updSl, err := h.db.UpdateShoppingList(r.Context(), sl)
-	fmt.Println(err)
-	if errors.As(err, &pgx.ErrNoRows) {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusNotFound, []byte("shopping list not found"))
+	current, ok := h.callerShoppingList(w, r, sl.ID, products.ShoppingList.IsOwner)
+	if !ok {
 		return
 	}
+	current.Name = sl.Name
+
+	updSl, err := h.db.UpdateShoppingList(r.Context(), current)
 	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.dbError(w, err, "shopping list not found")
 		return
 	}
 
 	b, err := updSl.Encode()
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
@@ -78,165 +77,705 @@ func (h *handlers) updateShoppingList(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handlers) getShoppingListsByAccount(w http.ResponseWriter, r *http.Request) {
-	accountId, err := strconv.ParseInt(chi.URLParam(r, "account_id"), 10, 64)
-	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+	accountId, ok := h.intParam(w, r, "account_id")
+	if !ok {
+		return
+	}
+
+	if accountId != sessionFromContext(r.Context()).AccountID {
+		h.response.error(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	include, ok := h.listInclude(w, r)
+	if !ok {
 		return
 	}
 
-	lists, err := h.db.GetShoppingListsByAccount(r.Context(), accountId)
+	lists, err := h.db.GetAllShoppingListsByAccount(r.Context(), accountId, include)
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(lists); err != nil {
+	b, err := json.Marshal(lists)
+	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	h.response.json(w, http.StatusOK, b)
 }
 
 func (h *handlers) deleteShoppingList(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	id, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, id, products.ShoppingList.IsOwner); !ok {
+		return
+	}
+
+	err := h.db.DeleteShoppingList(r.Context(), id)
 	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.dbError(w, err, "shopping list not found")
 		return
 	}
 
-	err = h.db.DeleteShoppingList(r.Context(), id)
-	if errors.As(err, &pgx.ErrNoRows) {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusNotFound, []byte("shopping list not found"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// duplicateShoppingList copies a list the session account is a member of to
+// a new list of the account.
+func (h *handlers) duplicateShoppingList(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req products.ListDuplicate
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, anyMember); !ok {
+		return
+	}
+
+	sl := products.ShoppingList{Name: req.Name, AccountId: sessionFromContext(r.Context()).AccountID}
+	listId, err := h.db.DuplicateShoppingList(r.Context(), slId, sl, req.UncheckedOnly)
+	if err != nil {
+		h.response.dbError(w, err, "shopping list not found")
+		return
+	}
+
+	h.listener.Notify(r.Context(), listId)
+	h.notifyCopiedItems(r, listId)
+	h.createdShoppingList(w, sl, listId)
+}
+
+// mergeShoppingLists adds the items of the source list to the list and
+// deletes the source list. The source has to be owned by the session account.
+func (h *handlers) mergeShoppingLists(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	sourceId, ok := h.intParam(w, r, "source_id")
+	if !ok {
+		return
+	}
+
+	if slId == sourceId {
+		h.validationError(w, validation.Errors{"source_id": "must be another list"})
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, products.ShoppingList.CanEdit); !ok {
 		return
 	}
+	if _, ok := h.callerShoppingList(w, r, sourceId, products.ShoppingList.IsOwner); !ok {
+		return
+	}
+
+	items, err := h.db.GetShoppingListProducts(r.Context(), sourceId)
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
+	if err := h.db.MergeShoppingLists(r.Context(), slId, sourceId); err != nil {
+		h.response.dbError(w, err, "shopping list not found")
+		return
+	}
+
+	h.listener.Notify(r.Context(), slId)
+	h.notifyItemsChange(r, slId, items, notifier.ActionAdded)
+	h.notifyItemsChange(r, sourceId, items, notifier.ActionRemoved)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *handlers) addProductToShoppingList(w http.ResponseWriter, r *http.Request) {
-	slId, err := strconv.ParseInt(chi.URLParam(r, "sl_id"), 10, 64)
+// splitShoppingList moves items of a list to a new list of the session
+// account.
+func (h *handlers) splitShoppingList(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req products.ListSplit
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, products.ShoppingList.CanEdit); !ok {
+		return
+	}
+
+	items, err := h.db.GetShoppingListProducts(r.Context(), slId)
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
-	pId, err := strconv.ParseInt(chi.URLParam(r, "barcode_or_id"), 10, 64)
+	sl := products.ShoppingList{Name: req.Name, AccountId: sessionFromContext(r.Context()).AccountID}
+	listId, err := h.db.SplitShoppingList(r.Context(), slId, sl, req.ItemIDs)
 	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.dbError(w, err, "items are not in the shopping list")
 		return
 	}
 
-	err = h.db.AddProductToShoppingListByIds(r.Context(), pId, slId)
-	if errors.As(err, &db.ErrDuplicateKey) {
+	moved := itemsByIds(items, req.ItemIDs)
+	h.listener.Notify(r.Context(), slId)
+	h.listener.Notify(r.Context(), listId)
+	h.notifyItemsChange(r, slId, moved, notifier.ActionRemoved)
+	h.notifyItemsChange(r, listId, moved, notifier.ActionAdded)
+	h.createdShoppingList(w, sl, listId)
+}
+
+// createdShoppingList responds with a list just created for the session
+// account.
+func (h *handlers) createdShoppingList(w http.ResponseWriter, sl products.ShoppingList, listId int64) {
+	sl.ID = listId
+	sl.Role = products.ListRoleOwner
+	b, err := sl.Encode()
+	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusBadRequest, []byte(err.Error()))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+// restoreShoppingList restores a list deleted within the restore window.
+func (h *handlers) restoreShoppingList(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	deletedAfter := time.Now().Add(-h.restoreWindow)
+	sl, err := h.db.FindDeletedShoppingListForAccount(r.Context(), id, sessionFromContext(r.Context()).AccountID, deletedAfter)
+	if err != nil {
+		h.response.dbError(w, err, "deleted shopping list not found")
+		return
+	}
+	if !sl.IsOwner() {
+		h.response.error(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	if err = h.db.RestoreShoppingList(r.Context(), id, deletedAfter); err != nil {
+		h.response.dbError(w, err, "deleted shopping list not found")
 		return
 	}
+
+	sl.DeletedAt = nil
+	b, err := sl.Encode()
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) archiveShoppingList(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, id, products.ShoppingList.IsOwner); !ok {
+		return
+	}
+
+	if err := h.db.ArchiveShoppingList(r.Context(), id); err != nil {
+		h.response.dbError(w, err, "shopping list not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) unarchiveShoppingList(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, id, products.ShoppingList.IsOwner); !ok {
+		return
+	}
+
+	if err := h.db.UnarchiveShoppingList(r.Context(), id); err != nil {
+		h.response.dbError(w, err, "shopping list not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) addProductToShoppingList(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "sl_id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, products.ShoppingList.CanEdit); !ok {
+		return
+	}
+
+	// the body is optional, without it one piece is added
+	item := products.ListItem{Quantity: products.OnePiece}
+	if err := item.Decode(r.Body); err != nil && !errors.Is(err, io.EOF) {
+		h.decodeError(w, err)
+		return
+	}
+	if err := item.Validate(); err != nil {
+		h.validationError(w, err)
+		return
+	}
+
+	pId, ok := h.listProductParam(w, r, "barcode_or_id", &item)
+	if !ok {
+		return
+	}
+
+	err := h.db.AddProductToShoppingListByIds(r.Context(), pId, slId, item)
+	if err != nil {
+		h.response.dbError(w, err, "product not found")
 		return
 	}
 
 	h.listener.Notify(r.Context(), slId)
+	h.notifyListChange(r, slId, pId, notifier.ActionAdded)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *handlers) deleteProductFromShoppingList(w http.ResponseWriter, r *http.Request) {
-	slId, err := strconv.ParseInt(chi.URLParam(r, "sl_id"), 10, 64)
-	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+	slId, ok := h.intParam(w, r, "sl_id")
+	if !ok {
 		return
 	}
 
-	pId, err := strconv.ParseInt(chi.URLParam(r, "barcode_or_id"), 10, 64)
-	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+	pId, ok := h.intParam(w, r, "barcode_or_id")
+	if !ok {
 		return
 	}
 
-	err = h.db.DeleteProductFromShoppingList(r.Context(), slId, pId)
+	if _, ok := h.callerShoppingList(w, r, slId, products.ShoppingList.CanEdit); !ok {
+		return
+	}
+
+	err := h.db.DeleteProductFromShoppingList(r.Context(), slId, pId)
 	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.dbError(w, err, "product is not in the shopping list")
 		return
 	}
 
 	h.listener.Notify(r.Context(), slId)
+	h.notifyListChange(r, slId, pId, notifier.ActionRemoved)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *handlers) getShoppingListProducts(w http.ResponseWriter, r *http.Request) {
-	slId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
-	if err != nil {
-		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+	slId, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, anyMember); !ok {
 		return
 	}
 
 	list, err := h.db.GetShoppingListProducts(r.Context(), slId)
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(list); err != nil {
+	b, err := json.Marshal(list)
+	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	h.response.json(w, http.StatusOK, b)
 }
 
+// toggleProductStateInShoppingList toggles a product, or any item by its id
+// when the route has item_id.
 func (h *handlers) toggleProductStateInShoppingList(w http.ResponseWriter, r *http.Request) {
-	slId, err := strconv.ParseInt(chi.URLParam(r, "sl_id"), 10, 64)
+	slId, ok := h.intParam(w, r, "sl_id")
+	if !ok {
+		return
+	}
+
+	byItem := chi.URLParam(r, "item_id") != ""
+	param := "product_id"
+	if byItem {
+		param = "item_id"
+	}
+
+	id, ok := h.intParam(w, r, param)
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, products.ShoppingList.CanEdit); !ok {
+		return
+	}
+
+	item := products.ProductInList{Product: products.Product{ID: id}}
+	var err error
+	if byItem {
+		item, err = h.db.ToggleShoppingListItemState(r.Context(), slId, id)
+	} else {
+		item.Checked, err = h.db.ToggleProductStateInShoppingList(r.Context(), slId, id)
+	}
+	if err != nil {
+		h.response.dbError(w, err, "product is not in the shopping list")
+		return
+	}
+
+	h.listener.Notify(r.Context(), slId)
+	if item.Checked {
+		h.notifyItemChange(r, slId, item, notifier.ActionChecked)
+	} else {
+		h.notifyItemChange(r, slId, item, notifier.ActionUnchecked)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) addTextItemToShoppingList(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "sl_id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, products.ShoppingList.CanEdit); !ok {
+		return
+	}
+
+	item := products.TextItem{ListItem: products.ListItem{Quantity: products.OnePiece}}
+	if !h.decodeRequest(w, r, &item) {
+		return
+	}
+
+	itemId, err := h.db.AddTextItemToShoppingList(r.Context(), slId, item)
+	if err != nil {
+		h.response.dbError(w, err, "shopping list not found")
+		return
+	}
+
+	added := products.ProductInList{
+		ItemID:   itemId,
+		Product:  products.Product{Name: item.Name, CategoryId: item.CategoryId},
+		Quantity: item.Quantity,
+		Note:     item.Note,
+	}
+
+	h.listener.Notify(r.Context(), slId)
+	h.notifyItemChange(r, slId, added, notifier.ActionAdded)
+
+	b, err := json.Marshal(&added)
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) deleteShoppingListItem(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "sl_id")
+	if !ok {
+		return
+	}
+
+	itemId, ok := h.intParam(w, r, "item_id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, products.ShoppingList.CanEdit); !ok {
 		return
 	}
 
-	pId, err := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	item, err := h.db.DeleteShoppingListItem(r.Context(), slId, itemId)
+	if err != nil {
+		h.response.dbError(w, err, "item is not in the shopping list")
+		return
+	}
+
+	h.listener.Notify(r.Context(), slId)
+	h.notifyItemChange(r, slId, item, notifier.ActionRemoved)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) updateShoppingListItemById(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "sl_id")
+	if !ok {
+		return
+	}
+
+	itemId, ok := h.intParam(w, r, "item_id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, products.ShoppingList.CanEdit); !ok {
+		return
+	}
+
+	var upd products.ListItemUpdate
+	if !h.decodeRequest(w, r, &upd) {
+		return
+	}
+
+	h.updateItem(w, r, slId, itemId, upd)
+}
+
+// updateShoppingListItem is updateShoppingListItemById for the item of a
+// product.
+func (h *handlers) updateShoppingListItem(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "sl_id")
+	if !ok {
+		return
+	}
+
+	pId, ok := h.intParam(w, r, "product_id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, products.ShoppingList.CanEdit); !ok {
+		return
+	}
+
+	var upd products.ListItemUpdate
+	if !h.decodeRequest(w, r, &upd) {
+		return
+	}
+
+	itemId, err := h.db.FindShoppingListItemId(r.Context(), slId, pId)
+	if err != nil {
+		h.response.dbError(w, err, "product is not in the shopping list")
+		return
+	}
+
+	h.updateItem(w, r, slId, itemId, upd)
+}
+
+func (h *handlers) updateItem(w http.ResponseWriter, r *http.Request, slId int64, itemId int64, upd products.ListItemUpdate) {
+	item, err := h.db.UpdateShoppingListItemById(r.Context(), slId, itemId, upd)
+	if err != nil {
+		h.response.dbError(w, err, "item is not in the shopping list")
+		return
+	}
+
+	h.listener.Notify(r.Context(), slId)
+	h.notifyItemChange(r, slId, item, notifier.ActionUpdated)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) muteShoppingList(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, anyMember); !ok {
+		return
+	}
+
+	err := h.db.MuteShoppingList(r.Context(), slId, sessionFromContext(r.Context()).AccountID)
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) unmuteShoppingList(w http.ResponseWriter, r *http.Request) {
+	slId, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := h.callerShoppingList(w, r, slId, anyMember); !ok {
 		return
 	}
 
-	err = h.db.ToggleProductStateInShoppingList(r.Context(), slId, pId)
-	if errors.As(err, &pgx.ErrNoRows) {
+	err := h.db.UnmuteShoppingList(r.Context(), slId, sessionFromContext(r.Context()).AccountID)
+	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusNotFound, []byte(err.Error()))
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listProductParam reads a product id or a barcode. A variable measure code
+// is resolved to the product of its item code, the weight becomes the quantity
+// of the item.
+func (h *handlers) listProductParam(w http.ResponseWriter, r *http.Request, name string, item *products.ListItem) (int64, bool) {
+	if code, err := barcode.ParseGTIN(chi.URLParam(r, name)); err == nil {
+		if m, ok := h.layouts.Match(code); ok {
+			p, err := h.db.FindProductByBarcode(r.Context(), m.Item)
+			if err != nil {
+				h.response.dbError(w, err, "product not found")
+				return 0, false
+			}
+
+			item.Quantity = products.MeasuredQuantity(m)
+			return p.ID, true
+		}
+	}
+
+	return h.intParam(w, r, name)
+}
+
+// notifyListChange tells the other subscribers of the list about the change
+// made by the session account.
+func (h *handlers) notifyListChange(r *http.Request, slId int64, pId int64, action notifier.Action) {
+	h.notifier.Notify(r.Context(), notifier.Change{
+		ListID:    slId,
+		AccountID: sessionFromContext(r.Context()).AccountID,
+		ProductID: pId,
+		Action:    action,
+	})
+}
+
+// notifyItemChange is notifyListChange for an item which may have no product.
+func (h *handlers) notifyItemChange(r *http.Request, slId int64, item products.ProductInList, action notifier.Action) {
+	c := notifier.Change{
+		ListID:    slId,
+		AccountID: sessionFromContext(r.Context()).AccountID,
+		ProductID: item.ID,
+		Action:    action,
+	}
+	if item.ID == 0 {
+		c.ItemID, c.ItemName = item.ItemID, item.Name
+	}
+
+	h.notifier.Notify(r.Context(), c)
+}
+
+// notifyItemsChange is notifyItemChange for items changed at once, e.g. moved
+// to another list.
+func (h *handlers) notifyItemsChange(r *http.Request, slId int64, items []products.ProductInList, action notifier.Action) {
+	for _, item := range items {
+		h.notifyItemChange(r, slId, item, action)
+	}
+}
+
+// notifyCopiedItems notifies about the items of a list just filled with a
+// copy of another one.
+func (h *handlers) notifyCopiedItems(r *http.Request, slId int64) {
+	items, err := h.db.GetShoppingListProducts(r.Context(), slId)
 	if err != nil {
 		h.logger.Error().Msg(err.Error())
-		h.response.json(w, http.StatusInternalServerError, []byte("internal server error"))
 		return
 	}
 
-	h.listener.Notify(r.Context(), slId)
+	h.notifyItemsChange(r, slId, items, notifier.ActionAdded)
+}
 
-	w.WriteHeader(http.StatusNoContent)
+func itemsByIds(items []products.ProductInList, ids []int64) []products.ProductInList {
+	selected := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	var result []products.ProductInList
+	for _, item := range items {
+		if selected[item.ItemID] {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// callerShoppingList loads the list with the role of the session account and
+// checks the role with allow. Lists the account is not a member of are
+// reported as not found, so their existence is not disclosed, and members
+// without the required role get forbidden.
+func (h *handlers) callerShoppingList(
+	w http.ResponseWriter,
+	r *http.Request,
+	slId int64,
+	allow func(products.ShoppingList) bool,
+) (products.ShoppingList, bool) {
+	sl, err := h.db.FindShoppingListForAccount(r.Context(), slId, sessionFromContext(r.Context()).AccountID)
+	if errors.Is(err, db.ErrNotFound) {
+		h.response.error(w, http.StatusNotFound, "shopping list not found")
+		return sl, false
+	}
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return sl, false
+	}
+
+	if !allow(sl) {
+		h.response.error(w, http.StatusForbidden, "forbidden")
+		return sl, false
+	}
+
+	return sl, true
+}
+
+// listInclude reads the include query parameter of list queries, a comma
+// separated list of archived and deleted. Deleted lists are the ones which can
+// still be restored.
+func (h *handlers) listInclude(w http.ResponseWriter, r *http.Request) (db.ListInclude, bool) {
+	var (
+		include db.ListInclude
+		rules   []validation.Rule
+	)
+	for _, v := range strings.Split(r.URL.Query().Get("include"), ",") {
+		switch v = strings.TrimSpace(v); v {
+		case "":
+		case "archived":
+			include.Archived = true
+		case "deleted":
+			include.DeletedAfter = time.Now().Add(-h.restoreWindow)
+		default:
+			rules = append(rules, validation.OneOf("include", v, "archived", "deleted"))
+		}
+	}
+
+	if err := validation.Validate(rules...); err != nil {
+		h.validationError(w, err)
+		return include, false
+	}
+
+	return include, true
+}
+
+func anyMember(products.ShoppingList) bool {
+	return true
 }
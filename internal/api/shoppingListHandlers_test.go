@@ -0,0 +1,131 @@
+package api
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"bybarcode/internal/auth"
+	"bybarcode/internal/products"
+)
+
+func TestShoppingListRoles(t *testing.T) {
+	const (
+		owner  = products.ListRoleOwner
+		editor = products.ListRoleEditor
+		viewer = products.ListRoleViewer
+	)
+
+	// otherAccount is a member the caller acts on in the member routes.
+	otherAccount := fmt.Sprint(testAccountId + 1)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		// allowed are the roles passing the check, the rest get 403
+		allowed []string
+		// rows answer the queries of the allowed roles
+		rows map[string][]driver.Value
+		// status is the answer to the allowed roles
+		status int
+	}{
+		{name: "get items", method: http.MethodGet, target: "/api/v1/shopping-list/3/product", allowed: []string{owner, editor, viewer}, status: http.StatusOK},
+		{name: "add item", method: http.MethodPost, target: "/api/v1/shopping-list/3/item", body: `{"name":"хлеб"}`, allowed: []string{owner, editor}, status: http.StatusOK},
+		{name: "delete item", method: http.MethodDelete, target: "/api/v1/shopping-list/3/item/11", allowed: []string{owner, editor}, status: http.StatusNotFound},
+		{name: "split", method: http.MethodPost, target: "/api/v1/shopping-list/3/split", body: `{"name":"Остальное","item_ids":[11]}`, allowed: []string{owner, editor}, status: http.StatusNotFound},
+		{name: "delete list", method: http.MethodDelete, target: "/api/v1/shopping-list/3", allowed: []string{owner}, rows: map[string][]driver.Value{queryDeleteList: {int64(testListId)}}, status: http.StatusNoContent},
+		{name: "archive", method: http.MethodPost, target: "/api/v1/shopping-list/3/archive", allowed: []string{owner}, rows: map[string][]driver.Value{queryArchiveList: {time.Now()}}, status: http.StatusNoContent},
+		{name: "unarchive", method: http.MethodDelete, target: "/api/v1/shopping-list/3/archive", allowed: []string{owner}, rows: map[string][]driver.Value{queryUnarchiveList: {int64(testListId)}}, status: http.StatusNoContent},
+		{name: "members", method: http.MethodGet, target: "/api/v1/shopping-list/3/member", allowed: []string{owner, editor, viewer}, status: http.StatusOK},
+		{name: "remove member", method: http.MethodDelete, target: "/api/v1/shopping-list/3/member/" + otherAccount, allowed: []string{owner}, status: http.StatusNotFound},
+		{name: "transfer", method: http.MethodPost, target: "/api/v1/shopping-list/3/owner/" + otherAccount, allowed: []string{owner}, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		for _, role := range []string{owner, editor, viewer} {
+			t.Run(tt.name+"/"+role, func(t *testing.T) {
+				a := newTestApi(t)
+				a.signIn(auth.RoleUser)
+				a.memberOf(role)
+				a.db.On(queryAddTextItem, []driver.Value{int64(11)})
+				for match, row := range tt.rows {
+					a.db.On(match, row)
+				}
+
+				w := a.do(t, tt.method, tt.target, testToken, tt.body)
+				if !contains(tt.allowed, role) {
+					assertError(t, w, http.StatusForbidden, codeForbidden, "")
+					return
+				}
+				if w.Code != tt.status {
+					t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body)
+				}
+			})
+		}
+	}
+}
+
+func TestShoppingListNotMember(t *testing.T) {
+	a := newTestApi(t)
+	a.signIn(auth.RoleUser)
+
+	w := a.do(t, http.MethodGet, "/api/v1/shopping-list/3/product", testToken, "")
+	assertError(t, w, http.StatusNotFound, codeNotFound, "shopping list not found")
+
+	lookups := a.db.Executed(queryListForAccount)
+	if len(lookups) != 1 || lookups[0][0] != int64(testListId) || lookups[0][1] != int64(testAccountId) {
+		t.Fatalf("list looked up with %v, want list %d of account %d", lookups, testListId, testAccountId)
+	}
+}
+
+func TestShoppingListForbiddenChangesNothing(t *testing.T) {
+	a := newTestApi(t)
+	a.signIn(auth.RoleUser)
+	a.memberOf(products.ListRoleEditor)
+
+	w := a.do(t, http.MethodDelete, "/api/v1/shopping-list/3", testToken, "")
+	assertError(t, w, http.StatusForbidden, codeForbidden, "")
+
+	if deleted := a.db.Executed(queryDeleteList); len(deleted) != 0 {
+		t.Fatalf("list was deleted by an editor: %v", deleted)
+	}
+}
+
+func TestLeaveShoppingList(t *testing.T) {
+	self := fmt.Sprint(testAccountId)
+
+	t.Run("member", func(t *testing.T) {
+		a := newTestApi(t)
+		a.signIn(auth.RoleUser)
+		a.memberOf(products.ListRoleViewer)
+		a.db.On(queryDeleteMember, []driver.Value{int64(testAccountId)})
+
+		w := a.do(t, http.MethodDelete, "/api/v1/shopping-list/3/member/"+self, testToken, "")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusNoContent, w.Body)
+		}
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		a := newTestApi(t)
+		a.signIn(auth.RoleUser)
+		a.memberOf(products.ListRoleOwner)
+
+		w := a.do(t, http.MethodDelete, "/api/v1/shopping-list/3/member/"+self, testToken, "")
+		assertError(t, w, http.StatusConflict, codeConflict, "the owner has to transfer the list before leaving it")
+	})
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"bybarcode/internal/message"
+	"bybarcode/internal/products"
+	"bybarcode/internal/validation"
+)
+
+func (h *handlers) addSubmission(w http.ResponseWriter, r *http.Request) {
+	var s products.Submission
+	if !h.decodeRequest(w, r, &s) {
+		return
+	}
+
+	s.AccountId = sessionFromContext(r.Context()).AccountID
+	s.BrandName = defaultIfEmpty(s.BrandName, "unknown brand")
+	s.CategoryName = defaultIfEmpty(s.CategoryName, "unknown category")
+
+	s, err := h.db.CreateSubmission(r.Context(), s)
+	if err != nil {
+		h.response.dbError(w, err, "account not found")
+		return
+	}
+
+	b, err := s.Encode()
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) getPendingSubmissions(w http.ResponseWriter, r *http.Request) {
+	list, err := h.db.GetSubmissionsByStatus(r.Context(), products.SubmissionPending)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	b, err := json.Marshal(list)
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) updateSubmission(w http.ResponseWriter, r *http.Request) {
+	var s products.Submission
+	if !h.decodeRequest(w, r, &s) || !h.validateId(w, s.ID) {
+		return
+	}
+
+	updS, err := h.db.UpdateSubmission(r.Context(), s)
+	if err != nil {
+		h.response.dbError(w, err, "pending submission not found")
+		return
+	}
+
+	b, err := updS.Encode()
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) approveSubmission(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	s, err := h.db.ApproveSubmission(r.Context(), id)
+	if err != nil {
+		h.response.dbError(w, err, "pending submission not found")
+		return
+	}
+
+	h.notifySubmitter(s, message.SubmissionApprovedMessage(s))
+
+	b, err := s.Encode()
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+func (h *handlers) rejectSubmission(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.intParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var body products.Submission
+	if r.ContentLength != 0 {
+		if err := body.Decode(r.Body); err != nil {
+			h.decodeError(w, err)
+			return
+		}
+	}
+
+	err := validation.Validate(validation.MaxLength("reason", body.Reason, 1000))
+	if err != nil {
+		h.validationError(w, err)
+		return
+	}
+
+	s, err := h.db.RejectSubmission(r.Context(), id, body.Reason)
+	if err != nil {
+		h.response.dbError(w, err, "pending submission not found")
+		return
+	}
+
+	h.notifySubmitter(s, message.SubmissionRejectedMessage(s))
+
+	b, err := s.Encode()
+	if err != nil {
+		h.logger.Error().Msg(err.Error())
+		h.response.error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.response.json(w, http.StatusOK, b)
+}
+
+// notifySubmitter doesn't fail the request, the review is already saved.
+func (h *handlers) notifySubmitter(s products.Submission, msg string) {
+	if s.AccountId == 0 {
+		return
+	}
+
+	if err := h.sender.SendMessage(s.AccountId, msg); err != nil {
+		h.logger.Error().Msg(err.Error())
+	}
+}
+
+func defaultIfEmpty(value string, defaultValue string) string {
+	if strings.TrimSpace(value) == "" {
+		return defaultValue
+	}
+
+	return strings.TrimSpace(value)
+}
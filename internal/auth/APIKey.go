@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"bybarcode/internal/validation"
+)
+
+// APIKeyPrefix tells API keys from session tokens in the Authorization header.
+const APIKeyPrefix = "bbk_"
+
+const (
+	ScopeCatalogRead = "catalog:read"
+	ScopeListsRead   = "lists:read"
+	ScopeListsWrite  = "lists:write"
+)
+
+// DefaultAPIKeyScopes are given to a key created without scopes.
+var DefaultAPIKeyScopes = []string{ScopeCatalogRead, ScopeListsRead}
+
+// APIKey is a long-lived token of an account for scripts and integrations.
+// Key is known only when the key is created, the database keeps its hash.
+type APIKey struct {
+	ID         int64      `json:"id" db:"id"`
+	AccountID  int64      `json:"account_id" db:"account_id"`
+	Name       string     `json:"name" db:"name"`
+	Key        string     `json:"key,omitempty" db:"-"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpireAt   *time.Time `json:"expire_at" db:"expire_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (k APIKey) Encode() ([]byte, error) {
+	return json.Marshal(k)
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type APIKeyRequest struct {
+	Name     string     `json:"name"`
+	Scopes   []string   `json:"scopes"`
+	ExpireAt *time.Time `json:"expire_at"`
+}
+
+func (r *APIKeyRequest) Decode(rd io.Reader) error {
+	return validation.DecodeJSON(rd, r)
+}
+
+func (r *APIKeyRequest) Validate() error {
+	_, scopesOk := NormalizeScopes(r.Scopes)
+
+	return validation.Validate(
+		validation.Required("name", r.Name),
+		validation.MaxLength("name", r.Name, 255),
+		validation.Check("scopes", scopesOk, "must be any of: "+JoinScopes([]string{ScopeCatalogRead, ScopeListsRead, ScopeListsWrite})),
+		validation.Check("expire_at", r.ExpireAt == nil || r.ExpireAt.After(time.Now()), "must be in the future"),
+	)
+}
+
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeCatalogRead, ScopeListsRead, ScopeListsWrite:
+		return true
+	}
+
+	return false
+}
+
+// NormalizeScopes drops duplicates and gives the default scopes to an empty
+// list. It returns false when one of the scopes is unknown.
+func NormalizeScopes(scopes []string) ([]string, bool) {
+	if len(scopes) == 0 {
+		return DefaultAPIKeyScopes, true
+	}
+
+	var normalized []string
+	seen := make(map[string]bool, len(scopes))
+	for _, s := range scopes {
+		if !ValidScope(s) {
+			return nil, false
+		}
+		if !seen[s] {
+			seen[s] = true
+			normalized = append(normalized, s)
+		}
+	}
+
+	return normalized, true
+}
+
+// JoinScopes and SplitScopes convert scopes to the space separated form they
+// are stored in.
+func JoinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+func SplitScopes(scopes string) []string {
+	return strings.Fields(scopes)
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		want   []string
+		ok     bool
+	}{
+		{name: "empty gets the defaults", scopes: nil, want: DefaultAPIKeyScopes, ok: true},
+		{name: "duplicates", scopes: []string{ScopeListsWrite, ScopeListsRead, ScopeListsWrite}, want: []string{ScopeListsWrite, ScopeListsRead}, ok: true},
+		{name: "unknown", scopes: []string{ScopeListsRead, "lists:delete"}, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NormalizeScopes(tt.scopes)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopesRoundTrip(t *testing.T) {
+	scopes := []string{ScopeCatalogRead, ScopeListsWrite}
+
+	if got := SplitScopes(JoinScopes(scopes)); !reflect.DeepEqual(got, scopes) {
+		t.Errorf("SplitScopes(JoinScopes(%v)) = %v", scopes, got)
+	}
+	if got := SplitScopes(""); len(got) != 0 {
+		t.Errorf("SplitScopes(\"\") = %v, want no scopes", got)
+	}
+}
+
+func TestIssueAPIKey(t *testing.T) {
+	tokens := NewTokenHasher("key")
+
+	key, err := tokens.IssueAPIKey(42, "script", []string{ScopeListsRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(key.Key, APIKeyPrefix) {
+		t.Errorf("key %q has no %q prefix", key.Key, APIKeyPrefix)
+	}
+	if key.KeyHash != tokens.Hash(key.Key) {
+		t.Error("key hash is not the hash of the key")
+	}
+	if !key.HasScope(ScopeListsRead) || key.HasScope(ScopeListsWrite) {
+		t.Errorf("key scopes = %v, want only %s", key.Scopes, ScopeListsRead)
+	}
+}
@@ -1,8 +1,20 @@
 package auth
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type Account struct {
 	ID        int64  `json:"id" db:"id"`
 	FirstName string `json:"first_name" db:"first_name"`
 	LastName  string `json:"last_name" db:"last_name"`
 	Username  string `json:"username" db:"username"`
+	Role      string `json:"role" db:"role"`
+}
+
+// IsModerator reports whether the account may review the product catalog.
+func (a Account) IsModerator() bool {
+	return a.Role == RoleModerator || a.Role == RoleAdmin
 }
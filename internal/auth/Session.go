@@ -2,21 +2,58 @@ package auth
 
 import (
 	"encoding/json"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
+
+	"bybarcode/internal/validation"
+)
+
+const (
+	AccessTokenTTL  = 24 * time.Hour
+	RefreshTokenTTL = 30 * 24 * time.Hour
 )
 
+// Session is one link of a login. Refreshing replaces the session with a new
+// one of the same family, so FamilyID identifies the login on a device.
+// Token and RefreshToken are known only when the session is issued, the
+// database keeps their hashes.
 type Session struct {
-	ID           uuid.UUID `json:"-" db:"id"`
-	Token        uuid.UUID `json:"token" db:"token"`
-	RefreshToken uuid.UUID `json:"refresh_token" db:"refresh_token"`
-	AccountID    int64     `json:"account_id" db:"account_id"`
-	ExpireAt     time.Time `json:"expire_at" db:"expire_at"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID               uuid.UUID `json:"-" db:"id"`
+	Token            string    `json:"token,omitempty" db:"-"`
+	RefreshToken     string    `json:"refresh_token,omitempty" db:"-"`
+	TokenHash        string    `json:"-" db:"token_hash"`
+	RefreshTokenHash string    `json:"-" db:"refresh_token_hash"`
+	AccountID        int64     `json:"account_id" db:"account_id"`
+	FamilyID         uuid.UUID `json:"-" db:"family_id"`
+	ExpireAt         time.Time `json:"expire_at" db:"expire_at"`
+	RefreshExpireAt  time.Time `json:"refresh_expire_at" db:"refresh_expire_at"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 }
 
 func (s Session) Encode() ([]byte, error) {
 	return json.Marshal(s)
 }
+
+// SessionInfo describes an active login of the account without its tokens.
+type SessionInfo struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+	ExpireAt    time.Time `json:"expire_at"`
+	Current     bool      `json:"current"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r *RefreshRequest) Decode(rd io.Reader) error {
+	return validation.DecodeJSON(rd, r)
+}
+
+func (r *RefreshRequest) Validate() error {
+	return validation.Validate(validation.Required("refresh_token", r.RefreshToken))
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const tokenSize = 32
+
+// TokenHasher issues opaque session tokens and hashes them with a server key.
+// Only the hashes are stored, so a database dump can't be used to log in.
+type TokenHasher struct {
+	key []byte
+}
+
+func NewTokenHasher(key string) TokenHasher {
+	return TokenHasher{key: []byte(key)}
+}
+
+func (t TokenHasher) Hash(token string) string {
+	return hex.EncodeToString(hmacSHA256(t.key, []byte(token)))
+}
+
+// IssueSession creates a session with new tokens which starts its own family.
+// Refreshing moves the new session to the family of the refreshed one.
+func (t TokenHasher) IssueSession(accountId int64) (Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return Session{}, err
+	}
+
+	refreshToken, err := newToken()
+	if err != nil {
+		return Session{}, err
+	}
+
+	now := time.Now()
+	id := uuid.New()
+
+	return Session{
+		ID:               id,
+		Token:            token,
+		RefreshToken:     refreshToken,
+		TokenHash:        t.Hash(token),
+		RefreshTokenHash: t.Hash(refreshToken),
+		AccountID:        accountId,
+		FamilyID:         id,
+		ExpireAt:         now.Add(AccessTokenTTL),
+		RefreshExpireAt:  now.Add(RefreshTokenTTL),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IssueAPIKey creates a key of the account. A nil expireAt means the key
+// works until it is revoked.
+func (t TokenHasher) IssueAPIKey(accountId int64, name string, scopes []string, expireAt *time.Time) (APIKey, error) {
+	token, err := newToken()
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	key := APIKeyPrefix + token
+
+	return APIKey{
+		AccountID: accountId,
+		Name:      name,
+		Key:       key,
+		KeyHash:   t.Hash(key),
+		Scopes:    scopes,
+		ExpireAt:  expireAt,
+		CreatedAt: time.Now(),
+	}, nil
+}
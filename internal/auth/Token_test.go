@@ -0,0 +1,50 @@
+package auth
+
+import "testing"
+
+func TestTokenHasherHash(t *testing.T) {
+	tokens := NewTokenHasher("key")
+
+	if tokens.Hash("token") != tokens.Hash("token") {
+		t.Error("hash of the same token differs")
+	}
+	if tokens.Hash("token") == tokens.Hash("other-token") {
+		t.Error("different tokens have the same hash")
+	}
+	if tokens.Hash("token") == NewTokenHasher("other-key").Hash("token") {
+		t.Error("hash does not depend on the server key")
+	}
+	if tokens.Hash("token") == "token" {
+		t.Error("token is not hashed")
+	}
+}
+
+func TestIssueSession(t *testing.T) {
+	tokens := NewTokenHasher("key")
+
+	s, err := tokens.IssueSession(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Token == "" || s.RefreshToken == "" || s.Token == s.RefreshToken {
+		t.Fatalf("tokens %q and %q are not two new tokens", s.Token, s.RefreshToken)
+	}
+	if s.TokenHash != tokens.Hash(s.Token) || s.RefreshTokenHash != tokens.Hash(s.RefreshToken) {
+		t.Error("session keeps other hashes than the ones of its tokens")
+	}
+	if s.FamilyID != s.ID {
+		t.Errorf("family = %s, want a new family %s", s.FamilyID, s.ID)
+	}
+	if !s.RefreshExpireAt.After(s.ExpireAt) {
+		t.Errorf("refresh token expires at %s, before the token at %s", s.RefreshExpireAt, s.ExpireAt)
+	}
+
+	next, err := tokens.IssueSession(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Token == s.Token || next.RefreshToken == s.RefreshToken {
+		t.Error("tokens are issued twice")
+	}
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bybarcode/internal/validation"
+)
+
+var (
+	ErrInitDataInvalid = errors.New("init data signature is invalid")
+	ErrInitDataExpired = errors.New("init data is expired")
+)
+
+// WebAppUser is the Telegram user who opened the web app.
+type WebAppUser struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Username  string `json:"username"`
+}
+
+type WebAppAuthRequest struct {
+	InitData string `json:"init_data"`
+}
+
+func (r *WebAppAuthRequest) Decode(rd io.Reader) error {
+	return validation.DecodeJSON(rd, r)
+}
+
+func (r *WebAppAuthRequest) Validate() error {
+	return validation.Validate(validation.Required("init_data", r.InitData))
+}
+
+// initDataClockSkew is how far auth_date may be ahead of the clock of the api.
+// Data signed further in the future would stay valid longer than maxAge.
+const initDataClockSkew = time.Minute
+
+// ValidateWebAppInitData checks the signature of Telegram.WebApp.initData as
+// described in https://core.telegram.org/bots/webapps#validating-data-received-via-the-mini-app
+// and returns the user from it. Data signed more than maxAge ago is rejected,
+// so a leaked init data string can't be used to log in forever.
+func ValidateWebAppInitData(initData string, botToken string, maxAge time.Duration, now time.Time) (WebAppUser, error) {
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return WebAppUser{}, ErrInitDataInvalid
+	}
+
+	hash, err := hex.DecodeString(values.Get("hash"))
+	if err != nil || len(hash) == 0 {
+		return WebAppUser{}, ErrInitDataInvalid
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hash" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+values.Get(key))
+	}
+
+	secret := hmacSHA256([]byte("WebAppData"), []byte(botToken))
+	if !hmac.Equal(hmacSHA256(secret, []byte(strings.Join(pairs, "\n"))), hash) {
+		return WebAppUser{}, ErrInitDataInvalid
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return WebAppUser{}, ErrInitDataInvalid
+	}
+	age := now.Sub(time.Unix(authDate, 0))
+	if age < -initDataClockSkew {
+		return WebAppUser{}, ErrInitDataInvalid
+	}
+	if age > maxAge {
+		return WebAppUser{}, ErrInitDataExpired
+	}
+
+	user := WebAppUser{}
+	if err = json.Unmarshal([]byte(values.Get("user")), &user); err != nil || user.ID == 0 {
+		return WebAppUser{}, ErrInitDataInvalid
+	}
+
+	return user, nil
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBotToken = "123456:test-token"
+
+// signInitData builds init data the way Telegram does: the data-check string
+// of the sorted keys signed with the key derived from the bot token.
+func signInitData(values url.Values, botToken string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, key+"="+values.Get(key))
+	}
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(botToken))
+	h := hmac.New(sha256.New, secret.Sum(nil))
+	h.Write([]byte(strings.Join(lines, "\n")))
+
+	signed := url.Values{}
+	for key := range values {
+		signed.Set(key, values.Get(key))
+	}
+	signed.Set("hash", hex.EncodeToString(h.Sum(nil)))
+
+	return signed.Encode()
+}
+
+func TestValidateWebAppInitData(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	maxAge := time.Hour
+
+	values := func() url.Values {
+		return url.Values{
+			"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+			"user":      {`{"id":279058397,"first_name":"Vladislav","last_name":"Kibenko","username":"vdkfrost"}`},
+			"auth_date": {strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)},
+			// sorting the "k=v" lines would put "param2=" before "param="
+			"param":  {"a"},
+			"param2": {"b"},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		initData func() string
+		user     WebAppUser
+		err      error
+	}{
+		{
+			name:     "valid",
+			initData: func() string { return signInitData(values(), testBotToken) },
+			user:     WebAppUser{ID: 279058397, FirstName: "Vladislav", LastName: "Kibenko", Username: "vdkfrost"},
+		},
+		{
+			name: "tampered user",
+			initData: func() string {
+				q, _ := url.ParseQuery(signInitData(values(), testBotToken))
+				q.Set("user", `{"id":1,"first_name":"Mallory"}`)
+				return q.Encode()
+			},
+			err: ErrInitDataInvalid,
+		},
+		{
+			name: "tampered auth date",
+			initData: func() string {
+				q, _ := url.ParseQuery(signInitData(values(), testBotToken))
+				q.Set("auth_date", strconv.FormatInt(now.Unix(), 10))
+				return q.Encode()
+			},
+			err: ErrInitDataInvalid,
+		},
+		{
+			name: "added field",
+			initData: func() string {
+				return signInitData(values(), testBotToken) + "&start_param=x"
+			},
+			err: ErrInitDataInvalid,
+		},
+		{
+			name:     "wrong bot token",
+			initData: func() string { return signInitData(values(), "654321:other-token") },
+			err:      ErrInitDataInvalid,
+		},
+		{
+			name: "missing hash",
+			initData: func() string {
+				q, _ := url.ParseQuery(signInitData(values(), testBotToken))
+				q.Del("hash")
+				return q.Encode()
+			},
+			err: ErrInitDataInvalid,
+		},
+		{
+			name: "hash is not hex",
+			initData: func() string {
+				q, _ := url.ParseQuery(signInitData(values(), testBotToken))
+				q.Set("hash", "not-a-hash")
+				return q.Encode()
+			},
+			err: ErrInitDataInvalid,
+		},
+		{
+			name: "expired",
+			initData: func() string {
+				v := values()
+				v.Set("auth_date", strconv.FormatInt(now.Add(-maxAge-time.Second).Unix(), 10))
+				return signInitData(v, testBotToken)
+			},
+			err: ErrInitDataExpired,
+		},
+		{
+			name: "signed in the future",
+			initData: func() string {
+				v := values()
+				v.Set("auth_date", strconv.FormatInt(now.Add(initDataClockSkew+time.Second).Unix(), 10))
+				return signInitData(v, testBotToken)
+			},
+			err: ErrInitDataInvalid,
+		},
+		{
+			name: "within clock skew",
+			initData: func() string {
+				v := values()
+				v.Set("auth_date", strconv.FormatInt(now.Add(initDataClockSkew).Unix(), 10))
+				return signInitData(v, testBotToken)
+			},
+			user: WebAppUser{ID: 279058397, FirstName: "Vladislav", LastName: "Kibenko", Username: "vdkfrost"},
+		},
+		{
+			name: "no auth date",
+			initData: func() string {
+				v := values()
+				v.Del("auth_date")
+				return signInitData(v, testBotToken)
+			},
+			err: ErrInitDataInvalid,
+		},
+		{
+			name: "no user",
+			initData: func() string {
+				v := values()
+				v.Del("user")
+				return signInitData(v, testBotToken)
+			},
+			err: ErrInitDataInvalid,
+		},
+		{
+			name:     "not a query",
+			initData: func() string { return "%zz" },
+			err:      ErrInitDataInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := ValidateWebAppInitData(tt.initData(), testBotToken, maxAge, now)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if user != tt.user {
+				t.Fatalf("user = %+v, want %+v", user, tt.user)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package barcode
+
+import (
+	"image"
+	"image/color"
+)
+
+const (
+	scanLinesCount = 60
+	minLineHits    = 2
+	minContrast    = 40
+	localThreshold = 8
+	quietZone      = 5
+)
+
+type decodeFunc func(runs []int) (Result, int, bool)
+
+// decoders are tried in order at every bar. modules is the symbol width used to
+// estimate the module size for quiet zone checks.
+var decoders = []struct {
+	decode  decodeFunc
+	modules int
+}{
+	{decodeEAN13, 95},
+	{decodeEAN8, 67},
+	{decodeUPCE, 51},
+}
+
+// Decode scans the image along horizontal and vertical lines and returns every
+// EAN-13, EAN-8, UPC-A and UPC-E code found on it. A code has to be read on at
+// least two scan lines to be reported, which filters out accidental matches.
+func Decode(img image.Image) []Result {
+	b := img.Bounds()
+	hits := map[Result]int{}
+	var found []Result
+
+	scan := func(line []uint8) {
+		for _, r := range decodeLine(line) {
+			if hits[r] == 0 {
+				found = append(found, r)
+			}
+			hits[r]++
+		}
+	}
+
+	step := maxInt(1, b.Dy()/scanLinesCount)
+	for y := b.Min.Y + step/2; y < b.Max.Y; y += step {
+		line := make([]uint8, b.Dx())
+		for x := b.Min.X; x < b.Max.X; x++ {
+			line[x-b.Min.X] = gray(img.At(x, y))
+		}
+		scan(line)
+	}
+
+	step = maxInt(1, b.Dx()/scanLinesCount)
+	for x := b.Min.X + step/2; x < b.Max.X; x += step {
+		line := make([]uint8, b.Dy())
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			line[y-b.Min.Y] = gray(img.At(x, y))
+		}
+		scan(line)
+	}
+
+	var results []Result
+	for _, r := range found {
+		if hits[r] >= minLineHits {
+			results = append(results, r)
+		}
+	}
+
+	return results
+}
+
+// decodeLine binarizes a single scan line in a couple of ways and tries to read
+// codes from it in both directions.
+func decodeLine(line []uint8) []Result {
+	lo, hi := uint8(255), uint8(0)
+	for _, v := range line {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	if int(hi)-int(lo) < minContrast {
+		return nil
+	}
+
+	seen := map[Result]bool{}
+	var results []Result
+	for _, bits := range [][]bool{globalBinarize(line, lo, hi), localBinarize(line)} {
+		for _, reversed := range []bool{false, true} {
+			for _, r := range decodeRuns(toRuns(bits, reversed)) {
+				if !seen[r] {
+					seen[r] = true
+					results = append(results, r)
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+// decodeRuns looks for symbols in alternating bar/space widths. Even indexes
+// are bars.
+func decodeRuns(runs []int) []Result {
+	var results []Result
+	for i := 0; i < len(runs); i += 2 {
+		for _, d := range decoders {
+			r, size, ok := d.decode(runs[i:])
+			if !ok {
+				continue
+			}
+
+			width := 0
+			for _, w := range runs[i : i+size] {
+				width += w
+			}
+			module := float64(width) / float64(d.modules)
+
+			if i > 0 && float64(runs[i-1]) < quietZone*module {
+				continue
+			}
+			if i+size < len(runs) && float64(runs[i+size]) < quietZone*module {
+				continue
+			}
+
+			results = append(results, r)
+			i += size - 1
+			break
+		}
+	}
+
+	return results
+}
+
+// toRuns converts binarized pixels (true for black) to run widths, starting
+// with the first black run.
+func toRuns(bits []bool, reversed bool) []int {
+	var runs []int
+	current, count := false, 0
+
+	for i := range bits {
+		b := bits[i]
+		if reversed {
+			b = bits[len(bits)-1-i]
+		}
+
+		if b == current {
+			count++
+			continue
+		}
+		if count > 0 && (current || len(runs) > 0) {
+			runs = append(runs, count)
+		}
+		current, count = b, 1
+	}
+	if count > 0 && (current || len(runs) > 0) {
+		runs = append(runs, count)
+	}
+
+	return runs
+}
+
+func globalBinarize(line []uint8, lo uint8, hi uint8) []bool {
+	threshold := (int(lo) + int(hi)) / 2
+	bits := make([]bool, len(line))
+	for i, v := range line {
+		bits[i] = int(v) < threshold
+	}
+
+	return bits
+}
+
+// localBinarize compares each pixel with the mean of its neighbourhood, which
+// copes with uneven lighting across the photo.
+func localBinarize(line []uint8) []bool {
+	radius := maxInt(4, len(line)/32)
+	sums := make([]int, len(line)+1)
+	for i, v := range line {
+		sums[i+1] = sums[i] + int(v)
+	}
+
+	bits := make([]bool, len(line))
+	for i, v := range line {
+		from, to := maxInt(0, i-radius), minInt(len(line), i+radius+1)
+		mean := (sums[to] - sums[from]) / (to - from)
+		bits[i] = int(v) < mean-localThreshold
+	}
+
+	return bits
+}
+
+func gray(c color.Color) uint8 {
+	return color.GrayModel.Convert(c).(color.Gray).Y
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
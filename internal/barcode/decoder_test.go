@@ -0,0 +1,224 @@
+package barcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// symbol is a barcode as module widths of alternating bars and spaces,
+// starting with a bar. Every pattern starts with the colour the previous one
+// doesn't end with, so widths are only appended.
+type symbol []int
+
+func (s symbol) add(widths []int) symbol {
+	return append(s, widths...)
+}
+
+func inverse(m map[int]byte) map[byte]int {
+	r := make(map[byte]int, len(m))
+	for k, v := range m {
+		r[v] = k
+	}
+
+	return r
+}
+
+// encodeEAN13 draws 13 digits, the check digit is not recalculated so wrong
+// codes can be drawn too.
+func encodeEAN13(digits string) symbol {
+	parity := inverse(ean13FirstDigit)[digits[0]]
+
+	s := symbol{}.add(guardPattern)
+	for i := 0; i < 6; i++ {
+		d := digits[i+1] - '0'
+		if parity&(1<<(5-i)) != 0 {
+			s = s.add(gPatterns[d])
+		} else {
+			s = s.add(lPatterns[d])
+		}
+	}
+	s = s.add(middleGuardPattern)
+	for i := 7; i < 13; i++ {
+		s = s.add(lPatterns[digits[i]-'0'])
+	}
+
+	return s.add(guardPattern)
+}
+
+func encodeEAN8(digits string) symbol {
+	s := symbol{}.add(guardPattern)
+	for i := 0; i < 4; i++ {
+		s = s.add(lPatterns[digits[i]-'0'])
+	}
+	s = s.add(middleGuardPattern)
+	for i := 4; i < 8; i++ {
+		s = s.add(lPatterns[digits[i]-'0'])
+	}
+
+	return s.add(guardPattern)
+}
+
+func encodeUPCE(digits string) symbol {
+	parity := inverse(upceCheckDigit)[digits[7]]
+	if digits[0] == '1' {
+		parity ^= 0x3F
+	}
+
+	s := symbol{}.add(guardPattern)
+	for i := 0; i < 6; i++ {
+		d := digits[i+1] - '0'
+		if parity&(1<<(5-i)) != 0 {
+			s = s.add(gPatterns[d])
+		} else {
+			s = s.add(lPatterns[d])
+		}
+	}
+
+	return s.add(upceEndPattern)
+}
+
+const (
+	testModule = 3
+	testQuiet  = 12
+	testHeight = 90
+)
+
+// render draws the symbol with quiet zones around it, bars are vertical.
+func render(s symbol) *image.Gray {
+	modules := 2 * testQuiet
+	for _, w := range s {
+		modules += w
+	}
+
+	img := image.NewGray(image.Rect(0, 0, modules*testModule, testHeight))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+
+	x := testQuiet * testModule
+	for i, w := range s {
+		if i%2 == 0 {
+			for px := x; px < x+w*testModule; px++ {
+				for y := 10; y < testHeight-10; y++ {
+					img.SetGray(px, y, color.Gray{Y: 0})
+				}
+			}
+		}
+		x += w * testModule
+	}
+
+	return img
+}
+
+// rotate turns the image around its centre on a larger white canvas.
+func rotate(src *image.Gray, degrees float64) *image.Gray {
+	b := src.Bounds()
+	size := int(math.Hypot(float64(b.Dx()), float64(b.Dy()))) + 1
+	dst := image.NewGray(image.Rect(0, 0, size, size))
+
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	cx, cy := float64(b.Dx())/2, float64(b.Dy())/2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)-float64(size)/2, float64(y)-float64(size)/2
+			sx, sy := int(cos*dx+sin*dy+cx), int(-sin*dx+cos*dy+cy)
+			c := uint8(255)
+			if image.Pt(sx, sy).In(b) {
+				c = src.GrayAt(sx, sy).Y
+			}
+			dst.SetGray(x, y, color.Gray{Y: c})
+		}
+	}
+
+	return dst
+}
+
+// noisy adds random noise and a lighting gradient from left to right.
+func noisy(src *image.Gray, amount int) *image.Gray {
+	rnd := rand.New(rand.NewSource(1))
+	b := src.Bounds()
+	dst := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := int(src.GrayAt(x, y).Y)*3/4 + 40*x/b.Dx() + rnd.Intn(2*amount+1) - amount
+			dst.SetGray(x, y, color.Gray{Y: uint8(minInt(255, maxInt(0, v)))})
+		}
+	}
+
+	return dst
+}
+
+func TestDecode(t *testing.T) {
+	blank := image.NewGray(image.Rect(0, 0, 300, 100))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+
+	tests := []struct {
+		name  string
+		img   image.Image
+		codes []GTIN
+	}{
+		{name: "EAN-13", img: render(encodeEAN13("4006381333931")), codes: []GTIN{"04006381333931"}},
+		{name: "EAN-8", img: render(encodeEAN8("96385074")), codes: []GTIN{"00000096385074"}},
+		{name: "UPC-A", img: render(encodeEAN13("0036000291452")), codes: []GTIN{"00036000291452"}},
+		{name: "UPC-E", img: render(encodeUPCE("04252614")), codes: []GTIN{"00042100005264"}},
+		{name: "UPC-E number system 1", img: render(encodeUPCE("11234593")), codes: []GTIN{"00112345000093"}},
+		{name: "upside down", img: rotate(render(encodeEAN13("4006381333931")), 180), codes: []GTIN{"04006381333931"}},
+		{name: "vertical", img: rotate(render(encodeEAN13("4006381333931")), 90), codes: []GTIN{"04006381333931"}},
+		{name: "tilted", img: rotate(render(encodeEAN13("4006381333931")), 7), codes: []GTIN{"04006381333931"}},
+		{name: "noisy", img: noisy(render(encodeEAN13("4006381333931")), 30), codes: []GTIN{"04006381333931"}},
+		{name: "noisy tilted", img: noisy(rotate(render(encodeEAN8("96385074")), -5), 25), codes: []GTIN{"00000096385074"}},
+		{name: "blank", img: blank},
+		{name: "noise only", img: noisy(blank, 120)},
+		{name: "EAN-13 wrong check digit", img: render(encodeEAN13("4006381333932"))},
+		{name: "EAN-8 wrong check digit", img: render(encodeEAN8("96385075"))},
+		{name: "UPC-A wrong check digit", img: render(encodeEAN13("0036000291453"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Decode(tt.img)
+			if len(results) != len(tt.codes) {
+				t.Fatalf("Decode() = %+v, want %v", results, tt.codes)
+			}
+
+			for i, r := range results {
+				code, err := ResultGTIN(r)
+				if err != nil {
+					t.Fatalf("ResultGTIN(%+v): %v", r, err)
+				}
+				if code != tt.codes[i] {
+					t.Fatalf("ResultGTIN(%+v) = %s, want %s", r, code, tt.codes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResultGTIN(t *testing.T) {
+	tests := []struct {
+		result Result
+		want   GTIN
+		err    error
+	}{
+		{result: Result{Format: EAN13, Digits: "4006381333931"}, want: "04006381333931"},
+		{result: Result{Format: UPCA, Digits: "036000291452"}, want: "00036000291452"},
+		{result: Result{Format: EAN8, Digits: "96385074"}, want: "00000096385074"},
+		{result: Result{Format: UPCE, Digits: "01234505"}, want: "00012000003455"},
+		{result: Result{Format: EAN13, Digits: "4006381333932"}, err: ErrInvalidChecksum},
+		{result: Result{Format: UPCE, Digits: "04252615"}, err: ErrInvalidChecksum},
+		{result: Result{Format: UPCE, Digits: "0425261"}, err: ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		got, err := ResultGTIN(tt.result)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("ResultGTIN(%+v) = %q, %v, want %q, %v", tt.result, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package barcode
+
+import "math"
+
+type Format string
+
+const (
+	EAN13 Format = "EAN-13"
+	EAN8  Format = "EAN-8"
+	UPCA  Format = "UPC-A"
+	UPCE  Format = "UPC-E"
+)
+
+type Result struct {
+	Format Format
+	Digits string
+}
+
+const (
+	maxAvgVariance        = 0.48
+	maxIndividualVariance = 0.7
+)
+
+var (
+	guardPattern       = []int{1, 1, 1}
+	middleGuardPattern = []int{1, 1, 1, 1, 1}
+	upceEndPattern     = []int{1, 1, 1, 1, 1, 1}
+)
+
+// lPatterns are module widths of the odd parity (L) digit encodings. R codes
+// share the same widths with inverted colours.
+var lPatterns = [10][]int{
+	{3, 2, 1, 1},
+	{2, 2, 2, 1},
+	{2, 1, 2, 2},
+	{1, 4, 1, 1},
+	{1, 1, 3, 2},
+	{1, 2, 3, 1},
+	{1, 1, 1, 4},
+	{1, 3, 1, 2},
+	{1, 2, 1, 3},
+	{3, 1, 1, 2},
+}
+
+// gPatterns are module widths of the even parity (G) digit encodings.
+var gPatterns = [10][]int{
+	{1, 1, 2, 3},
+	{1, 2, 2, 2},
+	{2, 2, 1, 2},
+	{1, 1, 4, 1},
+	{2, 3, 1, 1},
+	{1, 3, 2, 1},
+	{4, 1, 1, 1},
+	{2, 1, 3, 1},
+	{3, 1, 2, 1},
+	{2, 1, 1, 3},
+}
+
+// ean13FirstDigit maps parity of the six left digits (bit set for G) to the
+// implicit first digit of EAN-13.
+var ean13FirstDigit = map[int]byte{
+	0x00: '0',
+	0x0B: '1',
+	0x0D: '2',
+	0x0E: '3',
+	0x13: '4',
+	0x19: '5',
+	0x1C: '6',
+	0x15: '7',
+	0x16: '8',
+	0x1A: '9',
+}
+
+// upceCheckDigit maps parity of UPC-E digits (bit set for G) to the check
+// digit for number system 0. Number system 1 uses the inverted parity.
+var upceCheckDigit = map[int]byte{
+	0x38: '0',
+	0x34: '1',
+	0x32: '2',
+	0x31: '3',
+	0x2C: '4',
+	0x26: '5',
+	0x23: '6',
+	0x2A: '7',
+	0x29: '8',
+	0x25: '9',
+}
+
+func decodeEAN13(runs []int) (Result, int, bool) {
+	const size = 3 + 6*4 + 5 + 6*4 + 3
+	if len(runs) < size || !matches(runs[0:3], guardPattern) {
+		return Result{}, 0, false
+	}
+
+	digits := make([]byte, 13)
+	parity := 0
+	for i := 0; i < 6; i++ {
+		d, g, ok := decodeDigit(runs[3+i*4:7+i*4], true)
+		if !ok {
+			return Result{}, 0, false
+		}
+		digits[i+1] = d
+		parity <<= 1
+		if g {
+			parity |= 1
+		}
+	}
+
+	if !matches(runs[27:32], middleGuardPattern) {
+		return Result{}, 0, false
+	}
+
+	for i := 0; i < 6; i++ {
+		d, _, ok := decodeDigit(runs[32+i*4:36+i*4], false)
+		if !ok {
+			return Result{}, 0, false
+		}
+		digits[i+7] = d
+	}
+
+	if !matches(runs[56:59], guardPattern) {
+		return Result{}, 0, false
+	}
+
+	first, ok := ean13FirstDigit[parity]
+	if !ok {
+		return Result{}, 0, false
+	}
+	digits[0] = first
+
+	s := string(digits)
+	if !ValidChecksum(s) {
+		return Result{}, 0, false
+	}
+
+	if first == '0' {
+		return Result{Format: UPCA, Digits: s[1:]}, size, true
+	}
+
+	return Result{Format: EAN13, Digits: s}, size, true
+}
+
+func decodeEAN8(runs []int) (Result, int, bool) {
+	const size = 3 + 4*4 + 5 + 4*4 + 3
+	if len(runs) < size || !matches(runs[0:3], guardPattern) {
+		return Result{}, 0, false
+	}
+
+	digits := make([]byte, 8)
+	for i := 0; i < 4; i++ {
+		d, g, ok := decodeDigit(runs[3+i*4:7+i*4], true)
+		if !ok || g {
+			return Result{}, 0, false
+		}
+		digits[i] = d
+	}
+
+	if !matches(runs[19:24], middleGuardPattern) {
+		return Result{}, 0, false
+	}
+
+	for i := 0; i < 4; i++ {
+		d, _, ok := decodeDigit(runs[24+i*4:28+i*4], false)
+		if !ok {
+			return Result{}, 0, false
+		}
+		digits[i+4] = d
+	}
+
+	if !matches(runs[40:43], guardPattern) {
+		return Result{}, 0, false
+	}
+
+	s := string(digits)
+	if !ValidChecksum(s) {
+		return Result{}, 0, false
+	}
+
+	return Result{Format: EAN8, Digits: s}, size, true
+}
+
+func decodeUPCE(runs []int) (Result, int, bool) {
+	const size = 3 + 6*4 + 6
+	if len(runs) < size || !matches(runs[0:3], guardPattern) {
+		return Result{}, 0, false
+	}
+
+	digits := make([]byte, 8)
+	parity := 0
+	for i := 0; i < 6; i++ {
+		d, g, ok := decodeDigit(runs[3+i*4:7+i*4], true)
+		if !ok {
+			return Result{}, 0, false
+		}
+		digits[i+1] = d
+		parity <<= 1
+		if g {
+			parity |= 1
+		}
+	}
+
+	if !matches(runs[27:33], upceEndPattern) {
+		return Result{}, 0, false
+	}
+
+	if check, ok := upceCheckDigit[parity]; ok {
+		digits[0], digits[7] = '0', check
+	} else if check, ok := upceCheckDigit[parity^0x3F]; ok {
+		digits[0], digits[7] = '1', check
+	} else {
+		return Result{}, 0, false
+	}
+
+	s := string(digits)
+	if !ValidChecksum(ExpandUPCE(s)) {
+		return Result{}, 0, false
+	}
+
+	return Result{Format: UPCE, Digits: s}, size, true
+}
+
+// ExpandUPCE converts an 8 digit UPC-E code (number system, six digits and
+// check digit) to the equivalent 12 digit UPC-A code.
+func ExpandUPCE(upce string) string {
+	if len(upce) != 8 {
+		return ""
+	}
+
+	ns, d, check := upce[0:1], upce[1:7], upce[7:8]
+
+	var body string
+	switch d[5] {
+	case '0', '1', '2':
+		body = d[0:2] + d[5:6] + "0000" + d[2:5]
+	case '3':
+		body = d[0:3] + "00000" + d[3:5]
+	case '4':
+		body = d[0:4] + "00000" + d[4:5]
+	default:
+		body = d[0:5] + "0000" + d[5:6]
+	}
+
+	return ns + body + check
+}
+
+// ValidChecksum reports whether the last digit of an EAN/UPC code is its
+// correct modulo 10 check digit.
+func ValidChecksum(digits string) bool {
+	if len(digits) < 2 {
+		return false
+	}
+
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return false
+		}
+	}
+
+	return CheckDigit(digits[:len(digits)-1]) == digits[len(digits)-1]
+}
+
+// CheckDigit calculates the modulo 10 check digit for the given digits
+// without a check digit.
+func CheckDigit(digits string) byte {
+	sum, weight := 0, 3
+	for i := len(digits) - 1; i >= 0; i-- {
+		sum += int(digits[i]-'0') * weight
+		weight = 4 - weight
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
+
+func decodeDigit(counters []int, left bool) (byte, bool, bool) {
+	best, bestG, bestVariance := -1, false, maxAvgVariance
+	for d := 0; d < 10; d++ {
+		if v := patternVariance(counters, lPatterns[d]); v < bestVariance {
+			best, bestG, bestVariance = d, false, v
+		}
+		if !left {
+			continue
+		}
+		if v := patternVariance(counters, gPatterns[d]); v < bestVariance {
+			best, bestG, bestVariance = d, true, v
+		}
+	}
+
+	if best < 0 {
+		return 0, false, false
+	}
+
+	return byte('0' + best), bestG, true
+}
+
+func matches(counters []int, pattern []int) bool {
+	return patternVariance(counters, pattern) < maxAvgVariance
+}
+
+// patternVariance returns how far the observed run widths are from the
+// expected pattern, normalised to the module width.
+func patternVariance(counters []int, pattern []int) float64 {
+	total, patternLength := 0, 0
+	for i := range counters {
+		total += counters[i]
+		patternLength += pattern[i]
+	}
+	if total < patternLength {
+		return math.Inf(1)
+	}
+
+	unit := float64(total) / float64(patternLength)
+	maxIndividual := maxIndividualVariance * unit
+
+	totalVariance := 0.0
+	for i := range counters {
+		v := math.Abs(float64(counters[i]) - float64(pattern[i])*unit)
+		if v > maxIndividual {
+			return math.Inf(1)
+		}
+		totalVariance += v
+	}
+
+	return totalVariance / float64(total)
+}
@@ -0,0 +1,153 @@
+package barcode
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// GTINLength is the length of a normalized GTIN.
+const GTINLength = 14
+
+var (
+	ErrInvalidFormat   = errors.New("barcode must have 8, 12, 13 or 14 digits")
+	ErrInvalidChecksum = errors.New("barcode check digit is wrong")
+)
+
+// GTIN is a trade item number normalized to 14 digits, so the same product
+// scanned as UPC-A, UPC-E or EAN-13 has the same GTIN. Shorter codes are
+// padded with leading zeros, which doesn't change the check digit.
+type GTIN string
+
+// ParseGTIN parses an EAN-8, UPC-A, EAN-13 or GTIN-14 code. Spaces and dashes
+// between digit groups are ignored. 8 digits are read as EAN-8, or as UPC-E
+// when the check digit only fits the expanded UPC-E code.
+func ParseGTIN(code string) (GTIN, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(code))
+
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return "", ErrInvalidFormat
+		}
+	}
+
+	switch len(digits) {
+	case 8:
+		if !ValidChecksum(digits) && (digits[0] == '0' || digits[0] == '1') && ValidChecksum(ExpandUPCE(digits)) {
+			digits = ExpandUPCE(digits)
+		}
+	case 12, 13, GTINLength:
+	default:
+		return "", ErrInvalidFormat
+	}
+
+	if !ValidChecksum(digits) {
+		return "", ErrInvalidChecksum
+	}
+
+	return pad(digits), nil
+}
+
+// ResultGTIN normalizes a code read from an image.
+func ResultGTIN(r Result) (GTIN, error) {
+	digits := r.Digits
+	if r.Format == UPCE {
+		digits = ExpandUPCE(digits)
+	}
+
+	return ParseGTIN(digits)
+}
+
+// GTINFromInt64 restores a GTIN from Int64.
+func GTINFromInt64(code int64) (GTIN, error) {
+	if code <= 0 {
+		return "", ErrInvalidFormat
+	}
+
+	return ParseGTIN(string(pad(strconv.FormatInt(code, 10))))
+}
+
+func pad(digits string) GTIN {
+	if len(digits) >= GTINLength {
+		return GTIN(digits)
+	}
+
+	return GTIN(strings.Repeat("0", GTINLength-len(digits)) + digits)
+}
+
+// Valid reports whether g is a normalized GTIN with a correct check digit.
+func (g GTIN) Valid() bool {
+	return len(g) == GTINLength && ValidChecksum(string(g))
+}
+
+// Short returns the code the way it is printed under the bars: EAN-8, UPC-A
+// or EAN-13.
+func (g GTIN) Short() string {
+	s := string(g)
+	switch {
+	case len(s) != GTINLength || s[0] != '0':
+		return s
+	case strings.HasPrefix(s, "000000"):
+		return s[6:]
+	case strings.HasPrefix(s, "00"):
+		return s[2:]
+	default:
+		return s[1:]
+	}
+}
+
+// Int64 packs the GTIN into a number, e.g. for callback data. The leading
+// zeros are restored by GTINFromInt64.
+func (g GTIN) Int64() int64 {
+	n, _ := strconv.ParseInt(string(g), 10, 64)
+
+	return n
+}
+
+// UnmarshalJSON accepts the code as a string in any of the formats ParseGTIN
+// does, or as a number like the one returned by Int64. Codes that can't be
+// parsed are kept as they are and fail Valid, so they are reported by
+// validation together with the other fields.
+func (g *GTIN) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] != '"' {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		// numbers are not read as strings, a minus is not a separator there
+		*g = GTIN(n.String())
+		if code, err := n.Int64(); err == nil {
+			if parsed, err := GTINFromInt64(code); err == nil {
+				*g = parsed
+			}
+		}
+		return nil
+	}
+
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	parsed, err := ParseGTIN(raw)
+	if err != nil {
+		*g = GTIN(raw)
+		return nil
+	}
+
+	*g = parsed
+
+	return nil
+}
+
+// Value stores the GTIN as a string, see driver.Valuer.
+func (g GTIN) Value() (driver.Value, error) {
+	return string(g), nil
+}
@@ -0,0 +1,156 @@
+package barcode_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/validation"
+)
+
+func TestValidChecksum(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   bool
+	}{
+		{"96385074", true},
+		{"96385075", false},
+		{"036000291452", true},
+		{"036000291453", false},
+		{"4006381333931", true},
+		{"4006381333938", false},
+		{"10012345678902", true},
+		{"10012345678901", false},
+		{"4006381333a31", false},
+		{"0", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := barcode.ValidChecksum(tt.digits); got != tt.want {
+			t.Errorf("ValidChecksum(%q) = %v, want %v", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestExpandUPCE(t *testing.T) {
+	tests := []struct {
+		upce string
+		want string
+	}{
+		{"01234505", "012000003455"},
+		{"01234514", "012100003454"},
+		{"01234523", "012200003453"},
+		{"01234531", "012300000451"},
+		{"01234543", "012340000053"},
+		{"01234558", "012345000058"},
+		{"01234596", "012345000096"},
+		{"11234593", "112345000093"},
+		{"04252614", "042100005264"},
+		{"0123456", ""},
+	}
+
+	for _, tt := range tests {
+		got := barcode.ExpandUPCE(tt.upce)
+		if got != tt.want {
+			t.Errorf("ExpandUPCE(%q) = %q, want %q", tt.upce, got, tt.want)
+		}
+		if got != "" && !barcode.ValidChecksum(got) {
+			t.Errorf("ExpandUPCE(%q) = %q has a wrong check digit", tt.upce, got)
+		}
+	}
+}
+
+func TestParseGTIN(t *testing.T) {
+	tests := []struct {
+		code  string
+		want  barcode.GTIN
+		short string
+		err   error
+	}{
+		{code: "96385074", want: "00000096385074", short: "96385074"},
+		{code: "00123457", want: "00000000123457", short: "00123457"},
+		{code: "036000291452", want: "00036000291452", short: "036000291452"},
+		{code: "012345678905", want: "00012345678905", short: "012345678905"},
+		{code: "4006381333931", want: "04006381333931", short: "4006381333931"},
+		{code: "0036000291452", want: "00036000291452", short: "036000291452"},
+		{code: "10012345678902", want: "10012345678902", short: "10012345678902"},
+		{code: " 4006381-333931 ", want: "04006381333931", short: "4006381333931"},
+		{code: "01234505", want: "00012000003455", short: "012000003455"},
+		{code: "04252614", want: "00042100005264", short: "042100005264"},
+		{code: "01234565", want: "00000001234565", short: "01234565"},
+		{code: "4006381333932", err: barcode.ErrInvalidChecksum},
+		{code: "96385075", err: barcode.ErrInvalidChecksum},
+		{code: "400638133393", err: barcode.ErrInvalidChecksum},
+		{code: "1234567", err: barcode.ErrInvalidFormat},
+		{code: "400638133393x", err: barcode.ErrInvalidFormat},
+		{code: "", err: barcode.ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		got, err := barcode.ParseGTIN(tt.code)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseGTIN(%q) error = %v, want %v", tt.code, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseGTIN(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+		if tt.err != nil {
+			continue
+		}
+		if short := got.Short(); short != tt.short {
+			t.Errorf("ParseGTIN(%q).Short() = %q, want %q", tt.code, short, tt.short)
+		}
+		if back, err := barcode.GTINFromInt64(got.Int64()); err != nil || back != got {
+			t.Errorf("GTINFromInt64(%d) = %q, %v, want %q", got.Int64(), back, err, got)
+		}
+	}
+}
+
+func TestGTINJSONValidation(t *testing.T) {
+	tests := []struct {
+		body  string
+		want  barcode.GTIN
+		valid bool
+	}{
+		{body: `{"upcean": "4006381333931"}`, want: "04006381333931", valid: true},
+		{body: `{"upcean": 4006381333931}`, want: "04006381333931", valid: true},
+		{body: `{"upcean": "0 36000 29145 2"}`, want: "00036000291452", valid: true},
+		{body: `{"upcean": 36000291452}`, want: "00036000291452", valid: true},
+		{body: `{"upcean": "4006381333932"}`},
+		{body: `{"upcean": 4006381333932}`},
+		{body: `{"upcean": "barcode"}`},
+		{body: `{"upcean": ""}`},
+		{body: `{"upcean": 1.5}`},
+		{body: `{"upcean": -96385074}`},
+		{body: `{}`},
+		{body: `{"upcean": true}`},
+		{body: `{"upcean": ["4006381333931"]}`},
+	}
+
+	for _, tt := range tests {
+		var req struct {
+			Upcean barcode.GTIN `json:"upcean"`
+		}
+
+		err := validation.DecodeJSON(strings.NewReader(tt.body), &req)
+		if err == nil {
+			err = validation.Validate(validation.Barcode("upcean", req.Upcean))
+		}
+
+		if tt.valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.body, err)
+			} else if req.Upcean != tt.want {
+				t.Errorf("%s: decoded %q, want %q", tt.body, req.Upcean, tt.want)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s: decoded %q without an error", tt.body, req.Upcean)
+		}
+	}
+}
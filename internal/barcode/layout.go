@@ -0,0 +1,159 @@
+package barcode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	MeasureWeight = "weight"
+	MeasurePrice  = "price"
+)
+
+// Span is a part of an EAN-13 code, Start is the index of its first digit.
+type Span struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// Layout describes a variable measure EAN-13 code stores print on loose goods.
+// Such codes start with 20-29 and carry the item code of the store next to the
+// weight or the price of the package.
+type Layout struct {
+	Name     string   `json:"name"`
+	Prefixes []string `json:"prefixes"`
+	Item     Span     `json:"item"`
+	Value    Span     `json:"value"`
+	// Measure is MeasureWeight, the value is in kilograms then, or MeasurePrice.
+	Measure string `json:"measure"`
+	// Decimals is how many of the value digits are after the decimal point,
+	// e.g. 3 for a weight in grams.
+	Decimals int `json:"decimals"`
+}
+
+// Layouts are tried in order, the first one with a matching prefix is used.
+type Layouts []Layout
+
+// DefaultLayouts is the most common layout: a five digit item code after the
+// prefix and the weight in grams.
+var DefaultLayouts = Layouts{
+	{
+		Name:     "weight in grams",
+		Prefixes: []string{"20", "21", "22", "23", "24", "25", "26", "27", "28", "29"},
+		Item:     Span{Start: 2, Length: 5},
+		Value:    Span{Start: 7, Length: 5},
+		Measure:  MeasureWeight,
+		Decimals: 3,
+	},
+}
+
+// Measured is a variable measure code split into the item and its measure.
+type Measured struct {
+	// Item is the code with everything but the prefix and the item code set to
+	// zeros. Products sold by weight or price are stored in the catalog with it.
+	Item    GTIN    `json:"item"`
+	Measure string  `json:"measure"`
+	Value   float64 `json:"value"`
+}
+
+// LoadLayouts reads layouts from a json file with an array of Layout.
+func LoadLayouts(path string) (Layouts, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var layouts Layouts
+	if err = json.Unmarshal(b, &layouts); err != nil {
+		return nil, fmt.Errorf("barcode layouts %s: %w", path, err)
+	}
+
+	for _, l := range layouts {
+		if err = l.validate(); err != nil {
+			return nil, fmt.Errorf("barcode layouts %s: %w", path, err)
+		}
+	}
+
+	return layouts, nil
+}
+
+func (l Layout) validate() error {
+	if l.Measure != MeasureWeight && l.Measure != MeasurePrice {
+		return fmt.Errorf("layout %q: unknown measure %q", l.Name, l.Measure)
+	}
+	if len(l.Prefixes) == 0 {
+		return fmt.Errorf("layout %q: no prefixes", l.Name)
+	}
+
+	prefixLength := 0
+	for _, p := range l.Prefixes {
+		if _, err := strconv.Atoi(p); err != nil || p[0] != '2' {
+			return fmt.Errorf("layout %q: prefix %q is not a restricted circulation prefix", l.Name, p)
+		}
+		if len(p) > prefixLength {
+			prefixLength = len(p)
+		}
+	}
+
+	for _, s := range []Span{l.Item, l.Value} {
+		// the last digit is the check digit
+		if s.Length <= 0 || s.Start < prefixLength || s.Start+s.Length > 12 {
+			return fmt.Errorf("layout %q: span %+v is out of the code", l.Name, s)
+		}
+	}
+	if l.Item.Start < l.Value.Start+l.Value.Length && l.Value.Start < l.Item.Start+l.Item.Length {
+		return fmt.Errorf("layout %q: item and value overlap", l.Name)
+	}
+	if l.Decimals < 0 || l.Decimals > l.Value.Length {
+		return fmt.Errorf("layout %q: wrong number of decimals %d", l.Name, l.Decimals)
+	}
+
+	return nil
+}
+
+// Match splits a variable measure code with the first layout that has its
+// prefix. Other codes don't match.
+func (ls Layouts) Match(code GTIN) (Measured, bool) {
+	if !code.Valid() || code[0] != '0' {
+		return Measured{}, false
+	}
+	ean := string(code[1:])
+
+	for _, l := range ls {
+		for _, p := range l.Prefixes {
+			if strings.HasPrefix(ean, p) {
+				return l.split(ean, p), true
+			}
+		}
+	}
+
+	return Measured{}, false
+}
+
+func (l Layout) split(ean string, prefix string) Measured {
+	item := []byte(strings.Repeat("0", 12))
+	copy(item, prefix)
+	copy(item[l.Item.Start:], ean[l.Item.Start:l.Item.Start+l.Item.Length])
+
+	value, _ := strconv.ParseInt(ean[l.Value.Start:l.Value.Start+l.Value.Length], 10, 64)
+
+	return Measured{
+		Item:    pad(string(item) + string(CheckDigit(string(item)))),
+		Measure: l.Measure,
+		Value:   float64(value) / math.Pow10(l.Decimals),
+	}
+}
+
+// LoadLayoutsOrDefault loads the layouts from path, or returns DefaultLayouts
+// when path is empty.
+func LoadLayoutsOrDefault(path string) (Layouts, error) {
+	if path == "" {
+		return DefaultLayouts, nil
+	}
+
+	return LoadLayouts(path)
+}
@@ -0,0 +1,109 @@
+package barcode
+
+import (
+	"strings"
+	"testing"
+)
+
+// ean13 completes 12 digits with the check digit and normalizes the code.
+func ean13(body string) GTIN {
+	return pad(body + string(CheckDigit(body)))
+}
+
+func TestLayoutValidate(t *testing.T) {
+	valid := DefaultLayouts[0]
+
+	tests := []struct {
+		name   string
+		change func(l *Layout)
+		err    string
+	}{
+		{name: "default", change: func(l *Layout) {}},
+		{name: "price", change: func(l *Layout) { l.Measure, l.Decimals = MeasurePrice, 2 }},
+		{name: "unknown measure", change: func(l *Layout) { l.Measure = "volume" }, err: "unknown measure"},
+		{name: "no prefixes", change: func(l *Layout) { l.Prefixes = nil }, err: "no prefixes"},
+		{name: "not restricted prefix", change: func(l *Layout) { l.Prefixes = []string{"46"} }, err: "not a restricted circulation prefix"},
+		{name: "not a number prefix", change: func(l *Layout) { l.Prefixes = []string{"2x"} }, err: "not a restricted circulation prefix"},
+		{name: "item over prefix", change: func(l *Layout) { l.Item.Start = 1 }, err: "out of the code"},
+		{name: "item over longer prefix", change: func(l *Layout) { l.Prefixes = []string{"20", "211"} }, err: "out of the code"},
+		{name: "value over check digit", change: func(l *Layout) { l.Value.Length = 6 }, err: "out of the code"},
+		{name: "empty value", change: func(l *Layout) { l.Value.Length = 0 }, err: "out of the code"},
+		{name: "overlap", change: func(l *Layout) { l.Value.Start = 6 }, err: "overlap"},
+		{name: "value inside item", change: func(l *Layout) { l.Item.Length, l.Value = 9, Span{Start: 4, Length: 2} }, err: "overlap"},
+		{name: "too many decimals", change: func(l *Layout) { l.Decimals = 6 }, err: "wrong number of decimals"},
+		{name: "negative decimals", change: func(l *Layout) { l.Decimals = -1 }, err: "wrong number of decimals"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := valid
+			l.Prefixes = append([]string(nil), valid.Prefixes...)
+			tt.change(&l)
+
+			err := l.validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("expected error %q, got nil", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("expected error %q, got %q", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestLayoutsMatch(t *testing.T) {
+	layouts := Layouts{
+		{
+			Name:     "price in kopecks",
+			Prefixes: []string{"22"},
+			Item:     Span{Start: 2, Length: 4},
+			Value:    Span{Start: 6, Length: 6},
+			Measure:  MeasurePrice,
+			Decimals: 2,
+		},
+		DefaultLayouts[0],
+	}
+
+	tests := []struct {
+		name string
+		code GTIN
+		ok   bool
+		want Measured
+	}{
+		{
+			name: "weight",
+			code: ean13("201234501234"),
+			ok:   true,
+			want: Measured{Item: ean13("201234500000"), Measure: MeasureWeight, Value: 1.234},
+		},
+		{
+			name: "weight with the last prefix",
+			code: ean13("290000700250"),
+			ok:   true,
+			want: Measured{Item: ean13("290000700000"), Measure: MeasureWeight, Value: 0.25},
+		},
+		{
+			name: "price from the first layout",
+			code: ean13("221234012999"),
+			ok:   true,
+			want: Measured{Item: ean13("221234000000"), Measure: MeasurePrice, Value: 129.99},
+		},
+		{name: "regular code", code: ean13("460123456789")},
+		{name: "wrong check digit", code: GTIN("02012345012340")},
+		{name: "GTIN-14", code: GTIN("1" + string(ean13("201234501234")[1:]))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := layouts.Match(tt.code)
+			if ok != tt.ok {
+				t.Fatalf("Match(%s) ok = %v, want %v", tt.code, ok, tt.ok)
+			}
+			if got != tt.want {
+				t.Fatalf("Match(%s) = %+v, want %+v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+
+	"bybarcode/internal/auth"
+	"bybarcode/internal/message"
+)
+
+func (ab AppBot) onApiKeysHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return ab.sendApiKeys(ctx, msg.Chat.ID)
+}
+
+// onNewApiKeyHandler handles /newkey <name> [scopes] [<days>d].
+func (ab AppBot) onNewApiKeyHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	name, scopes, expireAt, ok := parseApiKeyArguments(msg.CommandArguments(), time.Now())
+	if !ok {
+		_, err := ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ApiKeyUsageMessage()))
+		return err
+	}
+
+	key, err := ab.tokens.IssueAPIKey(msg.Chat.ID, name, scopes, expireAt)
+	if err != nil {
+		return err
+	}
+
+	key, err = ab.db.CreateApiKey(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ApiKeyCreatedMessage(key)))
+
+	return err
+}
+
+func (ab AppBot) onRevokeApiKeyCallback(cb *tgbotapi.CallbackQuery, args []int64) error {
+	if len(args) != 1 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+	chatId := cb.Message.Chat.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keys, err := ab.db.GetApiKeysByAccount(ctx, chatId)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if k.ID != args[0] {
+			continue
+		}
+
+		if err = ab.db.RevokeApiKey(ctx, chatId, k.ID); err != nil {
+			return err
+		}
+
+		_, err = ab.bot.Send(tgbotapi.NewMessage(chatId, message.ApiKeyRevokedMessage(k.Name)))
+
+		return err
+	}
+
+	return fmt.Errorf("account %d has no api key %d", chatId, args[0])
+}
+
+func (ab AppBot) sendApiKeys(ctx context.Context, chatId int64) error {
+	keys, err := ab.db.GetApiKeysByAccount(ctx, chatId)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(chatId, message.ApiKeysMessage(keys))
+	if keyboard := apiKeysKeyboard(keys); keyboard != nil {
+		response.ReplyMarkup = keyboard
+	}
+
+	_, err = ab.bot.Send(response)
+
+	return err
+}
+
+func apiKeysKeyboard(keys []auth.APIKey) *tgbotapi.InlineKeyboardMarkup {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, k := range keys {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ "+k.Name, callbackData(callbackRevokeApiKey, k.ID)),
+		))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return &keyboard
+}
+
+// parseApiKeyArguments splits "fridge lists:read lists:write 90d" into the
+// name, the scopes and the expiry. Words with a colon are scopes and a number
+// of days may come last.
+func parseApiKeyArguments(args string, now time.Time) (string, []string, *time.Time, bool) {
+	words := strings.Fields(args)
+
+	var expireAt *time.Time
+	if n := len(words); n > 0 {
+		if days, ok := strings.CutSuffix(words[n-1], "d"); ok {
+			if d, err := strconv.Atoi(days); err == nil && d > 0 {
+				t := now.Add(time.Duration(d) * 24 * time.Hour)
+				expireAt = &t
+				words = words[:n-1]
+			}
+		}
+	}
+
+	var name, scopes []string
+	for _, w := range words {
+		if strings.Contains(w, ":") {
+			scopes = append(scopes, w)
+			continue
+		}
+		name = append(name, w)
+	}
+
+	normalized, ok := auth.NormalizeScopes(scopes)
+	if !ok || len(name) == 0 || len(strings.Join(name, " ")) > 255 {
+		return "", nil, nil, false
+	}
+
+	return strings.Join(name, " "), normalized, expireAt, true
+}
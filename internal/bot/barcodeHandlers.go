@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/message"
+	"bybarcode/internal/products"
+)
+
+func (ab AppBot) onBarcodeHandler(msg *tgbotapi.Message) error {
+	text, quantity := splitQuantity(msg.Text)
+
+	code, err := barcode.ParseGTIN(text)
+	if err != nil {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.InvalidBarcodeMessage()))
+		return err
+	}
+
+	return ab.sendProductInfo(msg.Chat.ID, code, quantity)
+}
+
+// sendProductInfo sends the product with buttons which add it to the lists in
+// the given quantity.
+func (ab AppBot) sendProductInfo(chatId int64, code barcode.GTIN, quantity products.Quantity) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var info string
+	if quantity != products.OnePiece {
+		info = message.QuantityMessage(quantity)
+	}
+
+	// loose goods are in the catalog with their item code, the weight or the
+	// price is taken from the scanned code
+	if m, ok := ab.layouts.Match(code); ok {
+		code, quantity, info = m.Item, products.MeasuredQuantity(m), message.MeasureMessage(m)
+	}
+
+	p, err := ab.db.FindProductByBarcode(ctx, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		response := tgbotapi.NewMessage(chatId, message.ProductNotFoundMessage(code))
+		response.ReplyMarkup = submitProductKeyboard(code)
+
+		_, err = ab.bot.Send(response)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, chatId)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(chatId, message.ProductInfoMessage(p)+info)
+	if keyboard := addProductKeyboard(p, quantity, lists); keyboard != nil {
+		response.ReplyMarkup = keyboard
+	}
+
+	_, err = ab.bot.Send(response)
+
+	return err
+}
+
+// quantityPattern matches "x3", "3x", "2 kg", "500г" and the like.
+var quantityPattern = regexp.MustCompile(
+	`(?i)^(?:[xх×*]\s*(\d+(?:[.,]\d+)?)|(\d+(?:[.,]\d+)?)\s*[xх×*]|(\d+(?:[.,]\d+)?)\s*(kg|кг|ml|мл|pcs|шт|гр|g|г|l|л)\.?)$`,
+)
+
+// unitAliases maps the units users type to products.Units.
+var unitAliases = map[string]string{
+	"kg":  products.UnitKilogram,
+	"кг":  products.UnitKilogram,
+	"g":   products.UnitGram,
+	"г":   products.UnitGram,
+	"гр":  products.UnitGram,
+	"l":   products.UnitLitre,
+	"л":   products.UnitLitre,
+	"ml":  products.UnitMillilitre,
+	"мл":  products.UnitMillilitre,
+	"pcs": products.UnitPiece,
+	"шт":  products.UnitPiece,
+}
+
+// parseQuantity reads a quantity typed by a user.
+func parseQuantity(text string) (products.Quantity, bool) {
+	m := quantityPattern.FindStringSubmatch(strings.TrimSpace(text))
+	if m == nil {
+		return products.Quantity{}, false
+	}
+
+	number, unit := m[1]+m[2], ""
+	if m[3] != "" {
+		number, unit = m[3], unitAliases[strings.ToLower(m[4])]
+	}
+
+	amount, err := strconv.ParseFloat(strings.Replace(number, ",", ".", 1), 64)
+	if err != nil {
+		return products.Quantity{}, false
+	}
+
+	q := products.Quantity{Amount: amount, Unit: unit}
+	item := products.ListItem{Quantity: q}
+	if item.Validate() != nil {
+		return products.Quantity{}, false
+	}
+
+	return q, true
+}
+
+// splitQuantity cuts the quantity off the end of a text like
+// "4006381333931 x3" or "4006381333931 2 kg". Without it the quantity is one
+// piece.
+func splitQuantity(text string) (string, products.Quantity) {
+	fields := strings.Fields(text)
+	for n := 1; n <= 2 && n < len(fields); n++ {
+		if q, ok := parseQuantity(strings.Join(fields[len(fields)-n:], " ")); ok {
+			return strings.Join(fields[:len(fields)-n], " "), q
+		}
+	}
+
+	return text, products.OnePiece
+}
@@ -2,89 +2,226 @@ package bot
 
 import (
 	"context"
+	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/rs/zerolog"
 	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
 
+	"bybarcode/internal/auth"
+	"bybarcode/internal/barcode"
 	"bybarcode/internal/config"
 	"bybarcode/internal/db"
 	"bybarcode/internal/message"
+	"bybarcode/internal/products"
 )
 
 type AppBot struct {
-	bot    *tgbotapi.BotAPI
-	logger zerolog.Logger
-	cfg    *config.BotConfig
-	db     db.Connect
+	bot     *tgbotapi.BotAPI
+	apiUrl  *url.URL
+	logger  zerolog.Logger
+	cfg     *config.BotConfig
+	db      db.Connect
+	tokens  auth.TokenHasher
+	layouts barcode.Layouts
+	states  map[string]state
+	server  *http.Server
+	workers *workerPool
+	// polled is closed when the long polling loop has received the last update.
+	polled chan struct{}
 }
 
 func NewAppBot(logger zerolog.Logger, cfg *config.BotConfig) *AppBot {
+	if cfg.TokenHashKey == "" {
+		logger.Fatal().Msg("API_TOKEN_HASH_KEY is not set")
+	}
+
 	conn, err := db.NewConnect("pgx", cfg.DBDsn)
 	if err != nil {
 		logger.Fatal().Msg(err.Error())
 	}
 
-	botApi, err := tgbotapi.NewBotAPI(cfg.Token)
+	ab, err := newAppBot(logger, cfg, conn)
 	if err != nil {
 		logger.Fatal().Msg(err.Error())
 	}
 
-	return &AppBot{
-		bot:    botApi,
-		logger: logger,
-		cfg:    cfg,
-		db:     conn,
+	return ab
+}
+
+// newAppBot builds the bot on an open connection, tests pass a connection to
+// a fake driver here.
+func newAppBot(logger zerolog.Logger, cfg *config.BotConfig, conn db.Connect) (*AppBot, error) {
+	layouts, err := barcode.LoadLayoutsOrDefault(cfg.BarcodeLayoutsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	apiUrl, err := apiBaseUrl(cfg.TgApiUrl)
+	if err != nil {
+		return nil, err
 	}
+
+	botApi, err := tgbotapi.NewBotAPIWithAPIEndpoint(cfg.Token, methodEndpoint(apiUrl))
+	if err != nil {
+		return nil, err
+	}
+
+	ab := &AppBot{
+		bot:     botApi,
+		apiUrl:  apiUrl,
+		logger:  logger,
+		cfg:     cfg,
+		db:      conn,
+		tokens:  auth.NewTokenHasher(cfg.TokenHashKey),
+		layouts: layouts,
+		states:  newStates(),
+		workers: newWorkerPool(cfg.Workers, cfg.QueueSize, logger),
+		polled:  make(chan struct{}),
+	}
+	ab.server = &http.Server{
+		Addr:    cfg.WebhookAddress,
+		Handler: ab.WebhookHandler(),
+	}
+
+	return ab, nil
 }
 
 func (ab AppBot) Run() error {
+	ab.workers.start(ab.dispatch)
+
+	if ab.cfg.Mode == config.BotModeWebhook {
+		return ab.runWebhook()
+	}
+
+	defer close(ab.polled)
+
 	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	u.Timeout = ab.cfg.PollingTimeout
 
 	ab.logger.Info().Msg("Bot was started")
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	updates := ab.bot.GetUpdatesChan(u)
 
 	for upd := range updates {
-		if upd.Message == nil {
-			continue
-		}
-
-		chat := upd.Message.Chat
-		err := ab.db.CreateAccountIfNotExist(ctx, int(chat.ID), chat.UserName, chat.FirstName, chat.LastName)
-		if err != nil {
+		if err := ab.workers.push(upd); err != nil {
 			ab.logger.Error().Msg(err.Error())
-			continue
-		}
-
-		if upd.Message.IsCommand() {
-			switch upd.Message.Command() {
-			case "start":
-				ab.errorHandler(upd.Message, ab.onStartHandler(upd.Message))
-			case "open":
-				ab.errorHandler(upd.Message, ab.onOpenHandler(upd.Message))
-			}
 		}
 	}
 
 	return nil
 }
 
+func (ab AppBot) dispatch(upd tgbotapi.Update) {
+	switch {
+	case upd.CallbackQuery != nil && upd.CallbackQuery.Message != nil:
+		ab.handleCallback(upd.CallbackQuery)
+	case upd.Message != nil:
+		ab.handleMessage(upd.Message)
+	}
+}
+
+func (ab AppBot) handleMessage(msg *tgbotapi.Message) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chat := msg.Chat
+	err := ab.db.CreateAccountIfNotExist(ctx, int(chat.ID), chat.UserName, chat.FirstName, chat.LastName)
+	if err != nil {
+		ab.logger.Error().Msg(err.Error())
+		return
+	}
+
+	if msg.IsCommand() {
+		ab.errorHandler(msg, ab.onCommand(ctx, msg))
+		return
+	}
+
+	handled, err := ab.continueDialog(ctx, msg)
+	if handled {
+		ab.errorHandler(msg, err)
+		return
+	}
+
+	if imageFileId(msg) != "" {
+		ab.errorHandler(msg, ab.onPhotoHandler(msg))
+		return
+	}
+
+	if msg.Text != "" {
+		ab.errorHandler(msg, ab.onBarcodeHandler(msg))
+	}
+}
+
+func (ab AppBot) handleCallback(cb *tgbotapi.CallbackQuery) {
+	action, args := parseCallbackData(cb.Data)
+
+	var err error
+	switch action {
+	case callbackAddProduct:
+		err = ab.onAddProductCallback(cb, args)
+	case callbackShowList:
+		err = ab.onShowListCallback(cb, args)
+	case callbackToggleProduct:
+		err = ab.onToggleProductCallback(cb, args, false)
+	case callbackToggleItem:
+		err = ab.onToggleProductCallback(cb, args, true)
+	case callbackSubmitProduct:
+		err = ab.onSubmitProductCallback(cb, args)
+	case callbackApproveSubmission:
+		err = ab.onApproveSubmissionCallback(cb, args)
+	case callbackRejectSubmission:
+		err = ab.onRejectSubmissionCallback(cb, args)
+	case callbackEditSubmission:
+		err = ab.onEditSubmissionCallback(cb, args)
+	case callbackRemoveMember:
+		err = ab.onRemoveMemberCallback(cb, args)
+	case callbackTransferOwnership:
+		err = ab.onTransferOwnershipCallback(cb, args)
+	case callbackRevokeApiKey:
+		err = ab.onRevokeApiKeyCallback(cb, args)
+	}
+
+	if _, aErr := ab.bot.Request(tgbotapi.NewCallback(cb.ID, "")); aErr != nil {
+		ab.logger.Error().Msg(aErr.Error())
+	}
+
+	ab.errorHandler(cb.Message, err)
+}
+
+// Shutdown stops receiving updates and waits until the received ones are
+// handled. Long polling can take up to the polling timeout to return.
 func (ab AppBot) Shutdown() error {
-	if err := ab.db.Close(); err != nil {
-		return err
+	ctx, cancel := context.WithTimeout(context.Background(), ab.cfg.ShutdownTimeout)
+	defer cancel()
+
+	if ab.cfg.Mode == config.BotModeWebhook {
+		if err := ab.server.Shutdown(ctx); err != nil {
+			return err
+		}
+	} else {
+		ab.bot.StopReceivingUpdates()
+
+		select {
+		case <-ab.polled:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
-	ab.bot.StopReceivingUpdates()
+	if err := ab.workers.stop(ctx); err != nil {
+		return err
+	}
 
-	return nil
+	return ab.db.Close()
 }
 
 func (ab AppBot) onStartHandler(msg *tgbotapi.Message) error {
+	if code, ok := strings.CutPrefix(msg.CommandArguments(), products.ListInviteStartPrefix); ok {
+		return ab.onAcceptInvite(msg, code)
+	}
+
 	response := tgbotapi.NewMessage(msg.Chat.ID, message.OnStartMessage())
 
 	_, err := ab.bot.Send(response)
@@ -92,24 +229,14 @@ func (ab AppBot) onStartHandler(msg *tgbotapi.Message) error {
 	return err
 }
 
+// onOpenHandler opens the web app, which logs in with the init data Telegram
+// passes to it, so no token has to be put into the url.
 func (ab AppBot) onOpenHandler(msg *tgbotapi.Message) error {
 	webAppURL, err := url.Parse(ab.cfg.TgWebAppUrl)
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	session, err := ab.db.CreateSession(ctx, msg.Chat.ID)
-	if err != nil {
-		return err
-	}
-
-	values := url.Values{}
-	values.Set("token", session.Token.String())
-	webAppURL.RawQuery = values.Encode()
-
 	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonWebApp(
@@ -140,8 +267,7 @@ func (ab AppBot) errorHandler(msg *tgbotapi.Message, err error) {
 
 	ab.logger.Error().Msg(err.Error())
 
-	_, err = ab.bot.Send(response)
-	if err != nil {
-		ab.logger.Fatal().Msg(err.Error())
+	if _, err = ab.bot.Send(response); err != nil {
+		ab.logger.Error().Msg(err.Error())
 	}
 }
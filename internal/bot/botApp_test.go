@@ -0,0 +1,327 @@
+package bot
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+
+	"bybarcode/internal/bot/bottest"
+	"bybarcode/internal/config"
+	"bybarcode/internal/db/dbtest"
+	"bybarcode/internal/message"
+	"bybarcode/internal/products"
+)
+
+const (
+	testToken   = "123456:test-token"
+	testChatId  = 42
+	waitTimeout = 5 * time.Second
+
+	testProductId    = 7
+	testBarcode      = "04006381333931"
+	testProductName  = "Шоколад"
+	testCategoryId   = 1
+	testCategoryName = "Сладости"
+	testBrandId      = 2
+	testBrandName    = "Ritter Sport"
+	testListId       = 3
+	testListName     = "Продукты"
+)
+
+// Queries of db.Connect, matched by a part of their text.
+const (
+	queryCreateAccount     = "INSERT INTO account"
+	queryProductByBarcode  = "WHERE p.upcean = $1"
+	queryProductByIdOrCode = "WHERE id = $1 OR upcean = $2"
+	queryListsByAccount    = "WHERE m.account_id = $1"
+	queryListForAccount    = "WHERE sl.id = $1 AND m.account_id = $2"
+	queryListById          = "SELECT id, name, account_id FROM shopping_lists WHERE id = $1"
+	queryAddProductToList  = "INSERT INTO shopping_list__products AS slp"
+	queryStatisticOnAdding = "INSERT INTO shopping_list_statistics"
+	queryDeleteDialog      = "DELETE FROM bot_dialogs"
+)
+
+var testProduct = products.Product{
+	ID:         testProductId,
+	Name:       testProductName,
+	Upcean:     testBarcode,
+	CategoryId: testCategoryId,
+	BrandId:    testBrandId,
+	Category:   &products.Category{ID: testCategoryId, Name: testCategoryName},
+	Brand:      &products.Brand{ID: testBrandId, Name: testBrandName},
+}
+
+// startTestBot runs the bot against the fake Bot API and the fake database
+// until the test ends.
+func startTestBot(t *testing.T) (*bottest.Server, *dbtest.DB) {
+	t.Helper()
+
+	srv := bottest.NewServer(testToken)
+	t.Cleanup(srv.Close)
+
+	fdb, conn := dbtest.New(t)
+
+	cfg := &config.BotConfig{
+		Token:           testToken,
+		TgApiUrl:        srv.URL(),
+		DialogTimeout:   time.Minute,
+		Mode:            config.BotModePolling,
+		PollingTimeout:  1,
+		Workers:         2,
+		QueueSize:       10,
+		ShutdownTimeout: waitTimeout,
+	}
+
+	ab, err := newAppBot(zerolog.Nop(), cfg, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- ab.Run() }()
+
+	t.Cleanup(func() {
+		if err := ab.Shutdown(); err != nil {
+			t.Errorf("shutdown: %v", err)
+		}
+		if err := <-stopped; err != nil {
+			t.Errorf("run: %v", err)
+		}
+	})
+
+	return srv, fdb
+}
+
+func waitCall(t *testing.T, srv *bottest.Server, method string) bottest.Call {
+	t.Helper()
+
+	calls, err := srv.WaitCalls(method, 1, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return calls[0]
+}
+
+func productRow() []driver.Value {
+	return []driver.Value{
+		int64(testProductId), testProductName, testBarcode, int64(testCategoryId), int64(testBrandId),
+		int64(testCategoryId), testCategoryName, int64(testBrandId), testBrandName,
+	}
+}
+
+func listRow(role string) []driver.Value {
+	return []driver.Value{int64(testListId), testListName, int64(testChatId), role, nil, nil}
+}
+
+func keyboard(t *testing.T, c bottest.Call) tgbotapi.InlineKeyboardMarkup {
+	t.Helper()
+
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(c.Params.Get("reply_markup")), &markup); err != nil {
+		t.Fatalf("reply_markup %q: %v", c.Params.Get("reply_markup"), err)
+	}
+
+	return markup
+}
+
+func TestStartCommand(t *testing.T) {
+	srv, fdb := startTestBot(t)
+
+	srv.PushMessage(testChatId, "/start")
+
+	c := waitCall(t, srv, "sendMessage")
+	if c.Int64("chat_id") != testChatId {
+		t.Errorf("chat_id = %d, want %d", c.Int64("chat_id"), testChatId)
+	}
+	if text := c.Params.Get("text"); text != message.OnStartMessage() {
+		t.Errorf("text = %q, want the start message", text)
+	}
+
+	accounts := fdb.Executed(queryCreateAccount)
+	if len(accounts) != 1 || accounts[0][0] != int64(testChatId) || accounts[0][1] != "user42" {
+		t.Errorf("accounts created with %v, want one for chat %d", accounts, testChatId)
+	}
+}
+
+func TestCommandEndsDialog(t *testing.T) {
+	srv, fdb := startTestBot(t)
+	// the lists can't be read, so the command fails before it finishes
+	fdb.On(queryListsByAccount, []driver.Value{"broken"})
+
+	srv.PushMessage(testChatId, "/lists")
+
+	waitCall(t, srv, "sendMessage")
+	deleted := fdb.Executed(queryDeleteDialog)
+	if len(deleted) != 1 || deleted[0][0] != int64(testChatId) {
+		t.Errorf("dialogs deleted with %v, want the dialog of chat %d", deleted, testChatId)
+	}
+}
+
+func TestBarcodeLookup(t *testing.T) {
+	srv, fdb := startTestBot(t)
+	fdb.On(queryProductByBarcode, productRow())
+	fdb.On(queryListsByAccount, listRow(products.ListRoleOwner))
+
+	srv.PushMessage(testChatId, "4006381333931")
+
+	c := waitCall(t, srv, "sendMessage")
+	if text := c.Params.Get("text"); text != message.ProductInfoMessage(testProduct) {
+		t.Errorf("text = %q, want %q", text, message.ProductInfoMessage(testProduct))
+	}
+
+	want := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			"➕ "+testListName, callbackData(callbackAddProduct, testListId, testProductId),
+		)),
+	}
+	if got := keyboard(t, c).InlineKeyboard; !reflect.DeepEqual(got, want) {
+		t.Errorf("keyboard = %+v, want %+v", got, want)
+	}
+
+	lookups := fdb.Executed(queryProductByBarcode)
+	if len(lookups) != 1 || lookups[0][0] != testBarcode {
+		t.Errorf("products looked up by %v, want %s", lookups, testBarcode)
+	}
+}
+
+func TestBarcodeNotFound(t *testing.T) {
+	srv, _ := startTestBot(t)
+
+	srv.PushMessage(testChatId, "4006381333931")
+
+	c := waitCall(t, srv, "sendMessage")
+	if text := c.Params.Get("text"); text != message.ProductNotFoundMessage(testBarcode) {
+		t.Errorf("text = %q, want %q", text, message.ProductNotFoundMessage(testBarcode))
+	}
+}
+
+func TestAddProductCallback(t *testing.T) {
+	srv, fdb := startTestBot(t)
+	fdb.On(queryListForAccount, listRow(products.ListRoleOwner))
+	fdb.On(queryProductByIdOrCode, productRow()[:5])
+	fdb.On(queryListById, []driver.Value{int64(testListId), testListName, int64(testChatId)})
+	fdb.On(queryAddProductToList, []driver.Value{int64(11)})
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➕ "+testListName, callbackData(callbackAddProduct, testListId, testProductId)),
+	))
+	msg := &tgbotapi.Message{
+		MessageID:   100,
+		Chat:        &tgbotapi.Chat{ID: testChatId, Type: "private"},
+		Text:        "Шоколад Ritter Sport",
+		ReplyMarkup: &markup,
+	}
+	upd := srv.PushCallback(msg, callbackData(callbackAddProduct, testListId, testProductId))
+
+	edit := waitCall(t, srv, "editMessageText")
+	if edit.Int64("chat_id") != testChatId || edit.Int64("message_id") != 100 {
+		t.Errorf("edited message %d in chat %d, want 100 in %d", edit.Int64("message_id"), edit.Int64("chat_id"), testChatId)
+	}
+	if text, want := edit.Params.Get("text"), message.ProductAddedMessage(msg.Text, testListName); text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	if got := keyboard(t, edit); !reflect.DeepEqual(got, markup) {
+		t.Errorf("keyboard = %+v, want the one of the message", got)
+	}
+
+	answer := waitCall(t, srv, "answerCallbackQuery")
+	if id := answer.Params.Get("callback_query_id"); id != upd.CallbackQuery.ID {
+		t.Errorf("answered callback %q, want %q", id, upd.CallbackQuery.ID)
+	}
+
+	added := fdb.Executed(queryAddProductToList)
+	if len(added) != 1 || added[0][0] != int64(testListId) || added[0][1] != int64(testProductId) {
+		t.Errorf("products added with %v, want product %d to list %d", added, testProductId, testListId)
+	}
+	if stats := fdb.Executed(queryStatisticOnAdding); len(stats) != 1 {
+		t.Errorf("statistics updated %d times, want once", len(stats))
+	}
+}
+
+func TestAddProductCallbackReadOnlyList(t *testing.T) {
+	srv, fdb := startTestBot(t)
+	fdb.On(queryListForAccount, listRow(products.ListRoleViewer))
+
+	msg := &tgbotapi.Message{MessageID: 100, Chat: &tgbotapi.Chat{ID: testChatId}, Text: "Шоколад"}
+	srv.PushCallback(msg, callbackData(callbackAddProduct, testListId, testProductId))
+
+	edit := waitCall(t, srv, "editMessageText")
+	want := message.ProductNotAddedMessage(msg.Text, errors.New(message.ListReadOnlyMessage(testListName)))
+	if text := edit.Params.Get("text"); text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	if added := fdb.Executed(queryAddProductToList); len(added) != 0 {
+		t.Errorf("products added to a read-only list: %v", added)
+	}
+}
+
+func TestAddProductCallbackInOtherUnit(t *testing.T) {
+	srv, fdb := startTestBot(t)
+	fdb.On(queryListForAccount, listRow(products.ListRoleOwner))
+	fdb.On(queryProductByIdOrCode, productRow()[:5])
+	fdb.On(queryListById, []driver.Value{int64(testListId), testListName, int64(testChatId)})
+
+	msg := &tgbotapi.Message{MessageID: 100, Chat: &tgbotapi.Chat{ID: testChatId}, Text: "Шоколад"}
+	srv.PushCallback(msg, callbackData(callbackAddProduct, testListId, testProductId))
+
+	edit := waitCall(t, srv, "editMessageText")
+	want := message.ProductNotAddedMessage(msg.Text, errors.New(message.ProductInOtherUnitMessage(testListName)))
+	if text := edit.Params.Get("text"); text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	if stats := fdb.Executed(queryStatisticOnAdding); len(stats) != 0 {
+		t.Errorf("statistics updated %d times for a product which was not added", len(stats))
+	}
+}
+
+func TestPhotoBarcode(t *testing.T) {
+	srv, fdb := startTestBot(t)
+	fdb.On(queryProductByBarcode, productRow())
+
+	photo, err := os.ReadFile("testdata/ean13.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.AddFile("photo1", photo)
+
+	srv.PushPhoto(testChatId, "photo1", "x2")
+
+	file := waitCall(t, srv, "getFile")
+	if id := file.Params.Get("file_id"); id != "photo1" {
+		t.Errorf("file_id = %q, want photo1", id)
+	}
+
+	c := waitCall(t, srv, "sendMessage")
+	want := message.ProductInfoMessage(testProduct) + message.QuantityMessage(products.Quantity{Amount: 2})
+	if text := c.Params.Get("text"); text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+
+	lookups := fdb.Executed(queryProductByBarcode)
+	if len(lookups) != 1 || lookups[0][0] != testBarcode {
+		t.Errorf("products looked up by %v, want %s", lookups, testBarcode)
+	}
+}
+
+func TestPhotoWithoutBarcode(t *testing.T) {
+	srv, fdb := startTestBot(t)
+
+	srv.AddFile("photo1", []byte("not an image"))
+	srv.PushPhoto(testChatId, "photo1", "")
+
+	c := waitCall(t, srv, "sendMessage")
+	if text := c.Params.Get("text"); text != message.BarcodeNotRecognizedMessage() {
+		t.Errorf("text = %q, want %q", text, message.BarcodeNotRecognizedMessage())
+	}
+	if lookups := fdb.Executed(queryProductByBarcode); len(lookups) != 0 {
+		t.Errorf("products looked up by %v without a barcode", lookups)
+	}
+}
@@ -0,0 +1,362 @@
+// Package bottest provides an in-process fake of the Telegram Bot API, so the
+// bot can be run against it without network access.
+package bottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+)
+
+// maxPollingWait caps long polling, so tests don't hang on getUpdates.
+const maxPollingWait = time.Second
+
+// Call is a recorded request made by the bot.
+type Call struct {
+	Method string
+	Params url.Values
+}
+
+// Int64 returns a numeric parameter of the call, e.g. chat_id.
+func (c Call) Int64(name string) int64 {
+	v, _ := strconv.ParseInt(c.Params.Get(name), 10, 64)
+	return v
+}
+
+// Server implements getMe, getUpdates, sendMessage, editMessageText,
+// answerCallbackQuery, getFile, setWebhook and file downloads.
+type Server struct {
+	server *httptest.Server
+	token  string
+	bot    tgbotapi.User
+
+	mu            sync.Mutex
+	updates       []tgbotapi.Update
+	nextUpdateId  int
+	nextMessageId int
+	calls         []Call
+	files         map[string][]byte
+	// changed is closed and replaced every time updates or calls change.
+	changed chan struct{}
+}
+
+func NewServer(token string) *Server {
+	s := &Server{
+		token: token,
+		bot: tgbotapi.User{
+			ID:        1,
+			IsBot:     true,
+			FirstName: "Bybarcode",
+			UserName:  "bybarcode_test_bot",
+		},
+		nextUpdateId:  1,
+		nextMessageId: 1,
+		files:         map[string][]byte{},
+		changed:       make(chan struct{}),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// URL is the value for TELEGRAM_API_URL of the bot under test.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// PushUpdate queues an update for getUpdates and returns it with the assigned
+// update id.
+func (s *Server) PushUpdate(upd tgbotapi.Update) tgbotapi.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upd.UpdateID = s.nextUpdateId
+	s.nextUpdateId++
+	s.updates = append(s.updates, upd)
+	s.notify()
+
+	return upd
+}
+
+// PushMessage queues a text message from a private chat. Commands get the
+// bot_command entity like real clients send.
+func (s *Server) PushMessage(chatId int64, text string) tgbotapi.Update {
+	msg := s.newMessage(chatId)
+	msg.Text = text
+
+	if strings.HasPrefix(text, "/") {
+		length := len(text)
+		if i := strings.Index(text, " "); i > 0 {
+			length = i
+		}
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+
+	return s.PushUpdate(tgbotapi.Update{Message: msg})
+}
+
+// PushPhoto queues a photo with the caption. The file has to be added with
+// AddFile to be downloaded by the bot.
+func (s *Server) PushPhoto(chatId int64, fileId string, caption string) tgbotapi.Update {
+	msg := s.newMessage(chatId)
+	msg.Caption = caption
+	msg.Photo = []tgbotapi.PhotoSize{{FileID: fileId, FileUniqueID: fileId, Width: 640, Height: 480}}
+
+	return s.PushUpdate(tgbotapi.Update{Message: msg})
+}
+
+func (s *Server) newMessage(chatId int64) *tgbotapi.Message {
+	chat := &tgbotapi.Chat{ID: chatId, Type: "private", UserName: fmt.Sprintf("user%d", chatId)}
+
+	return &tgbotapi.Message{
+		MessageID: s.messageId(),
+		From:      &tgbotapi.User{ID: chatId, UserName: chat.UserName},
+		Chat:      chat,
+		Date:      int(time.Now().Unix()),
+	}
+}
+
+// PushCallback queues a press on an inline button of the message.
+func (s *Server) PushCallback(msg *tgbotapi.Message, data string) tgbotapi.Update {
+	cb := &tgbotapi.CallbackQuery{
+		ID:      fmt.Sprintf("callback%d", s.messageId()),
+		From:    &tgbotapi.User{ID: msg.Chat.ID, UserName: msg.Chat.UserName},
+		Message: msg,
+		Data:    data,
+	}
+
+	return s.PushUpdate(tgbotapi.Update{CallbackQuery: cb})
+}
+
+// AddFile makes the content downloadable through getFile with the file id.
+func (s *Server) AddFile(fileId string, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[fileId] = content
+}
+
+// Calls returns recorded requests of the method, or all requests for an empty
+// method.
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.filterCalls(method)
+}
+
+// WaitCalls waits until the bot made at least n requests of the method.
+func (s *Server) WaitCalls(method string, n int, timeout time.Duration) ([]Call, error) {
+	deadline := time.After(timeout)
+
+	for {
+		s.mu.Lock()
+		calls := s.filterCalls(method)
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(calls) >= n {
+			return calls, nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return calls, fmt.Errorf("got %d %s calls, want %d", len(calls), method, n)
+		}
+	}
+}
+
+func (s *Server) filterCalls(method string) []Call {
+	var calls []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+func (s *Server) messageId() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextMessageId
+	s.nextMessageId++
+
+	return id
+}
+
+// notify wakes up long polling and WaitCalls. Must be called with mu held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if path, ok := strings.CutPrefix(r.URL.Path, "/file/bot"+s.token+"/"); ok {
+		s.serveFile(w, path)
+		return
+	}
+
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot"+s.token+"/")
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: method, Params: r.PostForm})
+	s.notify()
+	s.mu.Unlock()
+
+	switch method {
+	case "getMe":
+		writeResult(w, s.bot)
+	case "getUpdates":
+		s.getUpdates(w, r)
+	case "sendMessage", "editMessageText":
+		s.sendMessage(w, r, method)
+	case "answerCallbackQuery", "setWebhook", "deleteWebhook":
+		writeResult(w, true)
+	case "getFile":
+		s.getFile(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found: method not found")
+	}
+}
+
+func (s *Server) getUpdates(w http.ResponseWriter, r *http.Request) {
+	offset, _ := strconv.Atoi(r.PostForm.Get("offset"))
+	timeout, _ := strconv.Atoi(r.PostForm.Get("timeout"))
+
+	wait := time.Duration(timeout) * time.Second
+	if wait > maxPollingWait {
+		wait = maxPollingWait
+	}
+	deadline := time.After(wait)
+
+	for {
+		s.mu.Lock()
+		var updates []tgbotapi.Update
+		for _, u := range s.updates {
+			if u.UpdateID >= offset {
+				updates = append(updates, u)
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(updates) > 0 {
+			writeResult(w, updates)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			writeResult(w, []tgbotapi.Update{})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request, method string) {
+	chatId, err := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: chat not found")
+		return
+	}
+
+	msg := tgbotapi.Message{
+		MessageID: s.messageId(),
+		Chat:      &tgbotapi.Chat{ID: chatId, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      r.PostForm.Get("text"),
+	}
+
+	if method == "editMessageText" {
+		msg.MessageID, _ = strconv.Atoi(r.PostForm.Get("message_id"))
+	}
+
+	if markup := r.PostForm.Get("reply_markup"); markup != "" {
+		var keyboard tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal([]byte(markup), &keyboard); err == nil && keyboard.InlineKeyboard != nil {
+			msg.ReplyMarkup = &keyboard
+		}
+	}
+
+	writeResult(w, msg)
+}
+
+func (s *Server) getFile(w http.ResponseWriter, r *http.Request) {
+	fileId := r.PostForm.Get("file_id")
+
+	s.mu.Lock()
+	content, ok := s.files[fileId]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad Request: invalid file_id")
+		return
+	}
+
+	writeResult(w, tgbotapi.File{
+		FileID:   fileId,
+		FileSize: len(content),
+		FilePath: "files/" + fileId,
+	})
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, path string) {
+	s.mu.Lock()
+	content, ok := s.files[strings.TrimPrefix(path, "files/")]
+	s.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	_, _ = w.Write(content)
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	b, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeResponse(w, http.StatusOK, tgbotapi.APIResponse{Ok: true, Result: b})
+}
+
+func writeError(w http.ResponseWriter, status int, description string) {
+	writeResponse(w, status, tgbotapi.APIResponse{Ok: false, ErrorCode: status, Description: description})
+}
+
+func writeResponse(w http.ResponseWriter, status int, response tgbotapi.APIResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+
+	"bybarcode/internal/db"
+	"bybarcode/internal/message"
+	"bybarcode/internal/products"
+)
+
+const (
+	callbackAddProduct    = "add"
+	callbackShowList      = "show"
+	callbackToggleProduct = "toggle"
+	callbackToggleItem    = "titem"
+
+	callbackRemoveMember      = "rmmember"
+	callbackTransferOwnership = "mkowner"
+
+	callbackRevokeApiKey = "rmkey"
+
+	callbackSubmitProduct     = "submit"
+	callbackApproveSubmission = "approve"
+	callbackRejectSubmission  = "reject"
+	callbackEditSubmission    = "editsub"
+)
+
+// callbackData joins an action with its arguments. Telegram limits callback
+// data to 64 bytes, so only ids are passed around.
+func callbackData(action string, args ...int64) string {
+	parts := []string{action}
+	for _, a := range args {
+		parts = append(parts, strconv.FormatInt(a, 10))
+	}
+
+	return strings.Join(parts, ":")
+}
+
+func parseCallbackData(data string) (string, []int64) {
+	parts := strings.Split(data, ":")
+
+	args := make([]int64, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		a, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return "", nil
+		}
+		args = append(args, a)
+	}
+
+	return parts[0], args
+}
+
+// addProductKeyboard passes a quantity other than one piece in the callback
+// data as thousandths and the index of the unit in products.Units.
+func addProductKeyboard(p products.Product, q products.Quantity, lists []products.ShoppingList) *tgbotapi.InlineKeyboardMarkup {
+	if len(lists) == 0 {
+		return nil
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(lists))
+	for _, sl := range lists {
+		if !sl.CanEdit() {
+			continue
+		}
+
+		data := callbackData(callbackAddProduct, sl.ID, p.ID)
+		if q != products.OnePiece {
+			data = callbackData(callbackAddProduct, sl.ID, p.ID, int64(math.Round(q.Amount*1000)), unitIndex(q.Unit))
+		}
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("➕ %s", sl.Name), data),
+		))
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return &keyboard
+}
+
+func unitIndex(unit string) int64 {
+	for i, u := range products.Units {
+		if u == unit {
+			return int64(i)
+		}
+	}
+
+	return 0
+}
+
+func (ab AppBot) onAddProductCallback(cb *tgbotapi.CallbackQuery, args []int64) error {
+	if len(args) != 2 && len(args) != 4 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+	slId, pId := args[0], args[1]
+	chatId := cb.Message.Chat.ID
+
+	item := products.ListItem{Quantity: products.OnePiece}
+	if len(args) == 4 {
+		if args[3] < 0 || args[3] >= int64(len(products.Units)) {
+			return fmt.Errorf("unexpected callback data %q", cb.Data)
+		}
+		item.Quantity = products.Quantity{Amount: float64(args[2]) / 1000, Unit: products.Units[args[3]]}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sl, err := ab.findAccountList(ctx, chatId, slId)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	if !sl.CanEdit() {
+		text = message.ProductNotAddedMessage(cb.Message.Text, errors.New(message.ListReadOnlyMessage(sl.Name)))
+	} else if err = ab.db.AddProductToShoppingListByIds(ctx, pId, sl.ID, item); errors.Is(err, db.ErrConflict) {
+		text = message.ProductNotAddedMessage(cb.Message.Text, errors.New(message.ProductInOtherUnitMessage(sl.Name)))
+	} else if errors.Is(err, db.ErrValidation) {
+		text = message.ProductNotAddedMessage(cb.Message.Text, err)
+	} else if err != nil {
+		return err
+	} else {
+		text = message.ProductAddedMessage(cb.Message.Text, sl.Name)
+		if err = ab.db.AddedUpdStatisticByShoppingList(ctx, sl.ID); err != nil {
+			ab.logger.Error().Msg(err.Error())
+		}
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatId, cb.Message.MessageID, text)
+	edit.ReplyMarkup = cb.Message.ReplyMarkup
+
+	_, err = ab.bot.Send(edit)
+
+	return err
+}
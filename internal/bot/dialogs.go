@@ -0,0 +1,236 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+
+	"bybarcode/internal/dialog"
+	"bybarcode/internal/message"
+)
+
+const (
+	stateStart      = "start"
+	stateOpen       = "open"
+	stateLists      = "lists"
+	stateNewList    = "new"
+	stateNewListAsk = "new_list_name"
+	stateRenameList = "rename"
+	stateDeleteList = "delete"
+	stateRestore    = "restore"
+	stateArchive    = "archive"
+	stateUnarchive  = "unarchive"
+	stateCopyList   = "copy"
+	stateCopyRest   = "copyrest"
+	stateMergeLists = "merge"
+	stateSplitRest  = "splitrest"
+	stateShowList   = "show"
+	stateAddItem    = "add"
+	stateMuteList   = "mute"
+	stateUnmuteList = "unmute"
+	stateInvite     = "invite"
+	stateMembers    = "members"
+	stateLeaveList  = "leave"
+	stateApiKeys    = "keys"
+	stateNewApiKey  = "newkey"
+
+	stateSubmissions    = "submissions"
+	stateSubmitName     = "submit_name"
+	stateSubmitBrand    = "submit_brand"
+	stateSubmitCategory = "submit_category"
+)
+
+// stateHandler processes a message received in a state and returns the next
+// state of the dialog. An empty state finishes the dialog.
+type stateHandler func(ab AppBot, msg *tgbotapi.Message, d *dialog.Dialog) (string, error)
+
+type state struct {
+	// prompt is sent when the dialog moves to the state, e.g. to ask a question.
+	prompt func(ab AppBot, chatId int64, d *dialog.Dialog) error
+	handle stateHandler
+}
+
+// commands maps bot commands to the states they start a dialog with.
+var commands = map[string]string{
+	"start":  stateStart,
+	"open":   stateOpen,
+	"lists":  stateLists,
+	"new":    stateNewList,
+	"rename": stateRenameList,
+	"delete": stateDeleteList,
+	"show":   stateShowList,
+	"add":    stateAddItem,
+	"mute":   stateMuteList,
+	"unmute": stateUnmuteList,
+
+	"archive":   stateArchive,
+	"unarchive": stateUnarchive,
+	"restore":   stateRestore,
+
+	"copy":      stateCopyList,
+	"copyrest":  stateCopyRest,
+	"merge":     stateMergeLists,
+	"splitrest": stateSplitRest,
+
+	"invite":  stateInvite,
+	"members": stateMembers,
+	"leave":   stateLeaveList,
+
+	"keys":   stateApiKeys,
+	"newkey": stateNewApiKey,
+
+	"submissions": stateSubmissions,
+}
+
+func newStates() map[string]state {
+	return map[string]state{
+		stateStart:      {handle: finish(AppBot.onStartHandler)},
+		stateOpen:       {handle: finish(AppBot.onOpenHandler)},
+		stateLists:      {handle: finish(AppBot.onListsHandler)},
+		stateNewList:    {handle: AppBot.onNewListHandler},
+		stateNewListAsk: {prompt: ask(message.AskListNameMessage()), handle: AppBot.onListNameHandler},
+		stateRenameList: {handle: finish(AppBot.onRenameListHandler)},
+		stateDeleteList: {handle: finish(AppBot.onDeleteListHandler)},
+		stateRestore:    {handle: finish(AppBot.onRestoreListHandler)},
+		stateArchive:    {handle: finish(AppBot.onArchiveListHandler)},
+		stateUnarchive:  {handle: finish(AppBot.onUnarchiveListHandler)},
+		stateCopyList:   {handle: finish(AppBot.onCopyListHandler)},
+		stateCopyRest:   {handle: finish(AppBot.onCopyRestHandler)},
+		stateMergeLists: {handle: finish(AppBot.onMergeListsHandler)},
+		stateSplitRest:  {handle: finish(AppBot.onSplitRestHandler)},
+		stateShowList:   {handle: finish(AppBot.onShowListHandler)},
+		stateAddItem:    {handle: finish(AppBot.onAddItemHandler)},
+		stateMuteList:   {handle: finish(AppBot.onMuteListHandler)},
+		stateUnmuteList: {handle: finish(AppBot.onUnmuteListHandler)},
+		stateInvite:     {handle: finish(AppBot.onInviteHandler)},
+		stateMembers:    {handle: finish(AppBot.onMembersHandler)},
+		stateLeaveList:  {handle: finish(AppBot.onLeaveListHandler)},
+		stateApiKeys:    {handle: finish(AppBot.onApiKeysHandler)},
+		stateNewApiKey:  {handle: finish(AppBot.onNewApiKeyHandler)},
+
+		stateSubmissions: {handle: finish(AppBot.onSubmissionsHandler)},
+		stateSubmitName: {
+			prompt: ask(message.AskSubmissionNameMessage()),
+			handle: AppBot.onSubmissionNameHandler,
+		},
+		stateSubmitBrand: {
+			prompt: ask(message.AskSubmissionBrandMessage()),
+			handle: AppBot.onSubmissionBrandHandler,
+		},
+		stateSubmitCategory: {
+			prompt: ask(message.AskSubmissionCategoryMessage()),
+			handle: AppBot.onSubmissionCategoryHandler,
+		},
+	}
+}
+
+// finish turns a single message handler into a state that ends the dialog.
+func finish(h func(ab AppBot, msg *tgbotapi.Message) error) stateHandler {
+	return func(ab AppBot, msg *tgbotapi.Message, _ *dialog.Dialog) (string, error) {
+		return "", h(ab, msg)
+	}
+}
+
+func ask(question string) func(ab AppBot, chatId int64, d *dialog.Dialog) error {
+	return func(ab AppBot, chatId int64, _ *dialog.Dialog) error {
+		_, err := ab.bot.Send(tgbotapi.NewMessage(chatId, question))
+		return err
+	}
+}
+
+func (ab AppBot) onCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	if msg.Command() == "cancel" {
+		return ab.onCancelHandler(ctx, msg)
+	}
+
+	name, ok := commands[msg.Command()]
+	if !ok {
+		return nil
+	}
+
+	// a command ends the dialog the chat was in like /cancel does, so the next
+	// message is not taken as an answer to it
+	if err := ab.db.DeleteDialog(ctx, msg.Chat.ID); err != nil {
+		return err
+	}
+
+	d := dialog.Dialog{ChatID: msg.Chat.ID, State: name}
+
+	return ab.handleState(ctx, msg, &d)
+}
+
+// continueDialog passes the message to the state the chat is waiting in.
+// It reports false when there is no active dialog.
+func (ab AppBot) continueDialog(ctx context.Context, msg *tgbotapi.Message) (bool, error) {
+	d, err := ab.db.FindDialog(ctx, msg.Chat.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if d.Expired() {
+		if err = ab.db.DeleteDialog(ctx, d.ChatID); err != nil {
+			return true, err
+		}
+
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.DialogExpiredMessage()))
+		return true, err
+	}
+
+	return true, ab.handleState(ctx, msg, &d)
+}
+
+func (ab AppBot) handleState(ctx context.Context, msg *tgbotapi.Message, d *dialog.Dialog) error {
+	s, ok := ab.states[d.State]
+	if !ok {
+		return ab.db.DeleteDialog(ctx, d.ChatID)
+	}
+
+	next, err := s.handle(ab, msg, d)
+	if err != nil {
+		return err
+	}
+
+	return ab.moveTo(ctx, d, next)
+}
+
+func (ab AppBot) moveTo(ctx context.Context, d *dialog.Dialog, name string) error {
+	if name == "" {
+		return ab.db.DeleteDialog(ctx, d.ChatID)
+	}
+
+	d.State = name
+	d.ExpireAt = time.Now().Add(ab.cfg.DialogTimeout)
+	if err := ab.db.SaveDialog(ctx, *d); err != nil {
+		return err
+	}
+
+	if s := ab.states[name]; s.prompt != nil {
+		return s.prompt(ab, d.ChatID, d)
+	}
+
+	return nil
+}
+
+func (ab AppBot) onCancelHandler(ctx context.Context, msg *tgbotapi.Message) error {
+	text := message.NothingToCancelMessage()
+
+	_, err := ab.db.FindDialog(ctx, msg.Chat.ID)
+	if err == nil {
+		if err = ab.db.DeleteDialog(ctx, msg.Chat.ID); err != nil {
+			return err
+		}
+		text = message.DialogCanceledMessage()
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, text))
+
+	return err
+}
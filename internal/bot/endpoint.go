@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const defaultApiUrl = "api.telegram.org"
+
+// apiBaseUrl accepts a host, like api.telegram.org, or a full url with scheme,
+// e.g. a local Bot API server or a fake one in tests.
+func apiBaseUrl(apiUrl string) (*url.URL, error) {
+	if apiUrl == "" {
+		apiUrl = defaultApiUrl
+	}
+	if !strings.Contains(apiUrl, "://") {
+		apiUrl = "https://" + apiUrl
+	}
+
+	u, err := url.Parse(apiUrl)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	return u, nil
+}
+
+// methodEndpoint is the format expected by tgbotapi, token and method are
+// substituted by the library.
+func methodEndpoint(base *url.URL) string {
+	return strings.ReplaceAll(base.String(), "%", "%%") + "/bot%s/%s"
+}
+
+func methodUrl(base *url.URL, token string, method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", base.String(), token, method)
+}
+
+func fileUrl(base *url.URL, token string, filePath string) string {
+	return fmt.Sprintf("%s/file/bot%s/%s", base.String(), token, filePath)
+}
@@ -0,0 +1,274 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+
+	"bybarcode/internal/message"
+	"bybarcode/internal/products"
+)
+
+func (ab AppBot) onInviteHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	ref, role := parseInviteArguments(msg.CommandArguments())
+
+	sl, ok := findList(lists, ref)
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.InviteListUsageMessage()))
+		return err
+	}
+
+	if !sl.IsOwner() {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.OnlyOwnerMessage(sl.Name)))
+		return err
+	}
+
+	invite, err := products.NewListInvite(sl.ID, role, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = ab.db.CreateListInvite(ctx, invite); err != nil {
+		return err
+	}
+
+	text := message.ListInviteMessage(sl.Name, role, invite.DeepLink(ab.bot.Self.UserName))
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, text))
+
+	return err
+}
+
+// onAcceptInvite handles /start invite_<code> sent by the invitation link.
+func (ab AppBot) onAcceptInvite(msg *tgbotapi.Message, code string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sl, joined, err := ab.db.AcceptListInvite(ctx, code, msg.Chat.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.InviteNotFoundMessage()))
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if !joined {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.AlreadyListMemberMessage(sl)))
+		return err
+	}
+
+	if _, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.InviteAcceptedMessage(sl))); err != nil {
+		return err
+	}
+
+	name := products.ListMember{Username: msg.Chat.UserName, FirstName: msg.Chat.FirstName}
+	_, err = ab.bot.Send(tgbotapi.NewMessage(sl.AccountId, message.MemberJoinedMessage(message.MemberName(name), sl.Name)))
+
+	return err
+}
+
+func (ab AppBot) onMembersHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	sl, ok := findList(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.MembersListUsageMessage()))
+		return err
+	}
+
+	return ab.sendMembers(ctx, msg.Chat.ID, sl)
+}
+
+func (ab AppBot) onLeaveListHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	sl, ok := findList(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.LeaveListUsageMessage()))
+		return err
+	}
+
+	if sl.IsOwner() {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.OwnerCannotLeaveMessage(sl.Name)))
+		return err
+	}
+
+	if err = ab.db.RemoveShoppingListMember(ctx, sl.ID, msg.Chat.ID); err != nil {
+		return err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ListLeftMessage(sl.Name)))
+
+	return err
+}
+
+func (ab AppBot) onRemoveMemberCallback(cb *tgbotapi.CallbackQuery, args []int64) error {
+	if len(args) != 2 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+	slId, accountId := args[0], args[1]
+	chatId := cb.Message.Chat.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sl, member, err := ab.findOwnedListMember(ctx, chatId, slId, accountId)
+	if err != nil {
+		return err
+	}
+
+	if err = ab.db.RemoveShoppingListMember(ctx, sl.ID, member.AccountId); err != nil {
+		return err
+	}
+
+	text := message.MemberRemovedMessage(message.MemberName(member), sl.Name)
+	if _, err = ab.bot.Send(tgbotapi.NewMessage(chatId, text)); err != nil {
+		return err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(member.AccountId, message.RemovedFromListMessage(sl.Name)))
+
+	return err
+}
+
+func (ab AppBot) onTransferOwnershipCallback(cb *tgbotapi.CallbackQuery, args []int64) error {
+	if len(args) != 2 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+	slId, accountId := args[0], args[1]
+	chatId := cb.Message.Chat.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sl, member, err := ab.findOwnedListMember(ctx, chatId, slId, accountId)
+	if err != nil {
+		return err
+	}
+
+	if err = ab.db.TransferShoppingListOwnership(ctx, sl.ID, chatId, member.AccountId); err != nil {
+		return err
+	}
+
+	text := message.OwnershipTransferredMessage(message.MemberName(member), sl.Name)
+	if _, err = ab.bot.Send(tgbotapi.NewMessage(chatId, text)); err != nil {
+		return err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(member.AccountId, message.ListTransferredToYouMessage(sl.Name)))
+
+	return err
+}
+
+// findOwnedListMember makes sure the chat owns the list from callback data and
+// the account from it is another member of the list.
+func (ab AppBot) findOwnedListMember(
+	ctx context.Context,
+	chatId int64,
+	slId int64,
+	accountId int64,
+) (products.ShoppingList, products.ListMember, error) {
+	sl, err := ab.findAccountList(ctx, chatId, slId)
+	if err != nil {
+		return sl, products.ListMember{}, err
+	}
+
+	if !sl.IsOwner() {
+		return sl, products.ListMember{}, fmt.Errorf("account %d is not the owner of shopping list %d", chatId, slId)
+	}
+
+	members, err := ab.db.GetShoppingListMembers(ctx, sl.ID)
+	if err != nil {
+		return sl, products.ListMember{}, err
+	}
+
+	for _, m := range members {
+		if m.AccountId == accountId && m.Role != products.ListRoleOwner {
+			return sl, m, nil
+		}
+	}
+
+	return sl, products.ListMember{}, fmt.Errorf("account %d is not a member of shopping list %d", accountId, slId)
+}
+
+func (ab AppBot) sendMembers(ctx context.Context, chatId int64, sl products.ShoppingList) error {
+	members, err := ab.db.GetShoppingListMembers(ctx, sl.ID)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(chatId, message.ListMembersMessage(sl, members))
+	if keyboard := membersKeyboard(sl, members); keyboard != nil {
+		response.ReplyMarkup = keyboard
+	}
+
+	_, err = ab.bot.Send(response)
+
+	return err
+}
+
+// membersKeyboard lets the owner remove members or pass the list to one of them.
+func membersKeyboard(sl products.ShoppingList, members []products.ListMember) *tgbotapi.InlineKeyboardMarkup {
+	if !sl.IsOwner() {
+		return nil
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, m := range members {
+		if m.Role == products.ListRoleOwner {
+			continue
+		}
+
+		name := message.MemberName(m)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ "+name, callbackData(callbackRemoveMember, sl.ID, m.AccountId)),
+			tgbotapi.NewInlineKeyboardButtonData("👑 "+name, callbackData(callbackTransferOwnership, sl.ID, m.AccountId)),
+		))
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return &keyboard
+}
+
+// parseInviteArguments accepts "<list>" or "<list> viewer". Members are
+// invited as editors by default.
+func parseInviteArguments(args string) (string, string) {
+	args = strings.TrimSpace(args)
+
+	if i := strings.LastIndex(args, " "); i > 0 {
+		if role := strings.ToLower(args[i+1:]); products.ValidInviteRole(role) {
+			return strings.TrimSpace(args[:i]), role
+		}
+	}
+
+	return args, products.ListRoleEditor
+}
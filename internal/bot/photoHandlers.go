@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strings"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/message"
+	"bybarcode/internal/products"
+)
+
+// maxImageSize is the largest file the Bot API allows bots to download.
+const maxImageSize = 20 << 20
+
+func (ab AppBot) onPhotoHandler(msg *tgbotapi.Message) error {
+	fileId := imageFileId(msg)
+
+	img, err := ab.downloadImage(fileId)
+	if errors.Is(err, image.ErrFormat) {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.BarcodeNotRecognizedMessage()))
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	results := barcode.Decode(img)
+	switch len(results) {
+	case 0:
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.BarcodeNotRecognizedMessage()))
+		return err
+	case 1:
+	default:
+		codes := make([]string, 0, len(results))
+		for _, r := range results {
+			codes = append(codes, r.Digits)
+		}
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.SeveralBarcodesMessage(codes)))
+		return err
+	}
+
+	code, err := barcode.ResultGTIN(results[0])
+	if err != nil {
+		return err
+	}
+
+	quantity, ok := parseQuantity(msg.Caption)
+	if !ok {
+		quantity = products.OnePiece
+	}
+
+	return ab.sendProductInfo(msg.Chat.ID, code, quantity)
+}
+
+func (ab AppBot) downloadImage(fileId string) (image.Image, error) {
+	file, err := ab.bot.GetFile(tgbotapi.FileConfig{FileID: fileId})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fileUrl(ab.apiUrl, ab.bot.Token, file.FilePath), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := ab.bot.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file %s finished with status %d", fileId, response.StatusCode)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(response.Body, maxImageSize))
+
+	return img, err
+}
+
+// imageFileId returns the largest photo size or an image sent as a document.
+func imageFileId(msg *tgbotapi.Message) string {
+	if len(msg.Photo) > 0 {
+		largest := msg.Photo[0]
+		for _, p := range msg.Photo[1:] {
+			if p.Width*p.Height > largest.Width*largest.Height {
+				largest = p
+			}
+		}
+		return largest.FileID
+	}
+
+	if msg.Document != nil && strings.HasPrefix(msg.Document.MimeType, "image/") {
+		return msg.Document.FileID
+	}
+
+	return ""
+}
@@ -13,40 +13,40 @@ import (
 
 type Sender struct {
 	token  string
-	apiUrl string
+	apiUrl *url.URL
 	client http.Client
 }
 
-func NewSender(token string, apiUrl string) *Sender {
+// NewSender accepts the Bot API host or a full url of the Bot API server.
+func NewSender(token string, apiUrl string) (*Sender, error) {
+	base, err := apiBaseUrl(apiUrl)
+	if err != nil {
+		return nil, err
+	}
+
 	client := http.Client{
 		Timeout: 10 * time.Second,
 	}
 	return &Sender{
 		token:  token,
-		apiUrl: apiUrl,
+		apiUrl: base,
 		client: client,
-	}
+	}, nil
 }
 
 func (s Sender) SendMessage(chatId int64, msg string) error {
-	actionUrl := url.URL{
-		Scheme: "https",
-		Host:   s.apiUrl,
-		Path:   fmt.Sprintf("/bot%s/sendMessage", s.token),
-	}
+	actionUrl := methodUrl(s.apiUrl, s.token, "sendMessage")
 
 	val := url.Values{}
 	val.Set("chat_id", strconv.FormatInt(chatId, 10))
 	val.Set("text", msg)
 	body := []byte(val.Encode())
 
-	fmt.Println(actionUrl.String())
-
-	req, err := http.NewRequest(http.MethodPost, actionUrl.String(), bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req, err := http.NewRequest(http.MethodPost, actionUrl, bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
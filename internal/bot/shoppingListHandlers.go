@@ -0,0 +1,688 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+
+	"bybarcode/internal/db"
+	"bybarcode/internal/dialog"
+	"bybarcode/internal/message"
+	"bybarcode/internal/products"
+)
+
+// archiveArguments show archived lists with /lists.
+var archiveArguments = map[string]bool{"archived": true, "archive": true, "архив": true}
+
+func (ab AppBot) onListsHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	include := db.ListInclude{
+		Archived: archiveArguments[strings.ToLower(strings.TrimSpace(msg.CommandArguments()))],
+	}
+	lists, err := ab.db.GetAllShoppingListsByAccount(ctx, msg.Chat.ID, include)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(msg.Chat.ID, message.ShoppingListsMessage(lists))
+	if len(lists) > 0 {
+		response.ReplyMarkup = showListKeyboard(lists)
+	}
+
+	_, err = ab.bot.Send(response)
+
+	return err
+}
+
+func (ab AppBot) onNewListHandler(msg *tgbotapi.Message, _ *dialog.Dialog) (string, error) {
+	name := strings.TrimSpace(msg.CommandArguments())
+	if name == "" {
+		return stateNewListAsk, nil
+	}
+
+	_, err := ab.createList(msg.Chat.ID, name)
+
+	return "", err
+}
+
+func (ab AppBot) onListNameHandler(msg *tgbotapi.Message, _ *dialog.Dialog) (string, error) {
+	name := strings.TrimSpace(msg.Text)
+	if name == "" {
+		return stateNewListAsk, nil
+	}
+
+	created, err := ab.createList(msg.Chat.ID, name)
+	if err != nil || created {
+		return "", err
+	}
+
+	return stateNewListAsk, nil
+}
+
+// createList reports false when the account already has a list with the name.
+func (ab AppBot) createList(chatId int64, name string) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sl := products.ShoppingList{
+		Name:      name,
+		AccountId: chatId,
+	}
+
+	_, err := ab.db.CreateShoppingList(ctx, sl)
+	if errors.Is(err, db.ErrConflict) {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(chatId, message.ListNameTakenMessage(name)))
+		return false, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(chatId, message.ListCreatedMessage(name)))
+
+	return true, err
+}
+
+func (ab AppBot) onRenameListHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	sl, name, ok := parseRenameArguments(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.RenameListUsageMessage()))
+		return err
+	}
+
+	if !sl.IsOwner() {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.OnlyOwnerMessage(sl.Name)))
+		return err
+	}
+
+	oldName := sl.Name
+	sl.Name = name
+
+	var text string
+	_, err = ab.db.UpdateShoppingList(ctx, sl)
+	if errors.Is(err, db.ErrConflict) {
+		text = message.ListNameTakenMessage(name)
+	} else if err != nil {
+		return err
+	} else {
+		text = message.ListRenamedMessage(oldName, name)
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, text))
+
+	return err
+}
+
+// onAddItemHandler adds an item without a barcode, e.g.
+// "/add Продукты -> хлеб 2 шт".
+func (ab AppBot) onAddItemHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	sl, text, ok := parseRenameArguments(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.AddItemUsageMessage()))
+		return err
+	}
+
+	if !sl.CanEdit() {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ListReadOnlyMessage(sl.Name)))
+		return err
+	}
+
+	name, q := splitQuantity(text)
+	item := products.TextItem{Name: name, ListItem: products.ListItem{Quantity: q}}
+	if item.Validate() != nil {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.AddItemUsageMessage()))
+		return err
+	}
+
+	if _, err = ab.db.AddTextItemToShoppingList(ctx, sl.ID, item); err != nil {
+		return err
+	}
+
+	if err = ab.db.AddedUpdStatisticByShoppingList(ctx, sl.ID); err != nil {
+		ab.logger.Error().Msg(err.Error())
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.TextItemAddedMessage(item.Name, q, sl.Name)))
+
+	return err
+}
+
+func (ab AppBot) onDeleteListHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	sl, ok := findList(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.DeleteListUsageMessage()))
+		return err
+	}
+
+	if !sl.IsOwner() {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.OnlyOwnerMessage(sl.Name)))
+		return err
+	}
+
+	if err = ab.db.DeleteShoppingList(ctx, sl.ID); err != nil {
+		return err
+	}
+
+	restoreUntil := time.Now().Add(ab.cfg.ListRestoreWindow)
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ListDeletedMessage(sl.Name, restoreUntil)))
+
+	return err
+}
+
+func (ab AppBot) onCopyListHandler(msg *tgbotapi.Message) error {
+	return ab.copyList(msg, false)
+}
+
+// onCopyRestHandler copies the items which are not bought yet, e.g. to buy
+// them in another shop.
+func (ab AppBot) onCopyRestHandler(msg *tgbotapi.Message) error {
+	return ab.copyList(msg, true)
+}
+
+func (ab AppBot) copyList(msg *tgbotapi.Message, uncheckedOnly bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetAllShoppingListsByAccount(ctx, msg.Chat.ID, db.ListInclude{Archived: true})
+	if err != nil {
+		return err
+	}
+
+	sl, name, ok := parseRenameArguments(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.CopyListUsageMessage()))
+		return err
+	}
+
+	var text string
+	copied := products.ShoppingList{Name: name, AccountId: msg.Chat.ID}
+	_, err = ab.db.DuplicateShoppingList(ctx, sl.ID, copied, uncheckedOnly)
+	if errors.Is(err, db.ErrConflict) {
+		text = message.ListNameTakenMessage(name)
+	} else if err != nil {
+		return err
+	} else {
+		text = message.ListCopiedMessage(sl.Name, name)
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, text))
+
+	return err
+}
+
+// onMergeListsHandler moves the items of the first list to the second one,
+// "/merge <list> -> <list>".
+func (ab AppBot) onMergeListsHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetAllShoppingListsByAccount(ctx, msg.Chat.ID, db.ListInclude{Archived: true})
+	if err != nil {
+		return err
+	}
+
+	sourceRef, targetRef, found := strings.Cut(msg.CommandArguments(), "->")
+	source, sourceOk := findList(lists, sourceRef)
+	target, targetOk := findList(lists, targetRef)
+	if !found || !sourceOk || !targetOk {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.MergeListsUsageMessage()))
+		return err
+	}
+
+	var text string
+	switch {
+	case source.ID == target.ID:
+		text = message.SameListMessage()
+	case !source.IsOwner():
+		text = message.OnlyOwnerMessage(source.Name)
+	case !target.CanEdit():
+		text = message.ListReadOnlyMessage(target.Name)
+	default:
+		var conflict *db.Error
+		err = ab.db.MergeShoppingLists(ctx, target.ID, source.ID)
+		if errors.As(err, &conflict) && errors.Is(err, db.ErrConflict) {
+			text = message.MergeUnitConflictMessage(source.Name, target.Name, strings.Split(conflict.Details["items"], ","))
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = ab.db.AddedUpdStatisticByShoppingList(ctx, target.ID); err != nil {
+			ab.logger.Error().Msg(err.Error())
+		}
+		text = message.ListsMergedMessage(source.Name, target.Name)
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, text))
+
+	return err
+}
+
+// onSplitRestHandler moves the unchecked items of a list to a new list, e.g.
+// what was not found in one shop. Picking the items is left to the API.
+func (ab AppBot) onSplitRestHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetAllShoppingListsByAccount(ctx, msg.Chat.ID, db.ListInclude{Archived: true})
+	if err != nil {
+		return err
+	}
+
+	sl, name, ok := parseRenameArguments(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.SplitRestUsageMessage()))
+		return err
+	}
+
+	if !sl.CanEdit() {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ListReadOnlyMessage(sl.Name)))
+		return err
+	}
+
+	items, err := ab.db.GetShoppingListProducts(ctx, sl.ID)
+	if err != nil {
+		return err
+	}
+
+	var itemIds []int64
+	for _, item := range items {
+		if !item.Checked {
+			itemIds = append(itemIds, item.ItemID)
+		}
+	}
+	if len(itemIds) == 0 {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.NothingToSplitMessage(sl.Name)))
+		return err
+	}
+
+	var text string
+	split := products.ShoppingList{Name: name, AccountId: msg.Chat.ID}
+	_, err = ab.db.SplitShoppingList(ctx, sl.ID, split, itemIds)
+	if errors.Is(err, db.ErrConflict) {
+		text = message.ListNameTakenMessage(name)
+	} else if err != nil {
+		return err
+	} else {
+		text = message.ListSplitMessage(sl.Name, name, len(itemIds))
+		if err = ab.db.AddedUpdStatisticByShoppingList(ctx, sl.ID); err != nil {
+			ab.logger.Error().Msg(err.Error())
+		}
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, text))
+
+	return err
+}
+
+func (ab AppBot) onRestoreListHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deletedAfter := time.Now().Add(-ab.cfg.ListRestoreWindow)
+	lists, err := ab.db.GetAllShoppingListsByAccount(ctx, msg.Chat.ID, db.ListInclude{DeletedAfter: deletedAfter})
+	if err != nil {
+		return err
+	}
+
+	// an active list may have the name of a deleted one
+	var deleted []products.ShoppingList
+	for _, sl := range lists {
+		if sl.DeletedAt != nil && sl.IsOwner() {
+			deleted = append(deleted, sl)
+		}
+	}
+
+	sl, ok := findList(deleted, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.RestoreListUsageMessage(deleted)))
+		return err
+	}
+
+	var text string
+	err = ab.db.RestoreShoppingList(ctx, sl.ID, deletedAfter)
+	if errors.Is(err, db.ErrConflict) {
+		text = message.ListNameTakenMessage(sl.Name)
+	} else if err != nil {
+		return err
+	} else {
+		text = message.ListRestoredMessage(sl.Name)
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, text))
+
+	return err
+}
+
+func (ab AppBot) onArchiveListHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	sl, ok := findList(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ArchiveListUsageMessage()))
+		return err
+	}
+
+	if !sl.IsOwner() {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.OnlyOwnerMessage(sl.Name)))
+		return err
+	}
+
+	if err = ab.db.ArchiveShoppingList(ctx, sl.ID); err != nil {
+		return err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ListArchivedMessage(sl.Name)))
+
+	return err
+}
+
+func (ab AppBot) onUnarchiveListHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetAllShoppingListsByAccount(ctx, msg.Chat.ID, db.ListInclude{Archived: true})
+	if err != nil {
+		return err
+	}
+
+	var archived []products.ShoppingList
+	for _, sl := range lists {
+		if sl.ArchivedAt != nil {
+			archived = append(archived, sl)
+		}
+	}
+
+	sl, ok := findList(archived, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.UnarchiveListUsageMessage()))
+		return err
+	}
+
+	if !sl.IsOwner() {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.OnlyOwnerMessage(sl.Name)))
+		return err
+	}
+
+	if err = ab.db.UnarchiveShoppingList(ctx, sl.ID); err != nil {
+		return err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ListUnarchivedMessage(sl.Name)))
+
+	return err
+}
+
+// onShowListHandler opens archived lists too, they are only hidden from /lists.
+func (ab AppBot) onShowListHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetAllShoppingListsByAccount(ctx, msg.Chat.ID, db.ListInclude{Archived: true})
+	if err != nil {
+		return err
+	}
+
+	sl, ok := findList(lists, msg.CommandArguments())
+	if !ok {
+		return ab.onListsHandler(msg)
+	}
+
+	return ab.sendShoppingList(ctx, msg.Chat.ID, sl)
+}
+
+func (ab AppBot) onMuteListHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	sl, ok := findList(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.MuteListUsageMessage()))
+		return err
+	}
+
+	if err = ab.db.MuteShoppingList(ctx, sl.ID, msg.Chat.ID); err != nil {
+		return err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ListMutedMessage(sl.Name)))
+
+	return err
+}
+
+func (ab AppBot) onUnmuteListHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lists, err := ab.db.GetShoppingListsByAccount(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	sl, ok := findList(lists, msg.CommandArguments())
+	if !ok {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.UnmuteListUsageMessage()))
+		return err
+	}
+
+	if err = ab.db.UnmuteShoppingList(ctx, sl.ID, msg.Chat.ID); err != nil {
+		return err
+	}
+
+	_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.ListUnmutedMessage(sl.Name)))
+
+	return err
+}
+
+func (ab AppBot) onShowListCallback(cb *tgbotapi.CallbackQuery, args []int64) error {
+	if len(args) != 1 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sl, err := ab.findAccountList(ctx, cb.Message.Chat.ID, args[0])
+	if err != nil {
+		return err
+	}
+
+	return ab.sendShoppingList(ctx, cb.Message.Chat.ID, sl)
+}
+
+// onToggleProductCallback toggles a product, or an item by its id when byItem
+// is set. Keyboards sent before free-text items were added pass product ids.
+func (ab AppBot) onToggleProductCallback(cb *tgbotapi.CallbackQuery, args []int64, byItem bool) error {
+	if len(args) != 2 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+	slId, id := args[0], args[1]
+	chatId := cb.Message.Chat.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sl, err := ab.findAccountList(ctx, chatId, slId)
+	if err != nil {
+		return err
+	}
+
+	if !sl.CanEdit() {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(chatId, message.ListReadOnlyMessage(sl.Name)))
+		return err
+	}
+
+	if byItem {
+		_, err = ab.db.ToggleShoppingListItemState(ctx, sl.ID, id)
+	} else {
+		_, err = ab.db.ToggleProductStateInShoppingList(ctx, sl.ID, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = ab.db.AddedUpdStatisticByShoppingList(ctx, sl.ID); err != nil {
+		ab.logger.Error().Msg(err.Error())
+	}
+
+	items, err := ab.db.GetShoppingListProducts(ctx, sl.ID)
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatId, cb.Message.MessageID, message.ShoppingListMessage(sl, items))
+	edit.ReplyMarkup = shoppingListKeyboard(sl, items)
+
+	_, err = ab.bot.Send(edit)
+
+	return err
+}
+
+func (ab AppBot) sendShoppingList(ctx context.Context, chatId int64, sl products.ShoppingList) error {
+	items, err := ab.db.GetShoppingListProducts(ctx, sl.ID)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(chatId, message.ShoppingListMessage(sl, items))
+	if keyboard := shoppingListKeyboard(sl, items); keyboard != nil {
+		response.ReplyMarkup = keyboard
+	}
+
+	_, err = ab.bot.Send(response)
+
+	return err
+}
+
+// findAccountList makes sure the chat is a member of the list from callback
+// data.
+func (ab AppBot) findAccountList(ctx context.Context, accountId int64, slId int64) (products.ShoppingList, error) {
+	sl, err := ab.db.FindShoppingListForAccount(ctx, slId, accountId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sl, fmt.Errorf("account %d is not a member of shopping list %d", accountId, slId)
+	}
+
+	return sl, err
+}
+
+func showListKeyboard(lists []products.ShoppingList) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(lists))
+	for _, sl := range lists {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(sl.Name, callbackData(callbackShowList, sl.ID)),
+		))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func shoppingListKeyboard(sl products.ShoppingList, items []products.ProductInList) *tgbotapi.InlineKeyboardMarkup {
+	if len(items) == 0 {
+		return nil
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(items))
+	for _, item := range items {
+		mark := "⬜"
+		if item.Checked {
+			mark = "✅"
+		}
+
+		label := fmt.Sprintf("%s %s", mark, item.Name)
+		if item.Quantity != products.OnePiece {
+			label += ", " + message.FormatQuantity(item.Quantity)
+		}
+		if item.Note != "" {
+			label += fmt.Sprintf(" (%s)", item.Note)
+		}
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, callbackData(callbackToggleItem, sl.ID, item.ItemID)),
+		))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return &keyboard
+}
+
+// findList resolves a list by its id or name, as shown by /lists.
+func findList(lists []products.ShoppingList, ref string) (products.ShoppingList, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return products.ShoppingList{}, false
+	}
+
+	id, err := strconv.ParseInt(ref, 10, 64)
+	for _, sl := range lists {
+		if (err == nil && sl.ID == id) || strings.EqualFold(sl.Name, ref) {
+			return sl, true
+		}
+	}
+
+	return products.ShoppingList{}, false
+}
+
+// parseRenameArguments accepts "<list> -> <new name>" or, for ids and single
+// word names, "<list> <new name>".
+func parseRenameArguments(lists []products.ShoppingList, args string) (products.ShoppingList, string, bool) {
+	ref, name, found := strings.Cut(args, "->")
+	if !found {
+		ref, name, found = strings.Cut(strings.TrimSpace(args), " ")
+	}
+	if !found {
+		return products.ShoppingList{}, "", false
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return products.ShoppingList{}, "", false
+	}
+
+	sl, ok := findList(lists, ref)
+
+	return sl, name, ok
+}
@@ -0,0 +1,290 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/db"
+	"bybarcode/internal/dialog"
+	"bybarcode/internal/message"
+	"bybarcode/internal/products"
+)
+
+const (
+	dialogKeyBarcode      = "barcode"
+	dialogKeyName         = "name"
+	dialogKeyBrand        = "brand"
+	dialogKeySubmissionId = "submission_id"
+
+	// skipAnswer leaves an optional field of a submission empty.
+	skipAnswer = "-"
+)
+
+// submitProductKeyboard passes the barcode as a number, GTINFromInt64 restores
+// the leading zeros.
+func submitProductKeyboard(code barcode.GTIN) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ Предложить товар", callbackData(callbackSubmitProduct, code.Int64())),
+		),
+	)
+}
+
+func moderationKeyboard(s products.Submission) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Одобрить", callbackData(callbackApproveSubmission, s.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить", callbackData(callbackEditSubmission, s.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отклонить", callbackData(callbackRejectSubmission, s.ID)),
+		),
+	)
+}
+
+func (ab AppBot) onSubmitProductCallback(cb *tgbotapi.CallbackQuery, args []int64) error {
+	if len(args) != 1 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+
+	code, err := barcode.GTINFromInt64(args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := dialog.Dialog{ChatID: cb.Message.Chat.ID}
+	d.Set(dialogKeyBarcode, string(code))
+
+	return ab.moveTo(ctx, &d, stateSubmitName)
+}
+
+func (ab AppBot) onSubmissionNameHandler(msg *tgbotapi.Message, d *dialog.Dialog) (string, error) {
+	name := strings.TrimSpace(msg.Text)
+	if name == "" || name == skipAnswer {
+		return stateSubmitName, nil
+	}
+
+	d.Set(dialogKeyName, name)
+
+	return stateSubmitBrand, nil
+}
+
+func (ab AppBot) onSubmissionBrandHandler(msg *tgbotapi.Message, d *dialog.Dialog) (string, error) {
+	brand := strings.TrimSpace(msg.Text)
+	if brand == "" {
+		return stateSubmitBrand, nil
+	}
+	if brand == skipAnswer {
+		brand = "unknown brand"
+	}
+
+	d.Set(dialogKeyBrand, brand)
+
+	return stateSubmitCategory, nil
+}
+
+// onSubmissionCategoryHandler finishes the dialog. It creates a new submission
+// or, when a moderator edits one, updates the existing submission.
+func (ab AppBot) onSubmissionCategoryHandler(msg *tgbotapi.Message, d *dialog.Dialog) (string, error) {
+	category := strings.TrimSpace(msg.Text)
+	if category == "" {
+		return stateSubmitCategory, nil
+	}
+	if category == skipAnswer {
+		category = "unknown category"
+	}
+
+	code, err := barcode.ParseGTIN(d.Get(dialogKeyBarcode))
+	if err != nil {
+		return "", err
+	}
+
+	s := products.Submission{
+		Upcean:       code,
+		Name:         d.Get(dialogKeyName),
+		BrandName:    d.Get(dialogKeyBrand),
+		CategoryName: category,
+		AccountId:    msg.Chat.ID,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if d.Get(dialogKeySubmissionId) == "" {
+		_, err = ab.db.CreateSubmission(ctx, s)
+		if errors.Is(err, db.ErrConflict) {
+			_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.SubmissionAlreadyExistsMessage(code)))
+			return "", err
+		}
+		if err != nil {
+			return "", err
+		}
+
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.SubmissionCreatedMessage()))
+		return "", err
+	}
+
+	if s.ID, err = strconv.ParseInt(d.Get(dialogKeySubmissionId), 10, 64); err != nil {
+		return "", err
+	}
+
+	s, err = ab.db.UpdateSubmission(ctx, s)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.SubmissionAlreadyReviewedMessage()))
+		return "", err
+	}
+	if errors.Is(err, db.ErrConflict) {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.SubmissionAlreadyExistsMessage(code)))
+		return "", err
+	}
+	if err != nil {
+		return "", err
+	}
+
+	response := tgbotapi.NewMessage(msg.Chat.ID, message.SubmissionMessage(s))
+	response.ReplyMarkup = moderationKeyboard(s)
+
+	_, err = ab.bot.Send(response)
+
+	return "", err
+}
+
+func (ab AppBot) onSubmissionsHandler(msg *tgbotapi.Message) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if ok, err := ab.isModerator(ctx, msg.Chat.ID); err != nil || !ok {
+		return err
+	}
+
+	list, err := ab.db.GetSubmissionsByStatus(ctx, products.SubmissionPending)
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		_, err = ab.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, message.NoSubmissionsMessage()))
+		return err
+	}
+
+	for _, s := range list {
+		response := tgbotapi.NewMessage(msg.Chat.ID, message.SubmissionMessage(s))
+		response.ReplyMarkup = moderationKeyboard(s)
+
+		if _, err = ab.bot.Send(response); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ab AppBot) onEditSubmissionCallback(cb *tgbotapi.CallbackQuery, args []int64) error {
+	if len(args) != 1 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if ok, err := ab.isModerator(ctx, cb.Message.Chat.ID); err != nil || !ok {
+		return err
+	}
+
+	s, err := ab.db.FindSubmissionById(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	d := dialog.Dialog{ChatID: cb.Message.Chat.ID}
+	d.Set(dialogKeySubmissionId, strconv.FormatInt(s.ID, 10))
+	d.Set(dialogKeyBarcode, string(s.Upcean))
+
+	return ab.moveTo(ctx, &d, stateSubmitName)
+}
+
+func (ab AppBot) onApproveSubmissionCallback(cb *tgbotapi.CallbackQuery, args []int64) error {
+	if len(args) != 1 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if ok, err := ab.isModerator(ctx, cb.Message.Chat.ID); err != nil || !ok {
+		return err
+	}
+
+	s, err := ab.db.ApproveSubmission(ctx, args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return ab.editText(cb, message.SubmissionAlreadyReviewedMessage())
+	}
+	if errors.Is(err, db.ErrConflict) {
+		return ab.editText(cb, fmt.Sprintf("%s\n\n⚠️ %s", cb.Message.Text, err.Error()))
+	}
+	if err != nil {
+		return err
+	}
+
+	ab.notifySubmitter(s, message.SubmissionApprovedMessage(s))
+
+	return ab.editText(cb, fmt.Sprintf("%s\n\n✅ Одобрено", message.SubmissionMessage(s)))
+}
+
+func (ab AppBot) onRejectSubmissionCallback(cb *tgbotapi.CallbackQuery, args []int64) error {
+	if len(args) != 1 {
+		return fmt.Errorf("unexpected callback data %q", cb.Data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if ok, err := ab.isModerator(ctx, cb.Message.Chat.ID); err != nil || !ok {
+		return err
+	}
+
+	s, err := ab.db.RejectSubmission(ctx, args[0], "")
+	if errors.Is(err, sql.ErrNoRows) {
+		return ab.editText(cb, message.SubmissionAlreadyReviewedMessage())
+	}
+	if err != nil {
+		return err
+	}
+
+	ab.notifySubmitter(s, message.SubmissionRejectedMessage(s))
+
+	return ab.editText(cb, fmt.Sprintf("%s\n\n❌ Отклонено", message.SubmissionMessage(s)))
+}
+
+func (ab AppBot) isModerator(ctx context.Context, accountId int64) (bool, error) {
+	acc, err := ab.db.FindAccountById(ctx, accountId)
+	if err != nil {
+		return false, err
+	}
+
+	return acc.IsModerator(), nil
+}
+
+// notifySubmitter doesn't fail the review, it is already saved.
+func (ab AppBot) notifySubmitter(s products.Submission, text string) {
+	if s.AccountId == 0 {
+		return
+	}
+
+	if _, err := ab.bot.Send(tgbotapi.NewMessage(s.AccountId, text)); err != nil {
+		ab.logger.Error().Msg(err.Error())
+	}
+}
+
+func (ab AppBot) editText(cb *tgbotapi.CallbackQuery, text string) error {
+	_, err := ab.bot.Send(tgbotapi.NewEditMessageText(cb.Message.Chat.ID, cb.Message.MessageID, text))
+	return err
+}
@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+)
+
+const (
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+	maxUpdateSize     = 1 << 20
+)
+
+// WebhookHandler accepts updates pushed by Telegram. Requests have to carry the
+// secret token the webhook was registered with, so the handler can be exposed
+// behind a reverse proxy.
+func (ab AppBot) WebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		secret := r.Header.Get(secretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(ab.cfg.WebhookSecret)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var upd tgbotapi.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUpdateSize)).Decode(&upd); err != nil {
+			ab.logger.Error().Msg(err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Telegram retries the update later when the shard queue is full.
+		if err := ab.workers.tryPush(upd); err != nil {
+			ab.logger.Error().Msg(err.Error())
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (ab AppBot) runWebhook() error {
+	if ab.cfg.WebhookSecret == "" {
+		return errors.New("webhook mode requires TELEGRAM_BOT_WEBHOOK_SECRET")
+	}
+
+	if ab.cfg.WebhookUrl != "" {
+		if err := ab.setWebhook(); err != nil {
+			return err
+		}
+	}
+
+	ab.logger.Info().Msgf("Bot webhook was started on host %s", ab.cfg.WebhookAddress)
+
+	err := ab.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// setWebhook registers the public url with Telegram. The library config has no
+// secret_token field, so the request is built by hand.
+func (ab AppBot) setWebhook() error {
+	params := tgbotapi.Params{}
+	params["url"] = ab.cfg.WebhookUrl
+	params["secret_token"] = ab.cfg.WebhookSecret
+	if err := params.AddInterface("allowed_updates", []string{"message", "callback_query"}); err != nil {
+		return err
+	}
+
+	_, err := ab.bot.MakeRequest("setWebhook", params)
+
+	return err
+}
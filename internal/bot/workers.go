@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog"
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+)
+
+var errShardQueueFull = errors.New("shard queue is full")
+
+// workerPool handles updates concurrently. Chats are sharded over the workers
+// by id, updates of one chat always go to the same shard, so a chat sees its
+// messages handled in order while chats of other shards don't wait for it.
+type workerPool struct {
+	queues []chan tgbotapi.Update
+	quit   chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+	handle func(upd tgbotapi.Update)
+	logger zerolog.Logger
+}
+
+func newWorkerPool(workers int, queueSize int, logger zerolog.Logger) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	queues := make([]chan tgbotapi.Update, workers)
+	for i := range queues {
+		queues[i] = make(chan tgbotapi.Update, queueSize)
+	}
+
+	return &workerPool{
+		queues: queues,
+		quit:   make(chan struct{}),
+		logger: logger,
+	}
+}
+
+func (wp *workerPool) start(handle func(upd tgbotapi.Update)) {
+	wp.handle = handle
+
+	for _, q := range wp.queues {
+		wp.wg.Add(1)
+		go wp.work(q)
+	}
+}
+
+// push queues the update, waiting for a free slot while the shard queue is
+// full. The polling loop calls it, so a full queue slows down polling instead
+// of losing updates which Telegram won't send again.
+func (wp *workerPool) push(upd tgbotapi.Update) error {
+	select {
+	case <-wp.quit:
+		return fmt.Errorf("update %d was not queued, bot is stopping", upd.UpdateID)
+	default:
+	}
+
+	select {
+	case wp.queues[wp.shard(upd)] <- upd:
+		return nil
+	case <-wp.quit:
+		return fmt.Errorf("update %d was not queued, bot is stopping", upd.UpdateID)
+	}
+}
+
+// tryPush queues the update without waiting and fails with errShardQueueFull
+// when the shard queue is full. Chats are sharded by id over the workers, so a
+// slow chat holds back every chat of its shard.
+func (wp *workerPool) tryPush(upd tgbotapi.Update) error {
+	select {
+	case <-wp.quit:
+		return fmt.Errorf("update %d was not queued, bot is stopping", upd.UpdateID)
+	default:
+	}
+
+	select {
+	case wp.queues[wp.shard(upd)] <- upd:
+		return nil
+	default:
+		return fmt.Errorf("update %d was not queued: %w", upd.UpdateID, errShardQueueFull)
+	}
+}
+
+// stop waits until the queued updates are handled or ctx is done.
+func (wp *workerPool) stop(ctx context.Context) error {
+	wp.once.Do(func() {
+		close(wp.quit)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		wp.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (wp *workerPool) work(q chan tgbotapi.Update) {
+	defer wp.wg.Done()
+
+	for {
+		select {
+		case upd := <-q:
+			wp.safeHandle(upd)
+		case <-wp.quit:
+			for {
+				select {
+				case upd := <-q:
+					wp.safeHandle(upd)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// safeHandle keeps the worker alive when handling of a single update panics.
+func (wp *workerPool) safeHandle(upd tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			wp.logger.Error().Msgf("update %d handling panic: %v", upd.UpdateID, r)
+		}
+	}()
+
+	wp.handle(upd)
+}
+
+func (wp *workerPool) shard(upd tgbotapi.Update) int {
+	var id int64
+	if chat := upd.FromChat(); chat != nil {
+		id = chat.ID
+	} else if user := upd.SentFrom(); user != nil {
+		id = user.ID
+	}
+
+	return int(uint64(id) % uint64(len(wp.queues)))
+}
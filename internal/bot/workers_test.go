@@ -0,0 +1,264 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	tgbotapi "gitlab.com/kingofsystem/telegram-bot-api/v5"
+)
+
+func chatUpdate(id int, chatId int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: id,
+		Message:  &tgbotapi.Message{MessageID: id, Chat: &tgbotapi.Chat{ID: chatId}},
+	}
+}
+
+func stopPool(t *testing.T, wp *workerPool) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := wp.stop(ctx); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+}
+
+func TestWorkerPoolKeepsChatOrder(t *testing.T) {
+	const chats, perChat = 5, 50
+
+	var mu sync.Mutex
+	handled := map[int64][]int{}
+
+	wp := newWorkerPool(3, chats*perChat, zerolog.Nop())
+	wp.start(func(upd tgbotapi.Update) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[upd.Message.Chat.ID] = append(handled[upd.Message.Chat.ID], upd.UpdateID)
+	})
+
+	for i := 0; i < chats*perChat; i++ {
+		if err := wp.push(chatUpdate(i, int64(i%chats))); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+	stopPool(t, wp)
+
+	for chat := int64(0); chat < chats; chat++ {
+		ids := handled[chat]
+		if len(ids) != perChat {
+			t.Fatalf("chat %d: handled %d updates, want %d", chat, len(ids), perChat)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Fatalf("chat %d: update %d handled after %d", chat, ids[i], ids[i-1])
+			}
+		}
+	}
+}
+
+func TestWorkerPoolDrainsOnStop(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	handled := 0
+
+	wp := newWorkerPool(2, 10, zerolog.Nop())
+	wp.start(func(upd tgbotapi.Update) {
+		<-release
+		mu.Lock()
+		handled++
+		mu.Unlock()
+	})
+
+	for i := 0; i < 10; i++ {
+		if err := wp.push(chatUpdate(i, int64(i%2))); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+
+	stopped := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- wp.stop(ctx)
+	}()
+
+	// the pool refuses new updates as soon as it is stopping
+	<-wp.quit
+	if err := wp.push(chatUpdate(10, 0)); err == nil {
+		t.Fatal("update was queued to a stopping pool")
+	}
+
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if handled != 10 {
+		t.Fatalf("handled %d updates, want 10", handled)
+	}
+}
+
+func TestWorkerPoolStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	wp := newWorkerPool(1, 1, zerolog.Nop())
+	wp.start(func(upd tgbotapi.Update) { <-release })
+	if err := wp.push(chatUpdate(1, 1)); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := wp.stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("stop = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWorkerPoolRecoversFromPanic(t *testing.T) {
+	var mu sync.Mutex
+	var handled []int
+
+	wp := newWorkerPool(1, 10, zerolog.Nop())
+	wp.start(func(upd tgbotapi.Update) {
+		if upd.UpdateID == 1 {
+			panic("handler failed")
+		}
+		mu.Lock()
+		handled = append(handled, upd.UpdateID)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := wp.push(chatUpdate(i, 7)); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+	stopPool(t, wp)
+
+	if len(handled) != 2 || handled[0] != 0 || handled[1] != 2 {
+		t.Fatalf("handled %v, want [0 2]", handled)
+	}
+}
+
+func TestWorkerPoolTryPushFailsWhenShardQueueIsFull(t *testing.T) {
+	started := make(chan int, 10)
+	release := make(chan struct{})
+
+	wp := newWorkerPool(2, 1, zerolog.Nop())
+	wp.start(func(upd tgbotapi.Update) {
+		started <- upd.UpdateID
+		<-release
+	})
+
+	// chat 1 keeps the worker of its shard busy with the first update and
+	// fills the shard queue with the second one
+	if err := wp.tryPush(chatUpdate(1, 1)); err != nil {
+		t.Fatalf("push 1: %v", err)
+	}
+	<-started
+	if err := wp.tryPush(chatUpdate(2, 1)); err != nil {
+		t.Fatalf("push 2: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- wp.tryPush(chatUpdate(3, 1)) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errShardQueueFull) {
+			t.Fatalf("push 3 = %v, want %v", err, errShardQueueFull)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("push to a full shard queue blocked")
+	}
+
+	// chat 2 is in the other shard and is not held back by chat 1
+	if err := wp.tryPush(chatUpdate(4, 2)); err != nil {
+		t.Fatalf("push 4: %v", err)
+	}
+	select {
+	case id := <-started:
+		if id != 4 {
+			t.Fatalf("started update %d, want 4", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("update of another shard was not handled")
+	}
+
+	close(release)
+	stopPool(t, wp)
+}
+
+func TestWorkerPoolPushWaitsForShardQueue(t *testing.T) {
+	started := make(chan int, 10)
+	release := make(chan struct{})
+
+	wp := newWorkerPool(1, 1, zerolog.Nop())
+	wp.start(func(upd tgbotapi.Update) {
+		started <- upd.UpdateID
+		<-release
+	})
+
+	if err := wp.push(chatUpdate(1, 1)); err != nil {
+		t.Fatalf("push 1: %v", err)
+	}
+	<-started
+	if err := wp.push(chatUpdate(2, 1)); err != nil {
+		t.Fatalf("push 2: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- wp.push(chatUpdate(3, 1)) }()
+	select {
+	case err := <-done:
+		t.Fatalf("push 3 = %v to a full shard queue, want it to wait", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("push 3: %v", err)
+	}
+	stopPool(t, wp)
+
+	for want := 2; want <= 3; want++ {
+		if id := <-started; id != want {
+			t.Fatalf("started update %d, want %d", id, want)
+		}
+	}
+}
+
+func TestWorkerPoolPushStopsWaitingOnStop(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	wp := newWorkerPool(1, 1, zerolog.Nop())
+	wp.start(func(upd tgbotapi.Update) { <-release })
+	for i := 1; i <= 2; i++ {
+		if err := wp.push(chatUpdate(i, 1)); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+
+	done := make(chan error)
+	go func() { done <- wp.push(chatUpdate(3, 1)) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_ = wp.stop(ctx)
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("update was queued to a stopping pool")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("push kept waiting after stop")
+	}
+}
@@ -9,6 +9,23 @@ import (
 const (
 	defaultBarcodeFilePath = "/data/products_data_all.csv"
 	defaultAddress         = "127.0.0.1:8080"
+	defaultDialogTimeout   = 10 * time.Minute
+	defaultWebhookAddress  = "127.0.0.1:8081"
+	defaultPollingTimeout  = 60
+	defaultWorkers         = 8
+	defaultQueueSize       = 100
+	defaultShutdownTimeout = 70 * time.Second
+	defaultNotifyDebounce  = 30 * time.Second
+	defaultNotifyMaxDelay  = 2 * time.Minute
+	defaultWebAppAuthAge   = time.Hour
+	defaultSessionPurge    = time.Hour
+	defaultListPurge       = time.Hour
+	defaultRestoreWindow   = 7 * 24 * time.Hour
+)
+
+const (
+	BotModePolling = "polling"
+	BotModeWebhook = "webhook"
 )
 
 type ApiConfig struct {
@@ -17,6 +34,29 @@ type ApiConfig struct {
 	Gateway  string
 	BotToken string
 	TgApiUrl string
+	// BotUsername is used to build invitation links to shopping lists.
+	BotUsername string
+	// NotifyDebounce is how long a list has to stay unchanged before its
+	// subscribers get the digest of changes.
+	NotifyDebounce time.Duration
+	NotifyMaxDelay time.Duration
+	// WebAppAuthMaxAge is how long Telegram web app init data can be exchanged
+	// for a session after it was signed.
+	WebAppAuthMaxAge time.Duration
+	// TokenHashKey is the secret session tokens and API keys are hashed with
+	// before they are stored. Changing it logs everybody out.
+	TokenHashKey string
+	// SessionPurgeInterval is how often expired sessions are deleted, zero
+	// disables purging.
+	SessionPurgeInterval time.Duration
+	// BarcodeLayoutsFile is a json file with variable measure barcode layouts,
+	// the default layouts are used without it.
+	BarcodeLayoutsFile string
+	// ListRestoreWindow is how long a deleted shopping list can be restored.
+	ListRestoreWindow time.Duration
+	// ListPurgeInterval is how often lists deleted before the restore window
+	// are deleted for good, zero disables purging.
+	ListPurgeInterval time.Duration
 }
 
 type CliConfig struct {
@@ -25,26 +65,64 @@ type CliConfig struct {
 }
 
 type BotConfig struct {
-	Token       string
-	DBDsn       string
-	TgWebAppUrl string
+	Token          string
+	TgApiUrl       string
+	DBDsn          string
+	TgWebAppUrl    string
+	DialogTimeout  time.Duration
+	Mode           string
+	WebhookAddress string
+	WebhookUrl     string
+	WebhookSecret  string
+	// PollingTimeout is the long polling timeout in seconds.
+	PollingTimeout  int
+	Workers         int
+	QueueSize       int
+	ShutdownTimeout time.Duration
+	// TokenHashKey has to be the same as in the api, which checks the API keys
+	// created by the bot.
+	TokenHashKey       string
+	BarcodeLayoutsFile string
+	ListRestoreWindow  time.Duration
 }
 
 func NewBotConfig() *BotConfig {
 	return &BotConfig{
-		Token:       getEnvString("TELEGRAM_BOT_TOKEN", ""),
-		DBDsn:       getEnvString("POSTGRESQL_URL", ""),
-		TgWebAppUrl: getEnvString("TELEGRAM_BOT_WEB_APP_URL", ""),
+		Token:              getEnvString("TELEGRAM_BOT_TOKEN", ""),
+		TgApiUrl:           getEnvString("TELEGRAM_API_URL", ""),
+		DBDsn:              getEnvString("POSTGRESQL_URL", ""),
+		TgWebAppUrl:        getEnvString("TELEGRAM_BOT_WEB_APP_URL", ""),
+		DialogTimeout:      getEnvDuration("TELEGRAM_BOT_DIALOG_TIMEOUT", defaultDialogTimeout),
+		Mode:               getEnvString("TELEGRAM_BOT_MODE", BotModePolling),
+		WebhookAddress:     getEnvString("TELEGRAM_BOT_WEBHOOK_ADDRESS", defaultWebhookAddress),
+		WebhookUrl:         getEnvString("TELEGRAM_BOT_WEBHOOK_URL", ""),
+		WebhookSecret:      getEnvString("TELEGRAM_BOT_WEBHOOK_SECRET", ""),
+		PollingTimeout:     getEnvInt("TELEGRAM_BOT_POLLING_TIMEOUT", defaultPollingTimeout),
+		Workers:            getEnvInt("TELEGRAM_BOT_WORKERS", defaultWorkers),
+		QueueSize:          getEnvInt("TELEGRAM_BOT_QUEUE_SIZE", defaultQueueSize),
+		ShutdownTimeout:    getEnvDuration("TELEGRAM_BOT_SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+		TokenHashKey:       getEnvString("API_TOKEN_HASH_KEY", ""),
+		BarcodeLayoutsFile: getEnvString("BARCODE_LAYOUTS_FILE", ""),
+		ListRestoreWindow:  getEnvDuration("LIST_RESTORE_WINDOW", defaultRestoreWindow),
 	}
 }
 
 func NewApiConfig() *ApiConfig {
 	return &ApiConfig{
-		DBDsn:    getEnvString("POSTGRESQL_URL", ""),
-		Address:  getEnvString("APO_ADDRESS", defaultAddress),
-		Gateway:  getEnvString("API_GATEWAY", ""),
-		BotToken: getEnvString("TELEGRAM_BOT_TOKEN", ""),
-		TgApiUrl: getEnvString("TELEGRAM_API_URL", ""),
+		DBDsn:                getEnvString("POSTGRESQL_URL", ""),
+		Address:              getEnvString("APO_ADDRESS", defaultAddress),
+		Gateway:              getEnvString("API_GATEWAY", ""),
+		BotToken:             getEnvString("TELEGRAM_BOT_TOKEN", ""),
+		TgApiUrl:             getEnvString("TELEGRAM_API_URL", ""),
+		BotUsername:          getEnvString("TELEGRAM_BOT_USERNAME", ""),
+		NotifyDebounce:       getEnvDuration("API_NOTIFY_DEBOUNCE", defaultNotifyDebounce),
+		NotifyMaxDelay:       getEnvDuration("API_NOTIFY_MAX_DELAY", defaultNotifyMaxDelay),
+		WebAppAuthMaxAge:     getEnvDuration("API_WEBAPP_AUTH_MAX_AGE", defaultWebAppAuthAge),
+		TokenHashKey:         getEnvString("API_TOKEN_HASH_KEY", ""),
+		SessionPurgeInterval: getEnvDuration("API_SESSION_PURGE_INTERVAL", defaultSessionPurge),
+		BarcodeLayoutsFile:   getEnvString("BARCODE_LAYOUTS_FILE", ""),
+		ListRestoreWindow:    getEnvDuration("LIST_RESTORE_WINDOW", defaultRestoreWindow),
+		ListPurgeInterval:    getEnvDuration("API_LIST_PURGE_INTERVAL", defaultListPurge),
 	}
 }
 
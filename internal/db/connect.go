@@ -2,6 +2,8 @@ package db
 
 import (
 	"bybarcode/internal/auth"
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/dialog"
 	"bybarcode/internal/products"
 	"bybarcode/internal/stat"
 	"context"
@@ -9,7 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,8 +26,6 @@ type Connect struct {
 	sql *sql.DB
 }
 
-var ErrDuplicateKey error
-
 func NewConnect(driverName string, dsn string) (Connect, error) {
 	db, err := sql.Open(driverName, dsn)
 	if err != nil {
@@ -73,6 +73,20 @@ func (c *Connect) CreateCategory(ctx context.Context, name string) (int, error)
 	return categoryId, nil
 }
 
+// ProductReferencesExist reports whether the category and the brand a product
+// refers to exist.
+func (c *Connect) ProductReferencesExist(ctx context.Context, categoryId int64, brandId int64) (bool, bool, error) {
+	stmt, err := c.sql.PrepareContext(ctx, productReferencesExist())
+	if err != nil {
+		return false, false, err
+	}
+
+	var categoryExists, brandExists bool
+	err = stmt.QueryRowContext(ctx, categoryId, brandId).Scan(&categoryExists, &brandExists)
+
+	return categoryExists, brandExists, err
+}
+
 func (c *Connect) UpdateProduct(ctx context.Context, p products.Product) (products.Product, error) {
 	stmt, err := c.sql.PrepareContext(ctx, updateProductById())
 	if err != nil {
@@ -83,20 +97,90 @@ func (c *Connect) UpdateProduct(ctx context.Context, p products.Product) (produc
 	err = stmt.
 		QueryRowContext(ctx, p.Name, p.Upcean, p.CategoryId, p.BrandId, p.ID).
 		Scan(&productId)
+	if err != nil {
+		return p, mapError(err, fmt.Sprintf("product with barcode %s already exists", p.Upcean.Short()))
+	}
 
-	return p, err
+	return p, nil
 }
 
-func (c *Connect) DeleteProduct(ctx context.Context, id int64) error {
-	stmt, err := c.sql.PrepareContext(ctx, deleteProductById())
+// DeleteProduct refuses to delete a product which is on shopping lists with
+// an ErrConflict, its details list the ids of the lists.
+func (c *Connect) DeleteProduct(ctx context.Context, id int64) (err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	lockStmt, err := tx.PrepareContext(ctx, lockProductById())
 	if err != nil {
 		return err
 	}
 
 	var productId int64
-	err = stmt.QueryRowContext(ctx, id).Scan(&productId)
+	if err = lockStmt.QueryRowContext(ctx, id).Scan(&productId); err != nil {
+		return err
+	}
 
-	return err
+	listIds, err := c.productShoppingLists(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if len(listIds) > 0 {
+		return &Error{
+			Kind:    ErrConflict,
+			Message: fmt.Sprintf("product is on %d shopping lists, remove it from them first", len(listIds)),
+			Details: map[string]string{"shopping_lists": strings.Join(listIds, ",")},
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, deleteProductById())
+	if err != nil {
+		return err
+	}
+
+	if err = stmt.QueryRowContext(ctx, id).Scan(&productId); err != nil {
+		return mapDeleteError(err, "product is still referenced, remove it from the shopping lists first")
+	}
+
+	return nil
+}
+
+func (c *Connect) productShoppingLists(ctx context.Context, tx *sql.Tx, productId int64) ([]string, error) {
+	stmt, err := tx.PrepareContext(ctx, getProductShoppingLists())
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := stmt.QueryContext(ctx, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(r *sql.Rows) {
+		if rErr := r.Close(); rErr != nil {
+			err = rErr
+		}
+	}(r)
+
+	var listIds []string
+	for r.Next() {
+		var listId int64
+		if err = r.Scan(&listId); err != nil {
+			return nil, err
+		}
+
+		listIds = append(listIds, strconv.FormatInt(listId, 10))
+	}
+
+	return listIds, r.Err()
 }
 
 func (c *Connect) CreateProduct(ctx context.Context, p products.Product) (int64, error) {
@@ -107,7 +191,7 @@ func (c *Connect) CreateProduct(ctx context.Context, p products.Product) (int64,
 
 	var productId int64
 	if err := stmt.QueryRowContext(ctx, p.Name, p.Upcean, p.CategoryId, p.BrandId).Scan(&productId); err != nil {
-		return 0, err
+		return 0, mapError(err, fmt.Sprintf("product with barcode %s already exists", p.Upcean.Short()))
 	}
 
 	return productId, nil
@@ -132,7 +216,7 @@ func (c *Connect) FindAccountById(ctx context.Context, accountId int64) (auth.Ac
 
 	err = stmtCheck.
 		QueryRowContext(ctx, accountId).
-		Scan(&acc.ID, &acc.FirstName, &acc.LastName, &acc.Username)
+		Scan(&acc.ID, &acc.FirstName, &acc.LastName, &acc.Username, &acc.Role)
 	if err != nil {
 		return acc, err
 	}
@@ -140,183 +224,188 @@ func (c *Connect) FindAccountById(ctx context.Context, accountId int64) (auth.Ac
 	return acc, nil
 }
 
-func (c *Connect) CreateSession(ctx context.Context, accountId int64) (auth.Session, error) {
-	session := auth.Session{}
-
-	tx, err := c.sql.BeginTx(ctx, nil)
+func (c *Connect) UpdateAccountRole(ctx context.Context, accountId int64, role string) error {
+	stmt, err := c.sql.PrepareContext(ctx, updateAccountRole())
 	if err != nil {
-		return session, err
+		return err
 	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-			return
-		}
-		err = tx.Commit()
-	}()
+
+	var id int64
+	err = stmt.QueryRowContext(ctx, role, accountId).Scan(&id)
+
+	return err
+}
+
+// CreateSession stores a new login of the account issued by
+// auth.TokenHasher.IssueSession. Every login gets its own session family, so
+// devices can refresh and be revoked independently.
+func (c *Connect) CreateSession(ctx context.Context, session auth.Session) (auth.Session, error) {
+	accountId := session.AccountID
 
 	stmtCheck, err := c.sql.PrepareContext(ctx, findAccount())
 	if err != nil {
-		return session, err
+		return auth.Session{}, err
 	}
 
 	acc := auth.Account{}
 	err = stmtCheck.
 		QueryRowContext(ctx, accountId).
-		Scan(&acc.ID, &acc.FirstName, &acc.LastName, &acc.Username)
+		Scan(&acc.ID, &acc.FirstName, &acc.LastName, &acc.Username, &acc.Role)
 
-	if errors.As(err, &pgx.ErrNoRows) {
-		return session, fmt.Errorf("there is no account with id %d", accountId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Session{}, fmt.Errorf("there is no account with id %d", accountId)
 	} else if err != nil {
-		return session, err
+		return auth.Session{}, err
+	}
+
+	stmtInsert, err := c.sql.PrepareContext(ctx, CreateSession())
+	if err != nil {
+		return auth.Session{}, err
 	}
 
-	stmtSessionCheck, err := c.sql.PrepareContext(ctx, findNotExpiredSessionByAccountId())
+	err = insertSession(ctx, stmtInsert, session)
+
+	return session, err
+}
+
+// FindNotExpiredSession looks the session up by the hash of its access token.
+func (c *Connect) FindNotExpiredSession(ctx context.Context, tokenHash string) (auth.Session, error) {
+	stmt, err := c.sql.PrepareContext(ctx, findNotExpiredSessionByToken())
 	if err != nil {
-		return session, err
+		return auth.Session{}, err
 	}
 
-	err = stmtSessionCheck.
-		QueryRowContext(ctx, accountId).
-		Scan(
-			&session.ID,
-			&session.Token,
-			&session.RefreshToken,
-			&session.AccountID,
-			&session.ExpireAt,
-			&session.CreatedAt,
-			&session.UpdatedAt,
-		)
-	if err != nil && !errors.As(err, &pgx.ErrNoRows) {
+	return scanSession(stmt.QueryRowContext(ctx, tokenHash))
+}
+
+// RefreshSession replaces the session of the refresh token hash with next,
+// which joins the family of the replaced session. A refresh token can be used only once: presenting a rotated
+// token again revokes the whole family and returns ErrRefreshTokenReused, as
+// either the legitimate client or an attacker holds a stolen token. Unknown,
+// revoked and expired refresh tokens are sql.ErrNoRows.
+func (c *Connect) RefreshSession(ctx context.Context, refreshTokenHash string, next auth.Session) (session auth.Session, err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
 		return session, err
 	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
 
-	if session.ID != uuid.Nil {
-		return session, nil
+	findStmt, err := tx.PrepareContext(ctx, findSessionByRefreshTokenForUpdate())
+	if err != nil {
+		return session, err
 	}
 
-	stmtInsert, err := c.sql.PrepareContext(ctx, CreateSession())
+	var rotated, revoked bool
+	old := auth.Session{}
+	err = findStmt.
+		QueryRowContext(ctx, refreshTokenHash).
+		Scan(
+			&old.ID,
+			&old.TokenHash,
+			&old.RefreshTokenHash,
+			&old.AccountID,
+			&old.FamilyID,
+			&old.ExpireAt,
+			&old.RefreshExpireAt,
+			&old.CreatedAt,
+			&old.UpdatedAt,
+			&rotated,
+			&revoked,
+		)
 	if err != nil {
 		return session, err
 	}
 
-	session.ID = uuid.New()
-	session.Token = uuid.New()
-	session.RefreshToken = uuid.New()
-	session.AccountID = accountId
-	session.ExpireAt = time.Now().Add(24 * time.Hour)
-	session.CreatedAt = time.Now()
-	session.UpdatedAt = time.Now()
+	if rotated {
+		revokeStmt, err := tx.PrepareContext(ctx, revokeSessionFamily())
+		if err != nil {
+			return session, err
+		}
+		if _, err = revokeStmt.ExecContext(ctx, old.AccountID, old.FamilyID); err != nil {
+			return session, err
+		}
+		if err = tx.Commit(); err != nil {
+			return session, err
+		}
 
-	_, err = stmtInsert.ExecContext(
-		ctx,
-		session.ID,
-		session.Token,
-		session.RefreshToken,
-		session.AccountID,
-		session.ExpireAt,
-		session.CreatedAt,
-		session.UpdatedAt,
-	)
+		return session, ErrRefreshTokenReused
+	}
 
-	return session, err
-}
+	if revoked || !old.RefreshExpireAt.After(time.Now()) {
+		return session, sql.ErrNoRows
+	}
 
-func (c *Connect) FindNotExpiredSession(ctx context.Context, token string) (auth.Session, error) {
-	session := auth.Session{}
+	rotateStmt, err := tx.PrepareContext(ctx, rotateSession())
+	if err != nil {
+		return session, err
+	}
+	if _, err = rotateStmt.ExecContext(ctx, old.ID); err != nil {
+		return session, err
+	}
 
-	stmt, err := c.sql.PrepareContext(ctx, findNotExpiredSessionByToken())
+	insertStmt, err := tx.PrepareContext(ctx, CreateSession())
 	if err != nil {
 		return session, err
 	}
 
-	err = stmt.
-		QueryRowContext(ctx, token).
-		Scan(
-			&session.ID,
-			&session.Token,
-			&session.RefreshToken,
-			&session.AccountID,
-			&session.ExpireAt,
-			&session.CreatedAt,
-			&session.UpdatedAt,
-		)
+	session = next
+	session.AccountID = old.AccountID
+	session.FamilyID = old.FamilyID
+	err = insertSession(ctx, insertStmt, session)
 
 	return session, err
 }
 
-func (c *Connect) FindProductByBarcode(ctx context.Context, barcode int64) (products.Product, error) {
-	p := products.Product{
-		Category: &products.Category{},
-		Brand:    &products.Brand{},
+// RevokeSessionFamily ends the login with all its sessions. It returns
+// sql.ErrNoRows when the account has no active login with the id.
+func (c *Connect) RevokeSessionFamily(ctx context.Context, accountId int64, familyId uuid.UUID) error {
+	stmt, err := c.sql.PrepareContext(ctx, revokeSessionFamily())
+	if err != nil {
+		return err
 	}
 
-	stmt, err := c.sql.PrepareContext(ctx, findProductByBarcode())
+	res, err := stmt.ExecContext(ctx, accountId, familyId)
 	if err != nil {
-		return p, err
+		return err
 	}
 
-	err = stmt.
-		QueryRowContext(ctx, barcode).
-		Scan(
-			&p.ID,
-			&p.Name,
-			&p.Upcean,
-			&p.CategoryId,
-			&p.BrandId,
-			&p.Category.ID,
-			&p.Category.Name,
-			&p.Brand.ID,
-			&p.Brand.Name,
-		)
-
-	return p, err
-}
-
-func (c *Connect) CreateShoppingList(ctx context.Context, sl products.ShoppingList) (int64, error) {
-	stmt, err := c.sql.PrepareContext(ctx, createShoppingList())
+	affected, err := res.RowsAffected()
 	if err != nil {
-		return 0, err
+		return err
 	}
-
-	var listId int64
-	if err := stmt.QueryRowContext(ctx, sl.Name, sl.AccountId).Scan(&listId); err != nil {
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			ErrDuplicateKey = fmt.Errorf("duplciate shopping list name %s", sl.Name)
-			return 0, ErrDuplicateKey
-		}
-		return 0, err
+	if affected == 0 {
+		return sql.ErrNoRows
 	}
 
-	return listId, nil
+	return nil
 }
 
-func (c *Connect) UpdateShoppingList(ctx context.Context, sl products.ShoppingList) (products.ShoppingList, error) {
-	stmt, err := c.sql.PrepareContext(ctx, updateShoppingListById())
+// RevokeOtherSessionFamilies ends every login of the account except the one
+// with the given family id.
+func (c *Connect) RevokeOtherSessionFamilies(ctx context.Context, accountId int64, familyId uuid.UUID) error {
+	stmt, err := c.sql.PrepareContext(ctx, revokeOtherSessionFamilies())
 	if err != nil {
-		return sl, err
+		return err
 	}
 
-	var slId int64
-	err = stmt.
-		QueryRowContext(ctx, sl.Name, sl.ID).
-		Scan(&slId)
+	_, err = stmt.ExecContext(ctx, accountId, familyId)
 
-	return sl, err
+	return err
 }
 
-func (c *Connect) GetShoppingListsByAccount(ctx context.Context, accId int64) ([]products.ShoppingList, error) {
-	var (
-		id   int64
-		name string
-	)
-
-	stmt, err := c.sql.PrepareContext(ctx, getShoppingListsByAccount())
+func (c *Connect) GetActiveSessions(ctx context.Context, accountId int64) ([]auth.SessionInfo, error) {
+	stmt, err := c.sql.PrepareContext(ctx, getActiveSessionsByAccountId())
 	if err != nil {
 		return nil, err
 	}
 
-	r, err := stmt.QueryContext(ctx, accId)
+	r, err := stmt.QueryContext(ctx, accountId)
 	if err != nil {
 		return nil, err
 	}
@@ -327,98 +416,72 @@ func (c *Connect) GetShoppingListsByAccount(ctx context.Context, accId int64) ([
 		}
 	}(r)
 
-	var slm []products.ShoppingList
+	var sessions []auth.SessionInfo
 	for r.Next() {
-		if err = r.Scan(&id, &name); err != nil {
+		s := auth.SessionInfo{}
+		if err = r.Scan(&s.ID, &s.CreatedAt, &s.RefreshedAt, &s.ExpireAt); err != nil {
 			return nil, err
 		}
 
-		sl := products.ShoppingList{
-			ID:        id,
-			Name:      name,
-			AccountId: accId,
-		}
-
-		slm = append(slm, sl)
+		sessions = append(sessions, s)
 	}
 
 	if err = r.Err(); err != nil {
 		return nil, err
 	}
 
-	return slm, err
+	return sessions, err
 }
 
-func (c *Connect) DeleteShoppingList(ctx context.Context, id int64) error {
-	stmt, err := c.sql.PrepareContext(ctx, deleteShoppingListById())
+// PurgeExpiredSessions deletes sessions whose refresh token has expired and
+// returns how many were deleted.
+func (c *Connect) PurgeExpiredSessions(ctx context.Context) (int64, error) {
+	stmt, err := c.sql.PrepareContext(ctx, purgeExpiredSessions())
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	var slId int64
-	err = stmt.QueryRowContext(ctx, id).Scan(&slId)
-
-	return err
-}
-
-func (c *Connect) AddProductToShoppingListByIds(ctx context.Context, productId int64, listId int64) error {
-	tx, err := c.sql.BeginTx(ctx, nil)
+	res, err := stmt.ExecContext(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-			return
-		}
-		err = tx.Commit()
-	}()
 
-	productStmt, err := tx.PrepareContext(ctx, findProductByIdOrBarcode())
-	if err != nil {
-		return err
-	}
+	return res.RowsAffected()
+}
 
-	p := products.Product{}
-	err = productStmt.QueryRowContext(ctx, productId).Scan(&p.ID, &p.Name, &p.Upcean, &p.CategoryId, &p.BrandId)
+func (c *Connect) CreateApiKey(ctx context.Context, key auth.APIKey) (auth.APIKey, error) {
+	stmt, err := c.sql.PrepareContext(ctx, createApiKey())
 	if err != nil {
-		return err
+		return key, err
 	}
 
-	slStmt, err := tx.PrepareContext(ctx, findShoppingListById())
+	err = stmt.
+		QueryRowContext(ctx, key.AccountID, key.Name, key.KeyHash, auth.JoinScopes(key.Scopes), key.ExpireAt, key.CreatedAt).
+		Scan(&key.ID)
 	if err != nil {
-		return err
+		return key, mapError(err, "api key already exists")
 	}
 
-	sl := products.ShoppingList{}
-	err = slStmt.QueryRowContext(ctx, listId).Scan(&sl.ID, &sl.Name, &sl.AccountId)
-	if err != nil {
-		return err
-	}
+	return key, nil
+}
 
-	stmt, err := tx.PrepareContext(ctx, addProductToShoppingList())
+// FindNotExpiredApiKey looks the key up by its hash.
+func (c *Connect) FindNotExpiredApiKey(ctx context.Context, keyHash string) (auth.APIKey, error) {
+	stmt, err := c.sql.PrepareContext(ctx, findNotExpiredApiKeyByHash())
 	if err != nil {
-		return err
-	}
-
-	_, err = stmt.ExecContext(ctx, sl.ID, p.ID)
-	if err != nil && strings.Contains(err.Error(), "shopping_list__products_pkey") {
-		ErrDuplicateKey = fmt.Errorf("there is already exist product %s in list %s", p.Name, sl.Name)
-		return ErrDuplicateKey
+		return auth.APIKey{}, err
 	}
 
-	err = tx.Commit()
-
-	return err
+	return scanApiKey(stmt.QueryRowContext(ctx, keyHash))
 }
 
-func (c *Connect) GetShoppingListProducts(ctx context.Context, slId int64) ([]products.ProductInList, error) {
-	stmt, err := c.sql.PrepareContext(ctx, getShoppingListProducts())
+func (c *Connect) GetApiKeysByAccount(ctx context.Context, accountId int64) ([]auth.APIKey, error) {
+	stmt, err := c.sql.PrepareContext(ctx, getApiKeysByAccountId())
 	if err != nil {
 		return nil, err
 	}
 
-	r, err := stmt.QueryContext(ctx, slId)
+	r, err := stmt.QueryContext(ctx, accountId)
 	if err != nil {
 		return nil, err
 	}
@@ -429,65 +492,1056 @@ func (c *Connect) GetShoppingListProducts(ctx context.Context, slId int64) ([]pr
 		}
 	}(r)
 
-	var (
-		pList      []products.ProductInList
-		id         int64
-		name       string
-		barcode    int64
-		categoryId int64
-		brandId    int64
-		checked    bool
-	)
+	var keys []auth.APIKey
 	for r.Next() {
-		if err = r.Scan(&id, &name, &barcode, &categoryId, &brandId, &checked); err != nil {
+		key, err := scanApiKey(r)
+		if err != nil {
 			return nil, err
 		}
 
-		p := products.ProductInList{
-			Product: products.Product{
-				ID:         id,
-				Name:       name,
-				Upcean:     barcode,
-				CategoryId: categoryId,
-				BrandId:    brandId,
-			},
-			Checked: checked,
-		}
-
-		pList = append(pList, p)
+		keys = append(keys, key)
 	}
 
 	if err = r.Err(); err != nil {
 		return nil, err
 	}
 
-	return pList, err
+	return keys, err
 }
 
-func (c *Connect) DeleteProductFromShoppingList(ctx context.Context, slId int64, pId int64) error {
-	stmt, err := c.sql.PrepareContext(ctx, deleteProductFromShoppingList())
+// TouchApiKey records that the key was used.
+func (c *Connect) TouchApiKey(ctx context.Context, id int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, touchApiKey())
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.ExecContext(ctx, slId, pId)
+	_, err = stmt.ExecContext(ctx, id)
 
 	return err
 }
 
-func (c *Connect) ToggleProductStateInShoppingList(ctx context.Context, slId int64, pId int64) error {
-	stmt, err := c.sql.PrepareContext(ctx, toggleProductStateInList())
+// RevokeApiKey deletes the key of the account. It returns sql.ErrNoRows when
+// the account has no key with the id.
+func (c *Connect) RevokeApiKey(ctx context.Context, accountId int64, id int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, deleteApiKey())
 	if err != nil {
 		return err
 	}
 
-	var (
-		_slId int64
-		_pId  int64
-	)
-	return stmt.QueryRowContext(ctx, slId, pId).Scan(&_slId, &_pId)
-}
-
+	res, err := stmt.ExecContext(ctx, id, accountId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanApiKey(r rowScanner) (auth.APIKey, error) {
+	key := auth.APIKey{}
+
+	var scopes string
+	err := r.Scan(
+		&key.ID,
+		&key.AccountID,
+		&key.Name,
+		&key.KeyHash,
+		&scopes,
+		&key.ExpireAt,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+	)
+	key.Scopes = auth.SplitScopes(scopes)
+
+	return key, err
+}
+
+func insertSession(ctx context.Context, stmt *sql.Stmt, session auth.Session) error {
+	_, err := stmt.ExecContext(
+		ctx,
+		session.ID,
+		session.TokenHash,
+		session.RefreshTokenHash,
+		session.AccountID,
+		session.FamilyID,
+		session.ExpireAt,
+		session.RefreshExpireAt,
+		session.CreatedAt,
+		session.UpdatedAt,
+	)
+
+	return err
+}
+
+func scanSession(r rowScanner) (auth.Session, error) {
+	session := auth.Session{}
+	err := r.Scan(
+		&session.ID,
+		&session.TokenHash,
+		&session.RefreshTokenHash,
+		&session.AccountID,
+		&session.FamilyID,
+		&session.ExpireAt,
+		&session.RefreshExpireAt,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+	)
+
+	return session, err
+}
+
+func (c *Connect) FindProductByBarcode(ctx context.Context, code barcode.GTIN) (products.Product, error) {
+	p := products.Product{
+		Category: &products.Category{},
+		Brand:    &products.Brand{},
+	}
+
+	stmt, err := c.sql.PrepareContext(ctx, findProductByBarcode())
+	if err != nil {
+		return p, err
+	}
+
+	err = stmt.
+		QueryRowContext(ctx, code).
+		Scan(
+			&p.ID,
+			&p.Name,
+			&p.Upcean,
+			&p.CategoryId,
+			&p.BrandId,
+			&p.Category.ID,
+			&p.Category.Name,
+			&p.Brand.ID,
+			&p.Brand.Name,
+		)
+
+	return p, err
+}
+
+// CreateShoppingList creates the list and makes its account the owner.
+func (c *Connect) CreateShoppingList(ctx context.Context, sl products.ShoppingList) (listId int64, err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	listId, err = createShoppingListTx(ctx, tx, sl)
+
+	return listId, err
+}
+
+func createShoppingListTx(ctx context.Context, tx *sql.Tx, sl products.ShoppingList) (int64, error) {
+	stmt, err := tx.PrepareContext(ctx, createShoppingList())
+	if err != nil {
+		return 0, err
+	}
+
+	var listId int64
+	if err = stmt.QueryRowContext(ctx, sl.Name, sl.AccountId).Scan(&listId); err != nil {
+		return 0, mapError(err, fmt.Sprintf("shopping list %s already exists", sl.Name))
+	}
+
+	memberStmt, err := tx.PrepareContext(ctx, createShoppingListMember())
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = memberStmt.ExecContext(ctx, listId, sl.AccountId, products.ListRoleOwner)
+
+	return listId, err
+}
+
+// DuplicateShoppingList creates the list sl owned by its account with the
+// items of list slId, only the unchecked ones when uncheckedOnly is set.
+func (c *Connect) DuplicateShoppingList(
+	ctx context.Context,
+	slId int64,
+	sl products.ShoppingList,
+	uncheckedOnly bool,
+) (listId int64, err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if listId, err = createShoppingListTx(ctx, tx, sl); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, copyShoppingListItems())
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = stmt.ExecContext(ctx, slId, listId, uncheckedOnly)
+
+	return listId, err
+}
+
+// MergeShoppingLists adds the items of list sourceId to list targetId and
+// deletes the source list, so it can be restored like any deleted list. It is
+// ErrConflict when an item is on both lists in different units: its quantity
+// would be lost as the source list is deleted.
+func (c *Connect) MergeShoppingLists(ctx context.Context, targetId int64, sourceId int64) (err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	conflicts, err := c.mergeUnitConflicts(ctx, tx, targetId, sourceId)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		err = &Error{
+			Kind:    ErrConflict,
+			Message: fmt.Sprintf("%d items are on both lists in different units, change their units first", len(conflicts)),
+			Details: map[string]string{"items": strings.Join(conflicts, ",")},
+		}
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, mergeShoppingListItems())
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.ExecContext(ctx, targetId, sourceId); err != nil {
+		return mapError(err, "")
+	}
+
+	textStmt, err := tx.PrepareContext(ctx, mergeShoppingListTextItems())
+	if err != nil {
+		return err
+	}
+
+	if _, err = textStmt.ExecContext(ctx, targetId, sourceId); err != nil {
+		return mapError(err, "")
+	}
+
+	deleteStmt, err := tx.PrepareContext(ctx, deleteShoppingListById())
+	if err != nil {
+		return err
+	}
+
+	var slId int64
+	err = deleteStmt.QueryRowContext(ctx, sourceId).Scan(&slId)
+
+	return err
+}
+
+func (c *Connect) mergeUnitConflicts(ctx context.Context, tx *sql.Tx, targetId int64, sourceId int64) ([]string, error) {
+	stmt, err := tx.PrepareContext(ctx, mergeUnitConflicts())
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := stmt.QueryContext(ctx, targetId, sourceId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(r *sql.Rows) {
+		if rErr := r.Close(); rErr != nil {
+			err = rErr
+		}
+	}(r)
+
+	var names []string
+	for r.Next() {
+		var name string
+		if err = r.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, r.Err()
+}
+
+// SplitShoppingList creates the list sl owned by its account and moves the
+// items of list slId to it. It is sql.ErrNoRows when some of the items are not
+// on list slId.
+func (c *Connect) SplitShoppingList(
+	ctx context.Context,
+	slId int64,
+	sl products.ShoppingList,
+	itemIds []int64,
+) (listId int64, err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if listId, err = createShoppingListTx(ctx, tx, sl); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, moveShoppingListItems())
+	if err != nil {
+		return 0, err
+	}
+
+	ids := uniqueIds(itemIds)
+	res, err := stmt.ExecContext(ctx, slId, listId, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	moved, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if moved != int64(len(ids)) {
+		err = sql.ErrNoRows
+		return 0, err
+	}
+
+	return listId, nil
+}
+
+func uniqueIds(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
+
+func (c *Connect) UpdateShoppingList(ctx context.Context, sl products.ShoppingList) (products.ShoppingList, error) {
+	stmt, err := c.sql.PrepareContext(ctx, updateShoppingListById())
+	if err != nil {
+		return sl, err
+	}
+
+	var slId int64
+	err = stmt.
+		QueryRowContext(ctx, sl.Name, sl.ID).
+		Scan(&slId)
+	if err != nil {
+		return sl, mapError(err, fmt.Sprintf("shopping list %s already exists", sl.Name))
+	}
+
+	return sl, nil
+}
+
+// ListInclude selects the lists GetShoppingListsByAccount leaves out.
+type ListInclude struct {
+	Archived bool
+	// DeletedAfter adds the lists deleted after it, the zero time adds none.
+	DeletedAfter time.Time
+}
+
+// GetShoppingListsByAccount returns the active lists the account is a member
+// of, with the role of the account in each of them.
+func (c *Connect) GetShoppingListsByAccount(ctx context.Context, accId int64) ([]products.ShoppingList, error) {
+	return c.GetAllShoppingListsByAccount(ctx, accId, ListInclude{})
+}
+
+// GetAllShoppingListsByAccount is GetShoppingListsByAccount with archived or
+// recently deleted lists.
+func (c *Connect) GetAllShoppingListsByAccount(ctx context.Context, accId int64, include ListInclude) ([]products.ShoppingList, error) {
+	stmt, err := c.sql.PrepareContext(ctx, getShoppingListsByAccount())
+	if err != nil {
+		return nil, err
+	}
+
+	deletedAfter := sql.NullTime{Time: include.DeletedAfter, Valid: !include.DeletedAfter.IsZero()}
+	r, err := stmt.QueryContext(ctx, accId, include.Archived, deletedAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(r *sql.Rows) {
+		if rErr := r.Close(); rErr != nil {
+			err = rErr
+		}
+	}(r)
+
+	var slm []products.ShoppingList
+	for r.Next() {
+		sl := products.ShoppingList{}
+		if err = r.Scan(&sl.ID, &sl.Name, &sl.AccountId, &sl.Role, &sl.ArchivedAt, &sl.DeletedAt); err != nil {
+			return nil, err
+		}
+
+		slm = append(slm, sl)
+	}
+
+	if err = r.Err(); err != nil {
+		return nil, err
+	}
+
+	return slm, err
+}
+
+// DeleteShoppingList marks the list as deleted. It is hidden everywhere but
+// can be restored until PurgeDeletedShoppingLists deletes it.
+func (c *Connect) DeleteShoppingList(ctx context.Context, id int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, deleteShoppingListById())
+	if err != nil {
+		return err
+	}
+
+	var slId int64
+	err = stmt.QueryRowContext(ctx, id).Scan(&slId)
+
+	return err
+}
+
+// RestoreShoppingList restores a list deleted after deletedAfter. It is
+// ErrConflict when the owner has created another list with the same name.
+func (c *Connect) RestoreShoppingList(ctx context.Context, id int64, deletedAfter time.Time) error {
+	stmt, err := c.sql.PrepareContext(ctx, restoreShoppingListById())
+	if err != nil {
+		return err
+	}
+
+	var slId int64
+	if err = stmt.QueryRowContext(ctx, id, deletedAfter).Scan(&slId); err != nil {
+		return mapError(err, "a list with the same name already exists")
+	}
+
+	return nil
+}
+
+// PurgeDeletedShoppingLists deletes the lists deleted before deletedBefore for
+// good and returns how many were deleted.
+func (c *Connect) PurgeDeletedShoppingLists(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	stmt, err := c.sql.PrepareContext(ctx, purgeDeletedShoppingLists())
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := stmt.ExecContext(ctx, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (c *Connect) ArchiveShoppingList(ctx context.Context, id int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, archiveShoppingListById())
+	if err != nil {
+		return err
+	}
+
+	var archivedAt time.Time
+	err = stmt.QueryRowContext(ctx, id).Scan(&archivedAt)
+
+	return err
+}
+
+func (c *Connect) UnarchiveShoppingList(ctx context.Context, id int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, unarchiveShoppingListById())
+	if err != nil {
+		return err
+	}
+
+	var slId int64
+	err = stmt.QueryRowContext(ctx, id).Scan(&slId)
+
+	return err
+}
+
+func (c *Connect) AddProductToShoppingListByIds(
+	ctx context.Context,
+	productId int64,
+	listId int64,
+	item products.ListItem,
+) (err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	productStmt, err := tx.PrepareContext(ctx, findProductByIdOrBarcode())
+	if err != nil {
+		return err
+	}
+
+	p := products.Product{}
+	err = productStmt.QueryRowContext(ctx, productId, idAsBarcode(productId)).Scan(&p.ID, &p.Name, &p.Upcean, &p.CategoryId, &p.BrandId)
+	if err != nil {
+		return err
+	}
+
+	slStmt, err := tx.PrepareContext(ctx, findShoppingListById())
+	if err != nil {
+		return err
+	}
+
+	sl := products.ShoppingList{}
+	err = slStmt.QueryRowContext(ctx, listId).Scan(&sl.ID, &sl.Name, &sl.AccountId)
+	if err != nil {
+		return err
+	}
+
+	resolveStmt, err := tx.PrepareContext(ctx, resolveTextItem())
+	if err != nil {
+		return err
+	}
+
+	var (
+		itemId   int64
+		resolved bool
+	)
+	err = resolveStmt.
+		QueryRowContext(ctx, sl.ID, p.ID, p.Name, p.CategoryId, item.Unit, item.Amount, item.Note).
+		Scan(&itemId, &resolved)
+	if err == nil && !resolved {
+		err = otherUnitError(p, sl, itemId)
+		return err
+	}
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return mapError(err, fmt.Sprintf("product %s can't be added to list %s", p.Name, sl.Name))
+	}
+
+	stmt, err := tx.PrepareContext(ctx, addProductToShoppingList())
+	if err != nil {
+		return err
+	}
+
+	err = stmt.QueryRowContext(ctx, sl.ID, p.ID, item.Amount, item.Unit, item.Note).Scan(&itemId)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = otherUnitError(p, sl, 0)
+		return err
+	}
+	if err != nil {
+		return mapError(err, fmt.Sprintf("product %s can't be added to list %s", p.Name, sl.Name))
+	}
+
+	return nil
+}
+
+// otherUnitError is the conflict of adding a product which is on the list in
+// another unit. Quantities in different units can't be summed and the one on
+// the list is not overwritten, the client has to change the unit first.
+func otherUnitError(p products.Product, sl products.ShoppingList, itemId int64) error {
+	details := map[string]string{"unit": "differs from the unit on the list"}
+	if itemId != 0 {
+		details["item_id"] = strconv.FormatInt(itemId, 10)
+	}
+
+	return &Error{
+		Kind:    ErrConflict,
+		Message: fmt.Sprintf("product %s is on list %s in another unit", p.Name, sl.Name),
+		Details: details,
+	}
+}
+
+// FindShoppingListItemId returns the id of the item of a product on a list.
+func (c *Connect) FindShoppingListItemId(ctx context.Context, slId int64, pId int64) (int64, error) {
+	stmt, err := c.sql.PrepareContext(ctx, findShoppingListItemIdByProduct())
+	if err != nil {
+		return 0, err
+	}
+
+	var itemId int64
+	err = stmt.QueryRowContext(ctx, slId, pId).Scan(&itemId)
+
+	return itemId, err
+}
+
+func (c *Connect) GetShoppingListProducts(ctx context.Context, slId int64) ([]products.ProductInList, error) {
+	stmt, err := c.sql.PrepareContext(ctx, getShoppingListProducts())
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := stmt.QueryContext(ctx, slId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(r *sql.Rows) {
+		if rErr := r.Close(); rErr != nil {
+			err = rErr
+		}
+	}(r)
+
+	var (
+		pList      []products.ProductInList
+		itemId     int64
+		id         int64
+		name       string
+		upcean     barcode.GTIN
+		categoryId int64
+		brandId    int64
+		quantity   products.Quantity
+		note       string
+		checked    bool
+	)
+	for r.Next() {
+		if err = r.Scan(&itemId, &id, &name, &upcean, &categoryId, &brandId, &quantity.Amount, &quantity.Unit, &note, &checked); err != nil {
+			return nil, err
+		}
+
+		p := products.ProductInList{
+			ItemID: itemId,
+			Product: products.Product{
+				ID:         id,
+				Name:       name,
+				Upcean:     upcean,
+				CategoryId: categoryId,
+				BrandId:    brandId,
+			},
+			Quantity: quantity,
+			Note:     note,
+			Checked:  checked,
+		}
+
+		pList = append(pList, p)
+	}
+
+	if err = r.Err(); err != nil {
+		return nil, err
+	}
+
+	return pList, err
+}
+
+// AddTextItemToShoppingList adds an item without a product and returns its id.
+func (c *Connect) AddTextItemToShoppingList(ctx context.Context, slId int64, item products.TextItem) (int64, error) {
+	stmt, err := c.sql.PrepareContext(ctx, addTextItemToShoppingList())
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = stmt.QueryRowContext(ctx, slId, item.Name, item.CategoryId, item.Amount, item.Unit, item.Note).Scan(&id)
+	if err != nil {
+		return 0, mapError(err, "")
+	}
+
+	return id, nil
+}
+
+// DeleteShoppingListItem deletes an item by its id and returns the product id
+// of the item, or its text when it has no product.
+func (c *Connect) DeleteShoppingListItem(ctx context.Context, slId int64, itemId int64) (products.ProductInList, error) {
+	item := products.ProductInList{ItemID: itemId}
+
+	stmt, err := c.sql.PrepareContext(ctx, deleteShoppingListItem())
+	if err != nil {
+		return item, err
+	}
+
+	err = stmt.QueryRowContext(ctx, slId, itemId).Scan(&item.ID, &item.Name)
+
+	return item, err
+}
+
+// ToggleShoppingListItemState toggles an item by its id. The returned item has
+// the product id or the text and whether it is checked now.
+func (c *Connect) ToggleShoppingListItemState(ctx context.Context, slId int64, itemId int64) (products.ProductInList, error) {
+	item := products.ProductInList{ItemID: itemId}
+
+	stmt, err := c.sql.PrepareContext(ctx, toggleShoppingListItemState())
+	if err != nil {
+		return item, err
+	}
+
+	err = stmt.QueryRowContext(ctx, slId, itemId).Scan(&item.ID, &item.Name, &item.Checked)
+
+	return item, err
+}
+
+// UpdateShoppingListItemById sets the fields of the update which are not nil.
+// It works for free-text items too.
+func (c *Connect) UpdateShoppingListItemById(
+	ctx context.Context,
+	slId int64,
+	itemId int64,
+	upd products.ListItemUpdate,
+) (products.ProductInList, error) {
+	item := products.ProductInList{ItemID: itemId}
+
+	stmt, err := c.sql.PrepareContext(ctx, updateShoppingListItemById())
+	if err != nil {
+		return item, err
+	}
+
+	err = stmt.QueryRowContext(ctx, slId, itemId, upd.Amount, upd.Unit, upd.Note).Scan(&item.ID, &item.Name)
+	if err != nil {
+		return item, mapError(err, "")
+	}
+
+	return item, nil
+}
+
+// DeleteProductFromShoppingList returns ErrNotFound when the product is not on
+// the list.
+func (c *Connect) DeleteProductFromShoppingList(ctx context.Context, slId int64, pId int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, deleteProductFromShoppingList())
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.ExecContext(ctx, slId, pId)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ToggleProductStateInShoppingList returns whether the product is checked now.
+func (c *Connect) ToggleProductStateInShoppingList(ctx context.Context, slId int64, pId int64) (bool, error) {
+	stmt, err := c.sql.PrepareContext(ctx, toggleProductStateInList())
+	if err != nil {
+		return false, err
+	}
+
+	var checked bool
+	err = stmt.QueryRowContext(ctx, slId, pId).Scan(&checked)
+
+	return checked, err
+}
+
+// FindNotPurgedShoppingListById finds deleted lists too, e.g. to name a list
+// which was merged into another one.
+func (c *Connect) FindNotPurgedShoppingListById(ctx context.Context, id int64) (products.ShoppingList, error) {
+	sl := products.ShoppingList{}
+
+	stmt, err := c.sql.PrepareContext(ctx, findNotPurgedShoppingListById())
+	if err != nil {
+		return sl, err
+	}
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&sl.ID, &sl.Name, &sl.AccountId)
+
+	return sl, err
+}
+
+// FindShoppingListForAccount returns the list with the role of the account in
+// it, or sql.ErrNoRows when the account is not a member of the list.
+func (c *Connect) FindShoppingListForAccount(ctx context.Context, slId int64, accId int64) (products.ShoppingList, error) {
+	sl := products.ShoppingList{}
+
+	stmt, err := c.sql.PrepareContext(ctx, findShoppingListByIdForAccount())
+	if err != nil {
+		return sl, err
+	}
+
+	err = stmt.QueryRowContext(ctx, slId, accId).Scan(&sl.ID, &sl.Name, &sl.AccountId, &sl.Role, &sl.ArchivedAt, &sl.DeletedAt)
+
+	return sl, err
+}
+
+// FindDeletedShoppingListForAccount is FindShoppingListForAccount for a list
+// deleted after deletedAfter, which can still be restored.
+func (c *Connect) FindDeletedShoppingListForAccount(
+	ctx context.Context,
+	slId int64,
+	accId int64,
+	deletedAfter time.Time,
+) (products.ShoppingList, error) {
+	sl := products.ShoppingList{}
+
+	stmt, err := c.sql.PrepareContext(ctx, findDeletedShoppingListByIdForAccount())
+	if err != nil {
+		return sl, err
+	}
+
+	err = stmt.QueryRowContext(ctx, slId, accId, deletedAfter).Scan(&sl.ID, &sl.Name, &sl.AccountId, &sl.Role, &sl.ArchivedAt, &sl.DeletedAt)
+
+	return sl, err
+}
+
+func (c *Connect) GetShoppingListMembers(ctx context.Context, slId int64) ([]products.ListMember, error) {
+	stmt, err := c.sql.PrepareContext(ctx, getShoppingListMembers())
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := stmt.QueryContext(ctx, slId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(r *sql.Rows) {
+		if rErr := r.Close(); rErr != nil {
+			err = rErr
+		}
+	}(r)
+
+	var members []products.ListMember
+	for r.Next() {
+		m := products.ListMember{}
+		if err = r.Scan(&m.ShoppingListId, &m.AccountId, &m.Username, &m.FirstName, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		members = append(members, m)
+	}
+
+	if err = r.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, err
+}
+
+// RemoveShoppingListMember returns sql.ErrNoRows when the account is not a
+// member of the list or is its owner. The owner has to transfer the list first.
+func (c *Connect) RemoveShoppingListMember(ctx context.Context, slId int64, accId int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, deleteShoppingListMember())
+	if err != nil {
+		return err
+	}
+
+	var id int64
+	err = stmt.QueryRowContext(ctx, slId, accId).Scan(&id)
+
+	return err
+}
+
+// TransferShoppingListOwnership makes the member the owner of the list. The
+// previous owner stays in the list as an editor.
+func (c *Connect) TransferShoppingListOwnership(ctx context.Context, slId int64, fromId int64, toId int64) (err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	roleStmt, err := tx.PrepareContext(ctx, updateShoppingListMemberRole())
+	if err != nil {
+		return err
+	}
+
+	var id int64
+	if err = roleStmt.QueryRowContext(ctx, products.ListRoleOwner, slId, toId).Scan(&id); err != nil {
+		return err
+	}
+	if err = roleStmt.QueryRowContext(ctx, products.ListRoleEditor, slId, fromId).Scan(&id); err != nil {
+		return err
+	}
+
+	ownerStmt, err := tx.PrepareContext(ctx, updateShoppingListOwner())
+	if err != nil {
+		return err
+	}
+
+	// the new owner may have a list with the same name
+	if err = ownerStmt.QueryRowContext(ctx, toId, slId).Scan(&id); err != nil {
+		return mapError(err, "the new owner already has a list with the same name")
+	}
+
+	return nil
+}
+
+func (c *Connect) CreateListInvite(ctx context.Context, invite products.ListInvite) error {
+	stmt, err := c.sql.PrepareContext(ctx, createListInvite())
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, invite.Code, invite.ShoppingListId, invite.Role, invite.CreatedBy, invite.ExpireAt)
+
+	return err
+}
+
+// AcceptListInvite adds the account to the list of the invite and returns the
+// list with the role of the account. Existing members keep their role, which
+// is reported with false. A missing or expired invite is sql.ErrNoRows.
+func (c *Connect) AcceptListInvite(ctx context.Context, code string, accId int64) (sl products.ShoppingList, joined bool, err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return sl, false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	inviteStmt, err := tx.PrepareContext(ctx, findNotExpiredListInvite())
+	if err != nil {
+		return sl, false, err
+	}
+
+	invite := products.ListInvite{}
+	err = inviteStmt.
+		QueryRowContext(ctx, code).
+		Scan(&invite.Code, &invite.ShoppingListId, &invite.Role, &invite.CreatedBy, &invite.ExpireAt)
+	if err != nil {
+		return sl, false, err
+	}
+
+	memberStmt, err := tx.PrepareContext(ctx, createShoppingListMember())
+	if err != nil {
+		return sl, false, err
+	}
+
+	res, err := memberStmt.ExecContext(ctx, invite.ShoppingListId, accId, invite.Role)
+	if err != nil {
+		return sl, false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return sl, false, err
+	}
+
+	slStmt, err := tx.PrepareContext(ctx, findShoppingListByIdForAccount())
+	if err != nil {
+		return sl, false, err
+	}
+
+	err = slStmt.QueryRowContext(ctx, invite.ShoppingListId, accId).
+		Scan(&sl.ID, &sl.Name, &sl.AccountId, &sl.Role, &sl.ArchivedAt, &sl.DeletedAt)
+
+	return sl, affected > 0, err
+}
+
+func (c *Connect) FindProductById(ctx context.Context, id int64) (products.Product, error) {
+	p := products.Product{}
+
+	stmt, err := c.sql.PrepareContext(ctx, findProductByIdOrBarcode())
+	if err != nil {
+		return p, err
+	}
+
+	err = stmt.QueryRowContext(ctx, id, idAsBarcode(id)).Scan(&p.ID, &p.Name, &p.Upcean, &p.CategoryId, &p.BrandId)
+
+	return p, err
+}
+
+// idAsBarcode reads a number passed as a product id or a barcode as a GTIN.
+// Numbers which are not a valid barcode don't match any product.
+func idAsBarcode(n int64) barcode.GTIN {
+	code, _ := barcode.GTINFromInt64(n)
+
+	return code
+}
+
+// GetShoppingListSubscribers returns accounts with access to the list that
+// have not muted its notifications.
+func (c *Connect) GetShoppingListSubscribers(ctx context.Context, slId int64) ([]int64, error) {
+	stmt, err := c.sql.PrepareContext(ctx, getShoppingListSubscribers())
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := stmt.QueryContext(ctx, slId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(r *sql.Rows) {
+		if rErr := r.Close(); rErr != nil {
+			err = rErr
+		}
+	}(r)
+
+	var ids []int64
+	for r.Next() {
+		var id int64
+		if err = r.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = r.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, err
+}
+
+func (c *Connect) MuteShoppingList(ctx context.Context, slId int64, accountId int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, muteShoppingList())
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, slId, accountId)
+
+	return err
+}
+
+func (c *Connect) UnmuteShoppingList(ctx context.Context, slId int64, accountId int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, unmuteShoppingList())
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, slId, accountId)
+
+	return err
+}
+
 func (c *Connect) GetStatistic(ctx context.Context, from time.Time, to time.Time) ([]stat.Statistic, error) {
 	stmt, err := c.sql.PrepareContext(ctx, getStatistic())
 	if err != nil {
@@ -548,3 +1602,256 @@ func (c *Connect) AddedUpdStatisticByShoppingList(ctx context.Context, listId in
 
 	return err
 }
+
+func (c *Connect) FindDialog(ctx context.Context, chatId int64) (dialog.Dialog, error) {
+	d := dialog.Dialog{}
+
+	stmt, err := c.sql.PrepareContext(ctx, findDialogByChatId())
+	if err != nil {
+		return d, err
+	}
+
+	var data []byte
+	err = stmt.
+		QueryRowContext(ctx, chatId).
+		Scan(&d.ChatID, &d.State, &data, &d.ExpireAt, &d.UpdatedAt)
+	if err != nil {
+		return d, err
+	}
+
+	err = d.DecodeData(data)
+
+	return d, err
+}
+
+func (c *Connect) SaveDialog(ctx context.Context, d dialog.Dialog) error {
+	stmt, err := c.sql.PrepareContext(ctx, saveDialog())
+	if err != nil {
+		return err
+	}
+
+	data, err := d.EncodeData()
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, d.ChatID, d.State, string(data), d.ExpireAt)
+
+	return err
+}
+
+func (c *Connect) DeleteDialog(ctx context.Context, chatId int64) error {
+	stmt, err := c.sql.PrepareContext(ctx, deleteDialogByChatId())
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, chatId)
+
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubmission(r rowScanner) (products.Submission, error) {
+	s := products.Submission{}
+	err := r.Scan(
+		&s.ID,
+		&s.Upcean,
+		&s.Name,
+		&s.BrandName,
+		&s.CategoryName,
+		&s.AccountId,
+		&s.Status,
+		&s.Reason,
+		&s.ProductId,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+
+	return s, err
+}
+
+func (c *Connect) CreateSubmission(ctx context.Context, s products.Submission) (products.Submission, error) {
+	stmt, err := c.sql.PrepareContext(ctx, createSubmission())
+	if err != nil {
+		return s, err
+	}
+
+	err = stmt.
+		QueryRowContext(ctx, s.Upcean, s.Name, s.BrandName, s.CategoryName, s.AccountId).
+		Scan(&s.ID, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return s, mapError(err, fmt.Sprintf("product with barcode %s is already submitted", s.Upcean.Short()))
+	}
+
+	return s, nil
+}
+
+func (c *Connect) FindSubmissionById(ctx context.Context, id int64) (products.Submission, error) {
+	stmt, err := c.sql.PrepareContext(ctx, findSubmissionById())
+	if err != nil {
+		return products.Submission{}, err
+	}
+
+	return scanSubmission(stmt.QueryRowContext(ctx, id))
+}
+
+func (c *Connect) GetSubmissionsByStatus(ctx context.Context, status string) ([]products.Submission, error) {
+	stmt, err := c.sql.PrepareContext(ctx, getSubmissionsByStatus())
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := stmt.QueryContext(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(r *sql.Rows) {
+		if rErr := r.Close(); rErr != nil {
+			err = rErr
+		}
+	}(r)
+
+	var sList []products.Submission
+	for r.Next() {
+		s, err := scanSubmission(r)
+		if err != nil {
+			return nil, err
+		}
+
+		sList = append(sList, s)
+	}
+
+	if err = r.Err(); err != nil {
+		return nil, err
+	}
+
+	return sList, err
+}
+
+// UpdateSubmission lets a moderator fix a pending submission before approving it.
+func (c *Connect) UpdateSubmission(ctx context.Context, s products.Submission) (products.Submission, error) {
+	stmt, err := c.sql.PrepareContext(ctx, updatePendingSubmission())
+	if err != nil {
+		return s, err
+	}
+
+	var id int64
+	err = stmt.
+		QueryRowContext(ctx, s.Upcean, s.Name, s.BrandName, s.CategoryName, s.ID).
+		Scan(&id)
+	if err != nil {
+		return s, mapError(err, fmt.Sprintf("product with barcode %s is already submitted", s.Upcean.Short()))
+	}
+
+	return c.FindSubmissionById(ctx, id)
+}
+
+// ApproveSubmission adds the submitted product to the catalog. Brand and
+// category are created when they don't exist yet.
+func (c *Connect) ApproveSubmission(ctx context.Context, id int64) (s products.Submission, err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return s, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if s, err = c.lockPendingSubmission(ctx, tx, id); err != nil {
+		return s, err
+	}
+
+	brandStmt, err := tx.PrepareContext(ctx, CreateBrand())
+	if err != nil {
+		return s, err
+	}
+
+	var brandId int64
+	if err = brandStmt.QueryRowContext(ctx, s.BrandName).Scan(&brandId); err != nil {
+		return s, err
+	}
+
+	categoryStmt, err := tx.PrepareContext(ctx, CreateCategory())
+	if err != nil {
+		return s, err
+	}
+
+	var categoryId int64
+	if err = categoryStmt.QueryRowContext(ctx, s.CategoryName).Scan(&categoryId); err != nil {
+		return s, err
+	}
+
+	productStmt, err := tx.PrepareContext(ctx, CreateProduct())
+	if err != nil {
+		return s, err
+	}
+
+	err = productStmt.QueryRowContext(ctx, s.Name, s.Upcean, categoryId, brandId).Scan(&s.ProductId)
+	if err != nil {
+		err = mapError(err, fmt.Sprintf("product with barcode %s already exists", s.Upcean.Short()))
+		return s, err
+	}
+
+	s.Status = products.SubmissionApproved
+	s.Reason = ""
+	err = c.resolveSubmission(ctx, tx, s)
+
+	return s, err
+}
+
+func (c *Connect) RejectSubmission(ctx context.Context, id int64, reason string) (s products.Submission, err error) {
+	tx, err := c.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return s, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if s, err = c.lockPendingSubmission(ctx, tx, id); err != nil {
+		return s, err
+	}
+
+	s.Status = products.SubmissionRejected
+	s.Reason = reason
+	err = c.resolveSubmission(ctx, tx, s)
+
+	return s, err
+}
+
+// resolveSubmission stores the status, the reason and the product of a
+// reviewed submission.
+func (c *Connect) resolveSubmission(ctx context.Context, tx *sql.Tx, s products.Submission) error {
+	stmt, err := tx.PrepareContext(ctx, resolveSubmission())
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, s.Status, s.Reason, s.ProductId, s.ID)
+
+	return err
+}
+
+// lockPendingSubmission returns sql.ErrNoRows for submissions that were
+// already reviewed, so two moderators can't resolve the same one.
+func (c *Connect) lockPendingSubmission(ctx context.Context, tx *sql.Tx, id int64) (products.Submission, error) {
+	stmt, err := tx.PrepareContext(ctx, findPendingSubmissionByIdForUpdate())
+	if err != nil {
+		return products.Submission{}, err
+	}
+
+	return scanSubmission(stmt.QueryRowContext(ctx, id))
+}
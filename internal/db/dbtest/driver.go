@@ -0,0 +1,207 @@
+// Package dbtest provides a database/sql driver answering the queries of
+// db.Connect with canned rows, so code using the database runs in tests
+// without postgres.
+package dbtest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"bybarcode/internal/db"
+)
+
+// DriverName is the name the driver is registered with.
+const DriverName = "dbtest"
+
+func init() {
+	sql.Register(DriverName, fakeDriver{})
+}
+
+// dbs maps the dsn passed to db.NewConnect to the database of a test.
+var dbs sync.Map
+
+type query struct {
+	query string
+	args  []driver.Value
+}
+
+// DB records the queries of a test and answers them.
+type DB struct {
+	mu      sync.Mutex
+	answers map[string][][]driver.Value
+	errors  map[string]error
+	queries []query
+}
+
+// New registers a database for the test and connects to it, the test name is
+// its dsn.
+func New(t testing.TB) (*DB, db.Connect) {
+	t.Helper()
+
+	f := &DB{answers: map[string][][]driver.Value{}, errors: map[string]error{}}
+	dbs.Store(t.Name(), f)
+	t.Cleanup(func() { dbs.Delete(t.Name()) })
+
+	conn, err := db.NewConnect(DriverName, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return f, conn
+}
+
+// On makes queries containing match return the rows. Queries without an
+// answer return no rows, so QueryRow gets sql.ErrNoRows, and Exec succeeds.
+func (f *DB) On(match string, rows ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.answers[match] = rows
+}
+
+// Fail makes queries containing match fail with err, e.g. a *pgconn.PgError.
+func (f *DB) Fail(match string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.errors[match] = err
+}
+
+// Executed returns the arguments of the queries containing match.
+func (f *DB) Executed(match string) [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var args [][]driver.Value
+	for _, q := range f.queries {
+		if strings.Contains(q.query, match) {
+			args = append(args, q.args)
+		}
+	}
+
+	return args
+}
+
+func (f *DB) run(q string, args []driver.Value) ([][]driver.Value, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queries = append(f.queries, query{query: q, args: args})
+	for match, err := range f.errors {
+		if strings.Contains(q, match) {
+			return nil, err
+		}
+	}
+	for match, rows := range f.answers {
+		if strings.Contains(q, match) {
+			return rows, nil
+		}
+	}
+
+	return nil, nil
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	f, ok := dbs.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("fake database %q is not registered", dsn)
+	}
+
+	return fakeConn{db: f.(*DB)}, nil
+}
+
+type fakeConn struct {
+	db *DB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	db    *DB
+	query string
+}
+
+func (s fakeStmt) Close() error {
+	return nil
+}
+
+func (s fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, err := s.db.run(s.query, args); err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{rows: rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+
+	columns := make([]string, len(r.rows[0]))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("column%d", i)
+	}
+
+	return columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrNotFound is sql.ErrNoRows, so callers can check either of them.
+	ErrNotFound = sql.ErrNoRows
+	// ErrConflict means the row clashes with an existing one, e.g. a list with
+	// the same name.
+	ErrConflict = errors.New("conflict")
+	// ErrValidation means the database rejected a value, e.g. a too long name
+	// or a reference to a missing row.
+	ErrValidation = errors.New("validation failed")
+)
+
+// ErrRefreshTokenReused means a refresh token was presented after it had
+// already been exchanged. The login it belongs to is revoked.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation        = "23505"
+	pgForeignKeyViolation    = "23503"
+	pgNotNullViolation       = "23502"
+	pgCheckViolation         = "23514"
+	pgStringDataTruncation   = "22001"
+	pgNumericValueOutOfRange = "22003"
+	pgInvalidTextFormat      = "22P02"
+)
+
+// Error is an ErrConflict or ErrValidation with a message which can be shown
+// to the client. It unwraps to the driver error.
+type Error struct {
+	Kind    error
+	Message string
+	// Details names the constraint or the column the database complained about.
+	Details map[string]string
+	Err     error
+}
+
+// Error returns the message only, the driver error is available via Unwrap.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// mapError turns postgres constraint errors of inserts and updates into
+// ErrConflict and ErrValidation. conflict is the message for unique
+// violations, other errors are returned as they are. Deletes use
+// mapDeleteError.
+func mapError(err error, conflict string) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	details := errorDetails(pgErr)
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return &Error{Kind: ErrConflict, Message: conflict, Details: details, Err: err}
+	case pgForeignKeyViolation:
+		return &Error{Kind: ErrValidation, Message: "referenced row does not exist", Details: details, Err: err}
+	case pgNotNullViolation, pgCheckViolation, pgStringDataTruncation, pgNumericValueOutOfRange, pgInvalidTextFormat:
+		return &Error{Kind: ErrValidation, Message: pgErr.Message, Details: details, Err: err}
+	}
+
+	return err
+}
+
+// mapDeleteError turns a foreign key violation of a delete into ErrConflict
+// with the referenced message: the row is still used by another one, nothing
+// is wrong with the request itself.
+func mapDeleteError(err error, referenced string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return &Error{Kind: ErrConflict, Message: referenced, Details: errorDetails(pgErr), Err: err}
+	}
+
+	return mapError(err, "")
+}
+
+func errorDetails(pgErr *pgconn.PgError) map[string]string {
+	details := map[string]string{}
+	if pgErr.ConstraintName != "" {
+		details["constraint"] = pgErr.ConstraintName
+	}
+	if pgErr.ColumnName != "" {
+		details["column"] = pgErr.ColumnName
+	}
+
+	return details
+}
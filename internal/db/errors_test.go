@@ -0,0 +1,84 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		kind    error
+		message string
+	}{
+		{name: "unique", err: &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "uniq_name"}, kind: ErrConflict, message: "exists"},
+		{name: "foreign key", err: &pgconn.PgError{Code: pgForeignKeyViolation}, kind: ErrValidation, message: "referenced row does not exist"},
+		{name: "check", err: &pgconn.PgError{Code: pgCheckViolation, Message: "quantity must be positive"}, kind: ErrValidation, message: "quantity must be positive"},
+		{name: "too long", err: &pgconn.PgError{Code: pgStringDataTruncation, Message: "value too long"}, kind: ErrValidation, message: "value too long"},
+		{name: "wrapped", err: fmt.Errorf("insert: %w", &pgconn.PgError{Code: pgUniqueViolation}), kind: ErrConflict, message: "exists"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mapError(tt.err, "exists")
+
+			var dbErr *Error
+			if !errors.As(err, &dbErr) {
+				t.Fatalf("err = %v, want *Error", err)
+			}
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("kind = %v, want %v", dbErr.Kind, tt.kind)
+			}
+			if dbErr.Message != tt.message {
+				t.Errorf("message = %q, want %q", dbErr.Message, tt.message)
+			}
+
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) {
+				t.Error("driver error is not unwrapped")
+			}
+		})
+	}
+}
+
+func TestMapErrorKeepsOtherErrors(t *testing.T) {
+	for _, err := range []error{
+		ErrNotFound,
+		errors.New("connection refused"),
+		&pgconn.PgError{Code: "08006"},
+	} {
+		if got := mapError(err, "exists"); got != err {
+			t.Errorf("mapError(%v) = %v, want the error as it is", err, got)
+		}
+	}
+}
+
+func TestMapErrorDetails(t *testing.T) {
+	err := mapError(&pgconn.PgError{Code: pgNotNullViolation, ColumnName: "name", ConstraintName: "name_not_null"}, "")
+
+	var dbErr *Error
+	if !errors.As(err, &dbErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if dbErr.Details["column"] != "name" || dbErr.Details["constraint"] != "name_not_null" {
+		t.Errorf("details = %v, want the column and the constraint", dbErr.Details)
+	}
+}
+
+func TestMapDeleteError(t *testing.T) {
+	err := mapDeleteError(&pgconn.PgError{Code: pgForeignKeyViolation}, "product is used in lists")
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("err = %v, want ErrConflict", err)
+	}
+	if err.Error() != "product is used in lists" {
+		t.Errorf("message = %q, want the referenced message", err.Error())
+	}
+
+	if err := mapDeleteError(&pgconn.PgError{Code: pgCheckViolation}, "used"); !errors.Is(err, ErrValidation) {
+		t.Errorf("err = %v, want ErrValidation like mapError", err)
+	}
+}
@@ -47,27 +47,142 @@ WHERE NOT EXISTS (
 }
 
 func findAccount() string {
-	return `SELECT * FROM account where id = $1`
+	return `SELECT id, first_name, last_name, username, role FROM account where id = $1`
+}
+
+func updateAccountRole() string {
+	query := `
+	UPDATE account
+	SET role = $1
+	WHERE id = $2
+	RETURNING id;
+`
+	return strings.Trim(query, " ")
 }
 
 func CreateSession() string {
 	query := `
-        INSERT INTO sessions (id, token, refresh_token, account_id, expire_at, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)`
+        INSERT INTO sessions (id, token_hash, refresh_token_hash, account_id, family_id, expire_at, refresh_expire_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
 	return strings.Trim(query, " ")
 }
 
-func findNotExpiredSessionByAccountId() string {
+func findNotExpiredSessionByToken() string {
 	query := `
-	SELECT * FROM sessions WHERE account_id = $1 AND expire_at > now()
+	SELECT id, token_hash, refresh_token_hash, account_id, family_id, expire_at, refresh_expire_at, created_at, updated_at
+	FROM sessions
+	WHERE token_hash = $1 AND expire_at > now() AND revoked_at IS NULL
 `
 	return strings.Trim(query, " ")
 }
 
-func findNotExpiredSessionByToken() string {
+// findSessionByRefreshTokenForUpdate also returns revoked sessions, so reuse
+// of a rotated refresh token can be detected.
+func findSessionByRefreshTokenForUpdate() string {
+	query := `
+	SELECT id, token_hash, refresh_token_hash, account_id, family_id, expire_at, refresh_expire_at, created_at, updated_at,
+		rotated_at IS NOT NULL, revoked_at IS NOT NULL
+	FROM sessions
+	WHERE refresh_token_hash = $1
+	FOR UPDATE
+`
+	return strings.Trim(query, " ")
+}
+
+func rotateSession() string {
+	query := `
+	UPDATE sessions
+	SET rotated_at = now(), revoked_at = now(), updated_at = now()
+	WHERE id = $1
+`
+	return strings.Trim(query, " ")
+}
+
+func revokeSessionFamily() string {
+	query := `
+	UPDATE sessions
+	SET revoked_at = now(), updated_at = now()
+	WHERE account_id = $1 AND family_id = $2 AND revoked_at IS NULL
+`
+	return strings.Trim(query, " ")
+}
+
+func revokeOtherSessionFamilies() string {
+	query := `
+	UPDATE sessions
+	SET revoked_at = now(), updated_at = now()
+	WHERE account_id = $1 AND family_id <> $2 AND revoked_at IS NULL
+`
+	return strings.Trim(query, " ")
+}
+
+func getActiveSessionsByAccountId() string {
+	query := `
+	SELECT s.family_id, f.created_at, s.created_at, s.refresh_expire_at
+	FROM sessions s
+	JOIN (
+		SELECT family_id, MIN(created_at) AS created_at FROM sessions WHERE account_id = $1 GROUP BY family_id
+	) f ON f.family_id = s.family_id
+	WHERE s.account_id = $1 AND s.revoked_at IS NULL AND s.refresh_expire_at > now()
+	ORDER BY s.created_at DESC
+`
+	return strings.Trim(query, " ")
+}
+
+// purgeExpiredSessions deletes sessions which can't be refreshed anymore.
+// Rotated sessions are kept until then to detect refresh token reuse.
+func purgeExpiredSessions() string {
 	query := `
-	SELECT * FROM sessions WHERE token = $1 AND expire_at > now()
+	DELETE FROM sessions
+	WHERE refresh_expire_at < now()
+`
+	return strings.Trim(query, " ")
+}
+
+func createApiKey() string {
+	query := `
+	INSERT INTO api_keys (account_id, name, key_hash, scopes, expire_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+`
+	return strings.Trim(query, " ")
+}
+
+func findNotExpiredApiKeyByHash() string {
+	query := `
+	SELECT id, account_id, name, key_hash, scopes, expire_at, last_used_at, created_at
+	FROM api_keys
+	WHERE key_hash = $1 AND (expire_at IS NULL OR expire_at > now())
+`
+	return strings.Trim(query, " ")
+}
+
+func getApiKeysByAccountId() string {
+	query := `
+	SELECT id, account_id, name, key_hash, scopes, expire_at, last_used_at, created_at
+	FROM api_keys
+	WHERE account_id = $1
+	ORDER BY created_at
+`
+	return strings.Trim(query, " ")
+}
+
+// touchApiKey updates last_used_at at most once a minute, so busy scripts
+// don't write on every request.
+func touchApiKey() string {
+	query := `
+	UPDATE api_keys
+	SET last_used_at = now()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - INTERVAL '1 minute')
+`
+	return strings.Trim(query, " ")
+}
+
+func deleteApiKey() string {
+	query := `
+	DELETE FROM api_keys
+	WHERE id = $1 AND account_id = $2
 `
 	return strings.Trim(query, " ")
 }
@@ -83,7 +198,16 @@ func findProductByBarcode() string {
 }
 
 func findProductByIdOrBarcode() string {
-	return `SELECT id, name, upcean, category_id, brand_id FROM products WHERE id = $1 OR upcean = $1`
+	return `SELECT id, name, upcean, category_id, brand_id FROM products WHERE id = $1 OR upcean = $2`
+}
+
+func productReferencesExist() string {
+	query := `
+	SELECT
+	    EXISTS(SELECT 1 FROM categories WHERE id = $1),
+	    EXISTS(SELECT 1 FROM brands WHERE id = $2);
+`
+	return strings.Trim(query, " ")
 }
 
 func updateProductById() string {
@@ -96,6 +220,23 @@ func updateProductById() string {
 	return strings.Trim(query, " ")
 }
 
+// lockProductById keeps the product from being added to a list until it is
+// deleted, adding takes a key share lock on it.
+func lockProductById() string {
+	return `SELECT id FROM products WHERE id = $1 FOR UPDATE`
+}
+
+// getProductShoppingLists returns the lists the product is on, including the
+// deleted ones which can still be restored.
+func getProductShoppingLists() string {
+	query := `
+	SELECT DISTINCT slp.shopping_list_id FROM shopping_list__products slp
+	WHERE slp.product_id = $1
+	ORDER BY slp.shopping_list_id
+`
+	return strings.Trim(query, " ")
+}
+
 func deleteProductById() string {
 	query := `
 	DELETE FROM products
@@ -119,38 +260,319 @@ func updateShoppingListById() string {
 	query := `
 	UPDATE shopping_lists
 	SET name = $1
-	WHERE id = $2
+	WHERE id = $2 AND deleted_at IS NULL
 	RETURNING id;
 `
 	return strings.Trim(query, " ")
 }
 
 func findShoppingListById() string {
+	return `SELECT id, name, account_id FROM shopping_lists WHERE id = $1 AND deleted_at IS NULL`
+}
+
+// findNotPurgedShoppingListById finds deleted lists too, until they are purged.
+func findNotPurgedShoppingListById() string {
 	return `SELECT id, name, account_id FROM shopping_lists WHERE id = $1`
 }
 
+// getShoppingListsByAccount returns archived lists when $2 is true and the
+// lists deleted after $3, a null $3 leaves deleted lists out.
 func getShoppingListsByAccount() string {
-	return `SELECT id, name FROM shopping_lists WHERE account_id = $1`
+	query := `
+	SELECT sl.id, sl.name, sl.account_id, m.role, sl.archived_at, sl.deleted_at FROM shopping_lists sl
+	JOIN shopping_list_members m ON m.shopping_list_id = sl.id
+	WHERE m.account_id = $1
+	  AND (sl.archived_at IS NULL OR $2)
+	  AND (sl.deleted_at IS NULL OR sl.deleted_at > $3)
+	ORDER BY sl.id
+`
+	return strings.Trim(query, " ")
 }
 
+func findShoppingListByIdForAccount() string {
+	query := `
+	SELECT sl.id, sl.name, sl.account_id, m.role, sl.archived_at, sl.deleted_at FROM shopping_lists sl
+	JOIN shopping_list_members m ON m.shopping_list_id = sl.id
+	WHERE sl.id = $1 AND m.account_id = $2 AND sl.deleted_at IS NULL
+`
+	return strings.Trim(query, " ")
+}
+
+func findDeletedShoppingListByIdForAccount() string {
+	query := `
+	SELECT sl.id, sl.name, sl.account_id, m.role, sl.archived_at, sl.deleted_at FROM shopping_lists sl
+	JOIN shopping_list_members m ON m.shopping_list_id = sl.id
+	WHERE sl.id = $1 AND m.account_id = $2 AND sl.deleted_at > $3
+`
+	return strings.Trim(query, " ")
+}
+
+// deleteShoppingListById only marks the list as deleted, it can be restored
+// until it is purged.
 func deleteShoppingListById() string {
+	query := `
+	UPDATE shopping_lists
+	SET deleted_at = now()
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING id;
+`
+	return strings.Trim(query, " ")
+}
+
+func restoreShoppingListById() string {
+	query := `
+	UPDATE shopping_lists
+	SET deleted_at = NULL
+	WHERE id = $1 AND deleted_at > $2
+	RETURNING id;
+`
+	return strings.Trim(query, " ")
+}
+
+func purgeDeletedShoppingLists() string {
 	query := `
 	DELETE FROM shopping_lists
-	WHERE id = $1
+	WHERE deleted_at <= $1
+`
+	return strings.Trim(query, " ")
+}
+
+// archiveShoppingListById keeps the time a list was archived first.
+func archiveShoppingListById() string {
+	query := `
+	UPDATE shopping_lists
+	SET archived_at = COALESCE(archived_at, now())
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING archived_at;
+`
+	return strings.Trim(query, " ")
+}
+
+func unarchiveShoppingListById() string {
+	query := `
+	UPDATE shopping_lists
+	SET archived_at = NULL
+	WHERE id = $1 AND deleted_at IS NULL
 	RETURNING id;
 `
 	return strings.Trim(query, " ")
 }
 
+// addProductToShoppingList adds the quantity to a product which is on the list
+// already, the product has to be bought again then. A product on the list in
+// another unit is left as it is and no row is returned.
 func addProductToShoppingList() string {
-	return `INSERT INTO shopping_list__products (shopping_list_id, product_id) VALUES ($1, $2)`
+	query := `
+	INSERT INTO shopping_list__products AS slp (shopping_list_id, product_id, quantity, unit, note)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (shopping_list_id, product_id) DO UPDATE
+	SET quantity = slp.quantity + EXCLUDED.quantity,
+	    note     = CASE WHEN EXCLUDED.note = '' THEN slp.note ELSE EXCLUDED.note END,
+	    checked  = false
+	WHERE slp.unit = EXCLUDED.unit
+	RETURNING slp.id;
+`
+	return strings.Trim(query, " ")
+}
+
+// resolveTextItem links the first unchecked free-text item with the same name
+// as the product to the product. Names are compared ignoring case and extra
+// spaces. Items with a category only match products of that category. The
+// quantity and the note are merged like in addProductToShoppingList, an item
+// in another unit is found but not resolved. Nothing is found when the
+// product is on the list already. It returns the item id and whether the item
+// was resolved.
+func resolveTextItem() string {
+	query := `
+	WITH item AS (
+		SELECT item.id, item.unit
+		FROM shopping_list__products item
+		WHERE item.shopping_list_id = $1 AND item.product_id IS NULL AND NOT item.checked
+		  AND lower(regexp_replace(btrim(item.name), '\s+', ' ', 'g')) = lower(regexp_replace(btrim($3), '\s+', ' ', 'g'))
+		  AND (item.category_id IS NULL OR item.category_id = $4)
+		  AND NOT EXISTS (
+			SELECT 1 FROM shopping_list__products added
+			WHERE added.shopping_list_id = $1 AND added.product_id = $2
+		  )
+		ORDER BY item.id
+		LIMIT 1
+		FOR UPDATE
+	), resolved AS (
+		UPDATE shopping_list__products AS slp
+		SET product_id  = $2,
+		    name        = NULL,
+		    category_id = NULL,
+		    quantity    = slp.quantity + $6,
+		    note        = CASE WHEN $7 = '' THEN slp.note ELSE $7 END
+		FROM item
+		WHERE slp.id = item.id AND item.unit = $5
+	)
+	SELECT id, unit = $5 FROM item;
+`
+	return strings.Trim(query, " ")
+}
+
+func addTextItemToShoppingList() string {
+	query := `
+	INSERT INTO shopping_list__products (shopping_list_id, name, category_id, quantity, unit, note)
+	VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6)
+	RETURNING id;
+`
+	return strings.Trim(query, " ")
+}
+
+func deleteShoppingListItem() string {
+	query := `
+	DELETE FROM shopping_list__products
+	WHERE shopping_list_id = $1 AND id = $2
+	RETURNING COALESCE(product_id, 0), COALESCE(name, '');
+`
+	return strings.Trim(query, " ")
+}
+
+func toggleShoppingListItemState() string {
+	query := `
+	UPDATE shopping_list__products SET checked = NOT checked
+	WHERE shopping_list_id = $1 AND id = $2
+	RETURNING COALESCE(product_id, 0), COALESCE(name, ''), checked;
+`
+	return strings.Trim(query, " ")
+}
+
+func updateShoppingListItemById() string {
+	query := `
+	UPDATE shopping_list__products
+	SET quantity = COALESCE($3, quantity),
+	    unit     = COALESCE($4, unit),
+	    note     = COALESCE($5, note)
+	WHERE shopping_list_id = $1 AND id = $2
+	RETURNING COALESCE(product_id, 0), COALESCE(name, '');
+`
+	return strings.Trim(query, " ")
 }
 
+// copyShoppingListItems copies the items of list $1 to list $2, only the
+// unchecked ones when $3 is true. The copies are unchecked.
+func copyShoppingListItems() string {
+	query := `
+	INSERT INTO shopping_list__products (shopping_list_id, product_id, name, category_id, quantity, unit, note)
+	SELECT $2, product_id, name, category_id, quantity, unit, note
+	FROM shopping_list__products
+	WHERE shopping_list_id = $1 AND (NOT checked OR NOT $3)
+	ORDER BY id
+`
+	return strings.Trim(query, " ")
+}
+
+// mergeUnitConflicts returns the names of the items of list $2 which are on
+// list $1 in another unit, so their quantities can't be summed. Text items are
+// matched by the name like in resolveTextItem and by the category.
+func mergeUnitConflicts() string {
+	query := `
+	SELECT DISTINCT COALESCE(p.name, source.name)
+	FROM shopping_list__products source
+	JOIN shopping_list__products target ON target.shopping_list_id = $1 AND (
+		target.product_id = source.product_id
+		OR target.product_id IS NULL AND source.product_id IS NULL
+		   AND lower(regexp_replace(btrim(target.name), '\s+', ' ', 'g')) = lower(regexp_replace(btrim(source.name), '\s+', ' ', 'g'))
+		   AND target.category_id IS NOT DISTINCT FROM source.category_id
+	)
+	LEFT JOIN products p ON p.id = source.product_id
+	WHERE source.shopping_list_id = $2 AND source.unit <> target.unit
+	ORDER BY 1
+`
+	return strings.Trim(query, " ")
+}
+
+// mergeShoppingListItems adds the products of list $2 to list $1. Quantities of
+// a product on both lists are summed, mergeUnitConflicts has to be empty. The
+// product stays checked only if it was checked on both lists.
+func mergeShoppingListItems() string {
+	query := `
+	INSERT INTO shopping_list__products AS slp (shopping_list_id, product_id, quantity, unit, note, checked)
+	SELECT $1, product_id, quantity, unit, note, checked
+	FROM shopping_list__products
+	WHERE shopping_list_id = $2 AND product_id IS NOT NULL
+	ORDER BY id
+	ON CONFLICT (shopping_list_id, product_id) DO UPDATE
+	SET quantity = slp.quantity + EXCLUDED.quantity,
+	    note     = CASE WHEN slp.note = '' THEN EXCLUDED.note ELSE slp.note END,
+	    checked  = slp.checked AND EXCLUDED.checked;
+`
+	return strings.Trim(query, " ")
+}
+
+// mergeShoppingListTextItems adds the free-text items of list $2 to list $1
+// the way mergeShoppingListItems adds products. Items with the same name and
+// category are one item: their quantities are summed into the first such item
+// of list $1, or they are copied as one item when list $1 has none.
+func mergeShoppingListTextItems() string {
+	query := `
+	WITH source AS (
+		SELECT lower(regexp_replace(btrim(name), '\s+', ' ', 'g')) AS key, category_id, unit,
+		       min(id) AS first_id, sum(quantity) AS quantity, bool_and(checked) AS checked,
+		       COALESCE((array_agg(note ORDER BY id) FILTER (WHERE note <> ''))[1], '') AS note
+		FROM shopping_list__products
+		WHERE shopping_list_id = $2 AND product_id IS NULL
+		GROUP BY 1, 2, 3
+	), target AS (
+		SELECT DISTINCT ON (key, category_id) id, key, category_id
+		FROM (
+			SELECT id, lower(regexp_replace(btrim(name), '\s+', ' ', 'g')) AS key, category_id
+			FROM shopping_list__products
+			WHERE shopping_list_id = $1 AND product_id IS NULL
+		) items
+		ORDER BY key, category_id, id
+	), merged AS (
+		UPDATE shopping_list__products AS slp
+		SET quantity = slp.quantity + source.quantity,
+		    note     = CASE WHEN slp.note = '' THEN source.note ELSE slp.note END,
+		    checked  = slp.checked AND source.checked
+		FROM target
+		JOIN source ON source.key = target.key AND source.category_id IS NOT DISTINCT FROM target.category_id
+		WHERE slp.id = target.id
+	)
+	INSERT INTO shopping_list__products (shopping_list_id, name, category_id, quantity, unit, note, checked)
+	SELECT $1, item.name, source.category_id, source.quantity, source.unit, source.note, source.checked
+	FROM source
+	JOIN shopping_list__products item ON item.id = source.first_id
+	WHERE NOT EXISTS (
+		SELECT 1 FROM target
+		WHERE target.key = source.key AND target.category_id IS NOT DISTINCT FROM source.category_id
+	)
+	ORDER BY source.first_id
+`
+	return strings.Trim(query, " ")
+}
+
+func moveShoppingListItems() string {
+	query := `
+	UPDATE shopping_list__products
+	SET shopping_list_id = $2
+	WHERE shopping_list_id = $1 AND id = ANY($3)
+`
+	return strings.Trim(query, " ")
+}
+
+func findShoppingListItemIdByProduct() string {
+	query := `
+	SELECT id FROM shopping_list__products
+	WHERE shopping_list_id = $1 AND product_id = $2
+`
+	return strings.Trim(query, " ")
+}
+
+// getShoppingListProducts returns free-text items with an empty barcode and
+// zero product and brand ids.
 func getShoppingListProducts() string {
 	query := `
-	SELECT p.id, p.name, p.upcean, p.category_id, p.brand_id, slp.checked FROM products p
-	JOIN shopping_list__products slp on p.id = slp.product_id
+	SELECT slp.id, COALESCE(p.id, 0), COALESCE(p.name, slp.name), COALESCE(p.upcean, ''),
+	       COALESCE(p.category_id, slp.category_id, 0), COALESCE(p.brand_id, 0),
+	       slp.quantity, slp.unit, slp.note, slp.checked
+	FROM shopping_list__products slp
+	LEFT JOIN products p on p.id = slp.product_id
 	WHERE slp.shopping_list_id = $1
+	ORDER BY slp.id
 `
 
 	return strings.Trim(query, " ")
@@ -168,7 +590,7 @@ func toggleProductStateInList() string {
 	query := `
 	UPDATE shopping_list__products SET checked = NOT checked
 	WHERE shopping_list_id = $1 AND product_id = $2
-	RETURNING shopping_list_id, product_id
+	RETURNING checked
 `
 
 	return strings.Trim(query, " ")
@@ -181,27 +603,200 @@ func updateStatByAddingProduct() string {
 		FROM shopping_list__products 
 		WHERE shopping_list_id = $1
 	)
-	INSERT INTO shopping_list_statistics (shopping_list_id, added_products_count, checked_products_count)
-	SELECT $1, added_count, checked_count 
-	FROM counts; 
+	INSERT INTO shopping_list_statistics (shopping_list_id, shopping_list_name, added_products_count, checked_products_count)
+	SELECT $1, sl.name, added_count, checked_count 
+	FROM counts
+	JOIN shopping_lists sl ON sl.id = $1; 
 `
 	return strings.Trim(query, " ")
 }
 
+// getStatistic keeps the statistics of purged lists with the name they had
+// when the statistics were written.
 func getStatistic() string {
 	query := `
 	SELECT
 		sls.id,
-		sl.name,
+		COALESCE(sl.name, sls.shopping_list_name, ''),
 		sls.shopping_list_id,
 		sls.created_at,
 		sls.added_products_count,
 		sls.checked_products_count
 	FROM
 		shopping_list_statistics sls
-	JOIN shopping_lists sl on sl.id = sls.shopping_list_id
+	LEFT JOIN shopping_lists sl on sl.id = sls.shopping_list_id
 	WHERE
 		sls.created_at BETWEEN $1 and $2;
 `
 	return strings.Trim(query, " ")
 }
+
+func findDialogByChatId() string {
+	return `SELECT chat_id, state, data, expire_at, updated_at FROM bot_dialogs WHERE chat_id = $1`
+}
+
+func saveDialog() string {
+	query := `
+	INSERT INTO bot_dialogs (chat_id, state, data, expire_at, updated_at)
+	VALUES ($1, $2, $3, $4, now())
+	ON CONFLICT (chat_id) DO UPDATE
+	SET state = EXCLUDED.state, data = EXCLUDED.data, expire_at = EXCLUDED.expire_at, updated_at = EXCLUDED.updated_at
+`
+	return strings.Trim(query, " ")
+}
+
+func deleteDialogByChatId() string {
+	return `DELETE FROM bot_dialogs WHERE chat_id = $1`
+}
+
+func createSubmission() string {
+	query := `
+INSERT INTO product_submissions
+    (upcean, name, brand_name, category_name, account_id)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, status, created_at, updated_at
+`
+	return strings.Trim(query, " ")
+}
+
+func findSubmissionById() string {
+	query := `
+	SELECT id, upcean, name, brand_name, category_name, COALESCE(account_id, 0), status, reason,
+		COALESCE(product_id, 0), created_at, updated_at
+	FROM product_submissions
+	WHERE id = $1
+`
+	return strings.Trim(query, " ")
+}
+
+func findPendingSubmissionByIdForUpdate() string {
+	query := `
+	SELECT id, upcean, name, brand_name, category_name, COALESCE(account_id, 0), status, reason,
+		COALESCE(product_id, 0), created_at, updated_at
+	FROM product_submissions
+	WHERE id = $1 AND status = 'pending'
+	FOR UPDATE
+`
+	return strings.Trim(query, " ")
+}
+
+func getSubmissionsByStatus() string {
+	query := `
+	SELECT id, upcean, name, brand_name, category_name, COALESCE(account_id, 0), status, reason,
+		COALESCE(product_id, 0), created_at, updated_at
+	FROM product_submissions
+	WHERE status = $1
+	ORDER BY created_at
+`
+	return strings.Trim(query, " ")
+}
+
+func updatePendingSubmission() string {
+	query := `
+	UPDATE product_submissions
+	SET upcean = $1, name = $2, brand_name = $3, category_name = $4, updated_at = now()
+	WHERE id = $5 AND status = 'pending'
+	RETURNING id;
+`
+	return strings.Trim(query, " ")
+}
+
+func resolveSubmission() string {
+	query := `
+	UPDATE product_submissions
+	SET status = $1, reason = $2, product_id = NULLIF($3::BIGINT, 0), updated_at = now()
+	WHERE id = $4
+	RETURNING id;
+`
+	return strings.Trim(query, " ")
+}
+
+func getShoppingListSubscribers() string {
+	query := `
+	SELECT m.account_id FROM shopping_list_members m
+	WHERE m.shopping_list_id = $1 AND NOT EXISTS (
+		SELECT 1 FROM shopping_list_mutes mt
+		WHERE mt.shopping_list_id = m.shopping_list_id AND mt.account_id = m.account_id
+	)
+`
+	return strings.Trim(query, " ")
+}
+
+func muteShoppingList() string {
+	query := `
+	INSERT INTO shopping_list_mutes (shopping_list_id, account_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING
+`
+	return strings.Trim(query, " ")
+}
+
+func unmuteShoppingList() string {
+	return `DELETE FROM shopping_list_mutes WHERE shopping_list_id = $1 AND account_id = $2`
+}
+
+func createShoppingListMember() string {
+	query := `
+	INSERT INTO shopping_list_members (shopping_list_id, account_id, role)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING
+`
+	return strings.Trim(query, " ")
+}
+
+func getShoppingListMembers() string {
+	query := `
+	SELECT m.shopping_list_id, m.account_id, a.username, a.first_name, m.role, m.created_at
+	FROM shopping_list_members m
+	JOIN account a ON a.id = m.account_id
+	WHERE m.shopping_list_id = $1
+	ORDER BY m.role = 'owner' DESC, m.created_at
+`
+	return strings.Trim(query, " ")
+}
+
+func deleteShoppingListMember() string {
+	query := `
+	DELETE FROM shopping_list_members
+	WHERE shopping_list_id = $1 AND account_id = $2 AND role <> 'owner'
+	RETURNING account_id;
+`
+	return strings.Trim(query, " ")
+}
+
+func updateShoppingListMemberRole() string {
+	query := `
+	UPDATE shopping_list_members
+	SET role = $1
+	WHERE shopping_list_id = $2 AND account_id = $3
+	RETURNING account_id;
+`
+	return strings.Trim(query, " ")
+}
+
+func updateShoppingListOwner() string {
+	query := `
+	UPDATE shopping_lists
+	SET account_id = $1
+	WHERE id = $2
+	RETURNING id;
+`
+	return strings.Trim(query, " ")
+}
+
+func createListInvite() string {
+	query := `
+	INSERT INTO shopping_list_invites (code, shopping_list_id, role, created_by, expire_at)
+	VALUES ($1, $2, $3, $4, $5)
+`
+	return strings.Trim(query, " ")
+}
+
+func findNotExpiredListInvite() string {
+	query := `
+	SELECT code, shopping_list_id, role, created_by, expire_at
+	FROM shopping_list_invites
+	WHERE code = $1 AND expire_at > now()
+`
+	return strings.Trim(query, " ")
+}
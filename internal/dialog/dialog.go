@@ -0,0 +1,42 @@
+package dialog
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Dialog is the persisted progress of a multi-step conversation with a chat.
+type Dialog struct {
+	ChatID    int64             `json:"chat_id" db:"chat_id"`
+	State     string            `json:"state" db:"state"`
+	Data      map[string]string `json:"data" db:"data"`
+	ExpireAt  time.Time         `json:"expire_at" db:"expire_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+func (d *Dialog) Get(key string) string {
+	return d.Data[key]
+}
+
+func (d *Dialog) Set(key string, value string) {
+	if d.Data == nil {
+		d.Data = map[string]string{}
+	}
+	d.Data[key] = value
+}
+
+func (d *Dialog) Expired() bool {
+	return time.Now().After(d.ExpireAt)
+}
+
+func (d *Dialog) EncodeData() ([]byte, error) {
+	if d.Data == nil {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(d.Data)
+}
+
+func (d *Dialog) DecodeData(b []byte) error {
+	return json.Unmarshal(b, &d.Data)
+}
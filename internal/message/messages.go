@@ -1,13 +1,565 @@
 package message
 
-import "strings"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"bybarcode/internal/auth"
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/products"
+)
 
 func OnStartMessage() string {
 	msg := `
 Привет!
 Отправь мне штрихкод товара и я пришлю тебе информацию о нем.
+Количество можно указать после штрихкода: «4006381333931 x3» или «4006381333931 2 кг».
+
+Списки покупок:
+/lists - все списки
+/new <название> - создать список
+/show <список> - открыть список
+/add <список> -> <текст> - добавить товар без штрихкода, например «хлеб 2 шт»
+/rename <список> -> <название> - переименовать список
+/delete <список> - удалить список
+/restore <список> - восстановить удаленный список
+/archive <список> - убрать завершенный список в архив
+/unarchive <список> - вернуть список из архива
+/lists архив - все списки вместе с архивом
+/copy <список> -> <название> - скопировать список
+/copyrest <список> -> <название> - скопировать только некупленное
+/merge <список> -> <в список> - перенести товары в другой список и удалить этот
+/splitrest <список> -> <название> - перенести некупленное в новый список
+/mute <список> - не присылать уведомления об изменениях списка
+/unmute <список> - снова присылать уведомления
+
+Совместные списки:
+/invite <список> - пригласить в список (добавь viewer, чтобы только для просмотра)
+/members <список> - участники списка
+/leave <список> - выйти из чужого списка
+/cancel - отменить текущее действие
+
+API-ключи для скриптов:
+/keys - мои ключи
+/newkey <название> [права] [срок, например 90d] - создать ключ
+
 Чтобы открыть веб-приложение воспользуйся командой:
 /open
 `
 	return strings.Trim(msg, " ")
 }
+
+func ProductInfoMessage(p products.Product) string {
+	var category, brand string
+	if p.Category != nil {
+		category = p.Category.Name
+	}
+	if p.Brand != nil {
+		brand = p.Brand.Name
+	}
+
+	msg := `
+Штрихкод: %s
+Название: %s
+Бренд: %s
+Категория: %s
+`
+	return strings.Trim(fmt.Sprintf(msg, p.Upcean.Short(), p.Name, brand, category), "\n")
+}
+
+// MeasureMessage is appended to the product info of a variable measure code.
+func MeasureMessage(m barcode.Measured) string {
+	if m.Measure == barcode.MeasureWeight {
+		return fmt.Sprintf("\nВес: %s кг", strconv.FormatFloat(m.Value, 'f', -1, 64))
+	}
+
+	return fmt.Sprintf("\nЦена: %.2f", m.Value)
+}
+
+// QuantityMessage is appended to the product info when a quantity was typed.
+func QuantityMessage(q products.Quantity) string {
+	return fmt.Sprintf("\nКоличество: %s", FormatQuantity(q))
+}
+
+var unitNames = map[string]string{
+	products.UnitPiece:      "шт",
+	products.UnitKilogram:   "кг",
+	products.UnitGram:       "г",
+	products.UnitLitre:      "л",
+	products.UnitMillilitre: "мл",
+}
+
+func FormatQuantity(q products.Quantity) string {
+	return fmt.Sprintf("%s %s", strconv.FormatFloat(q.Amount, 'f', -1, 64), unitNames[q.Unit])
+}
+
+func ProductNotFoundMessage(code barcode.GTIN) string {
+	return fmt.Sprintf("Товар со штрихкодом %s не найден :(\nТы можешь предложить его добавить в каталог.", code.Short())
+}
+
+func InvalidBarcodeMessage() string {
+	msg := `
+Не похоже на штрихкод.
+Отправь цифры штрихкода EAN-13, EAN-8, UPC или GTIN-14 (8, 12, 13 или 14 цифр).
+`
+	return strings.Trim(msg, "\n")
+}
+
+func BarcodeNotRecognizedMessage() string {
+	msg := `
+Не удалось найти штрихкод на фото.
+Попробуй сфотографировать его ближе и ровнее или отправь цифры штрихкода текстом.
+`
+	return strings.Trim(msg, "\n")
+}
+
+func SeveralBarcodesMessage(barcodes []string) string {
+	return fmt.Sprintf(
+		"На фото несколько штрихкодов: %s.\nОтправь фото, на котором только один из них.",
+		strings.Join(barcodes, ", "),
+	)
+}
+
+func ProductAddedMessage(productInfo string, listName string) string {
+	return fmt.Sprintf("%s\n\n✅ Добавлено в список «%s»", productInfo, listName)
+}
+
+func AddItemUsageMessage() string {
+	return "Укажи список и что купить: /add <номер или название> -> <текст> [количество]"
+}
+
+// TextItemAddedMessage is sent for an item without a barcode. It is replaced
+// with the product when one with a matching name is scanned.
+func TextItemAddedMessage(name string, q products.Quantity, listName string) string {
+	if q != products.OnePiece {
+		name += ", " + FormatQuantity(q)
+	}
+
+	return fmt.Sprintf("«%s» добавлено в список «%s». Когда отсканируешь подходящий товар, он заменит эту запись.", name, listName)
+}
+
+func ProductNotAddedMessage(productInfo string, err error) string {
+	return fmt.Sprintf("%s\n\n⚠️ Не удалось добавить: %s", productInfo, err.Error())
+}
+
+func ProductInOtherUnitMessage(listName string) string {
+	return fmt.Sprintf("Товар уже есть в списке «%s» в другой единице измерения, сначала поменяй ее в списке.", listName)
+}
+
+func ShoppingListsMessage(lists []products.ShoppingList) string {
+	if len(lists) == 0 {
+		return "У тебя пока нет списков покупок.\nСоздай первый командой /new <название>"
+	}
+
+	var b strings.Builder
+	b.WriteString("Твои списки покупок:\n")
+	for _, sl := range lists {
+		name := sl.Name
+		if sl.ArchivedAt != nil {
+			name += " 📦"
+		}
+		if sl.IsOwner() {
+			b.WriteString(fmt.Sprintf("%d. %s\n", sl.ID, name))
+			continue
+		}
+		b.WriteString(fmt.Sprintf("%d. %s 👥 %s\n", sl.ID, name, listRoleName(sl.Role)))
+	}
+	b.WriteString("\nОткрыть список: /show <номер или название>")
+
+	return b.String()
+}
+
+func ShoppingListMessage(sl products.ShoppingList, items []products.ProductInList) string {
+	if len(items) == 0 {
+		return fmt.Sprintf("🛒 %s\n\nСписок пуст. Отправь штрихкод, чтобы добавить товар.", sl.Name)
+	}
+
+	checked := 0
+	for _, item := range items {
+		if item.Checked {
+			checked++
+		}
+	}
+
+	return fmt.Sprintf("🛒 %s\n\nКуплено %d из %d. Нажми на товар, чтобы отметить его.", sl.Name, checked, len(items))
+}
+
+func AskListNameMessage() string {
+	return "Как назовем новый список? Для отмены отправь /cancel"
+}
+
+func RenameListUsageMessage() string {
+	return "Укажи список и новое название: /rename <номер или название> -> <новое название>"
+}
+
+func DeleteListUsageMessage() string {
+	return "Укажи список, который нужно удалить: /delete <номер или название>"
+}
+
+func ListCreatedMessage(name string) string {
+	return fmt.Sprintf("Список «%s» создан.", name)
+}
+
+func ListRenamedMessage(oldName string, name string) string {
+	return fmt.Sprintf("Список «%s» переименован в «%s».", oldName, name)
+}
+
+func ListDeletedMessage(name string, restoreUntil time.Time) string {
+	return fmt.Sprintf(
+		"Список «%s» удален. Его можно восстановить командой /restore %s до %s.",
+		name, name, restoreUntil.Format("02.01.2006 15:04"),
+	)
+}
+
+func CopyListUsageMessage() string {
+	return "Укажи список и название копии: /copy <номер или название> -> <название копии>"
+}
+
+func ListCopiedMessage(name string, copyName string) string {
+	return fmt.Sprintf("Список «%s» скопирован в «%s».", name, copyName)
+}
+
+func MergeListsUsageMessage() string {
+	return "Укажи, какой список в какой перенести: /merge <номер или название> -> <номер или название>"
+}
+
+func SameListMessage() string {
+	return "Нужно указать два разных списка."
+}
+
+func ListsMergedMessage(source string, target string) string {
+	return fmt.Sprintf(
+		"Товары из списка «%s» перенесены в «%s», а сам список удален. Его можно восстановить командой /restore %s.",
+		source, target, source,
+	)
+}
+
+func MergeUnitConflictMessage(source string, target string, items []string) string {
+	return fmt.Sprintf(
+		"Списки «%s» и «%s» не объединены: %s в них в разных единицах измерения. Поменяй единицы, чтобы количества сложились.",
+		source, target, strings.Join(items, ", "),
+	)
+}
+
+func SplitRestUsageMessage() string {
+	return "Укажи список и название нового списка для некупленных товаров: /splitrest <номер или название> -> <название>"
+}
+
+func NothingToSplitMessage(name string) string {
+	return fmt.Sprintf("В списке «%s» все уже куплено, переносить нечего.", name)
+}
+
+func ListSplitMessage(name string, newName string, moved int) string {
+	return fmt.Sprintf("Некупленные товары из списка «%s» (%d) перенесены в новый список «%s».", name, moved, newName)
+}
+
+func RestoreListUsageMessage(deleted []products.ShoppingList) string {
+	if len(deleted) == 0 {
+		return "Нет удаленных списков, которые можно восстановить."
+	}
+
+	var b strings.Builder
+	b.WriteString("Укажи список, который нужно восстановить: /restore <номер или название>\n\nУдаленные списки:\n")
+	for _, sl := range deleted {
+		b.WriteString(fmt.Sprintf("%d. %s\n", sl.ID, sl.Name))
+	}
+
+	return b.String()
+}
+
+func ListRestoredMessage(name string) string {
+	return fmt.Sprintf("Список «%s» восстановлен.", name)
+}
+
+func ArchiveListUsageMessage() string {
+	return "Укажи список, который нужно убрать в архив: /archive <номер или название>"
+}
+
+func UnarchiveListUsageMessage() string {
+	return "Укажи список, который нужно вернуть из архива: /unarchive <номер или название>\nСписки в архиве: /lists архив"
+}
+
+func ListArchivedMessage(name string) string {
+	return fmt.Sprintf("Список «%s» убран в архив. Посмотреть архив: /lists архив", name)
+}
+
+func ListUnarchivedMessage(name string) string {
+	return fmt.Sprintf("Список «%s» возвращен из архива.", name)
+}
+
+func ListNameTakenMessage(name string) string {
+	return fmt.Sprintf("Список с названием «%s» уже существует.", name)
+}
+
+func ListChangesMessage(
+	listName string,
+	added []string,
+	removed []string,
+	checked []string,
+	unchecked []string,
+	updated []string,
+) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("🔔 Список «%s» изменился", listName))
+
+	for _, group := range []struct {
+		title string
+		names []string
+	}{
+		{"Добавлено", added},
+		{"Удалено", removed},
+		{"Куплено", checked},
+		{"Снова нужно купить", unchecked},
+		{"Изменено количество или комментарий", updated},
+	} {
+		if len(group.names) > 0 {
+			b.WriteString(fmt.Sprintf("\n%s: %s", group.title, strings.Join(group.names, ", ")))
+		}
+	}
+
+	return b.String()
+}
+
+func MuteListUsageMessage() string {
+	return "Укажи список: /mute <номер или название>"
+}
+
+func UnmuteListUsageMessage() string {
+	return "Укажи список: /unmute <номер или название>"
+}
+
+func ListMutedMessage(name string) string {
+	return fmt.Sprintf("Уведомления об изменениях списка «%s» отключены.", name)
+}
+
+func ListUnmutedMessage(name string) string {
+	return fmt.Sprintf("Уведомления об изменениях списка «%s» включены.", name)
+}
+
+func listRoleName(role string) string {
+	switch role {
+	case products.ListRoleOwner:
+		return "владелец"
+	case products.ListRoleEditor:
+		return "редактор"
+	default:
+		return "просмотр"
+	}
+}
+
+func InviteListUsageMessage() string {
+	return "Укажи список, в который нужно пригласить: /invite <номер или название> [viewer]"
+}
+
+func ListInviteMessage(name string, role string, link string) string {
+	msg := `
+Приглашение в список «%s» (%s).
+Перешли эту ссылку тем, с кем хочешь вести список вместе:
+%s
+
+Ссылка действует 7 дней.
+`
+	return strings.Trim(fmt.Sprintf(msg, name, listRoleName(role), link), "\n")
+}
+
+func InviteNotFoundMessage() string {
+	return "Приглашение не найдено или устарело. Попроси прислать новое."
+}
+
+func InviteAcceptedMessage(sl products.ShoppingList) string {
+	return fmt.Sprintf("Ты присоединился к списку «%s» (%s).\nОткрыть список: /show %d", sl.Name, listRoleName(sl.Role), sl.ID)
+}
+
+func AlreadyListMemberMessage(sl products.ShoppingList) string {
+	return fmt.Sprintf("Ты уже участник списка «%s» (%s).", sl.Name, listRoleName(sl.Role))
+}
+
+func MemberJoinedMessage(username string, listName string) string {
+	return fmt.Sprintf("%s присоединился к списку «%s».", username, listName)
+}
+
+func MembersListUsageMessage() string {
+	return "Укажи список: /members <номер или название>"
+}
+
+func ListMembersMessage(sl products.ShoppingList, members []products.ListMember) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("👥 Участники списка «%s»:\n", sl.Name))
+	for _, m := range members {
+		b.WriteString(fmt.Sprintf("%s — %s\n", MemberName(m), listRoleName(m.Role)))
+	}
+	if sl.IsOwner() && len(members) > 1 {
+		b.WriteString("\n❌ — удалить из списка, 👑 — передать список")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func MemberName(m products.ListMember) string {
+	if m.Username != "" {
+		return "@" + m.Username
+	}
+
+	return m.FirstName
+}
+
+func LeaveListUsageMessage() string {
+	return "Укажи список, из которого хочешь выйти: /leave <номер или название>"
+}
+
+func ListLeftMessage(name string) string {
+	return fmt.Sprintf("Ты вышел из списка «%s».", name)
+}
+
+func OwnerCannotLeaveMessage(name string) string {
+	return fmt.Sprintf("Ты владелец списка «%s». Передай его другому участнику в /members или удали список.", name)
+}
+
+func MemberRemovedMessage(memberName string, listName string) string {
+	return fmt.Sprintf("%s удален из списка «%s».", memberName, listName)
+}
+
+func RemovedFromListMessage(listName string) string {
+	return fmt.Sprintf("Тебя удалили из списка «%s».", listName)
+}
+
+func OwnershipTransferredMessage(memberName string, listName string) string {
+	return fmt.Sprintf("Теперь %s владелец списка «%s», а ты редактор.", memberName, listName)
+}
+
+func ListTransferredToYouMessage(listName string) string {
+	return fmt.Sprintf("Тебе передали список «%s», теперь ты его владелец.", listName)
+}
+
+func ListReadOnlyMessage(name string) string {
+	return fmt.Sprintf("Список «%s» доступен тебе только для просмотра.", name)
+}
+
+func OnlyOwnerMessage(name string) string {
+	return fmt.Sprintf("Это может сделать только владелец списка «%s».", name)
+}
+
+func DialogExpiredMessage() string {
+	return "Я не дождался ответа, поэтому предыдущее действие отменено. Начни заново :)"
+}
+
+func DialogCanceledMessage() string {
+	return "Действие отменено."
+}
+
+func NothingToCancelMessage() string {
+	return "Сейчас нечего отменять."
+}
+
+func SubmissionApprovedMessage(s products.Submission) string {
+	return fmt.Sprintf("Спасибо! Товар «%s» со штрихкодом %s добавлен в каталог.", s.Name, s.Upcean.Short())
+}
+
+func SubmissionRejectedMessage(s products.Submission) string {
+	msg := fmt.Sprintf("Товар «%s» со штрихкодом %s не прошел модерацию.", s.Name, s.Upcean.Short())
+	if s.Reason != "" {
+		msg += fmt.Sprintf("\nПричина: %s", s.Reason)
+	}
+
+	return msg
+}
+
+func AskSubmissionNameMessage() string {
+	return "Как называется товар? Для отмены отправь /cancel"
+}
+
+func AskSubmissionBrandMessage() string {
+	return "Какой у товара бренд? Отправь «-», если не знаешь."
+}
+
+func AskSubmissionCategoryMessage() string {
+	return "К какой категории относится товар? Отправь «-», если не знаешь."
+}
+
+func SubmissionCreatedMessage() string {
+	return "Спасибо! Товар отправлен на модерацию, я напишу, когда его проверят."
+}
+
+func SubmissionAlreadyReviewedMessage() string {
+	return "Эту заявку уже проверили."
+}
+
+func SubmissionAlreadyExistsMessage(code barcode.GTIN) string {
+	return fmt.Sprintf("Товар со штрихкодом %s уже ждет проверки, повторная заявка не нужна.", code.Short())
+}
+
+func NoSubmissionsMessage() string {
+	return "Новых заявок нет."
+}
+
+func SubmissionMessage(s products.Submission) string {
+	msg := `
+Заявка #%d
+Штрихкод: %s
+Название: %s
+Бренд: %s
+Категория: %s
+`
+	return strings.Trim(fmt.Sprintf(msg, s.ID, s.Upcean.Short(), s.Name, s.BrandName, s.CategoryName), "\n")
+}
+
+func ApiKeyUsageMessage() string {
+	msg := `
+Укажи название ключа: /newkey <название> [права] [срок]
+Права: catalog:read - каталог товаров, lists:read - чтение списков, lists:write - изменение списков.
+Без прав ключ получает catalog:read и lists:read. Срок задается в днях, например 90d, без него ключ бессрочный.
+`
+	return strings.Trim(msg, "\n")
+}
+
+func ApiKeyCreatedMessage(key auth.APIKey) string {
+	msg := `
+🔑 Ключ «%s» создан.
+Права: %s
+Действует: %s
+
+%s
+
+Сохрани ключ, больше я его не покажу. Передавай его в заголовке Authorization: Bearer <ключ>.
+`
+	return strings.Trim(fmt.Sprintf(msg, key.Name, strings.Join(key.Scopes, ", "), apiKeyExpiry(key), key.Key), "\n")
+}
+
+func ApiKeysMessage(keys []auth.APIKey) string {
+	if len(keys) == 0 {
+		return "У тебя нет API-ключей. Создать: /newkey <название>"
+	}
+
+	var b strings.Builder
+	b.WriteString("🔑 Твои API-ключи:\n")
+	for _, k := range keys {
+		lastUsed := "не использовался"
+		if k.LastUsedAt != nil {
+			lastUsed = "использован " + k.LastUsedAt.Format("02.01.2006 15:04")
+		}
+		b.WriteString(fmt.Sprintf(
+			"%s — %s, действует %s, %s\n",
+			k.Name,
+			strings.Join(k.Scopes, ", "),
+			apiKeyExpiry(k),
+			lastUsed,
+		))
+	}
+	b.WriteString("\n❌ — отозвать ключ")
+
+	return b.String()
+}
+
+func ApiKeyRevokedMessage(name string) string {
+	return fmt.Sprintf("Ключ «%s» отозван.", name)
+}
+
+func apiKeyExpiry(key auth.APIKey) string {
+	if key.ExpireAt == nil {
+		return "бессрочно"
+	}
+
+	return "до " + key.ExpireAt.Format("02.01.2006")
+}
@@ -0,0 +1,266 @@
+package notifier
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"bybarcode/internal/db"
+	"bybarcode/internal/message"
+)
+
+type Action string
+
+const (
+	ActionAdded     Action = "added"
+	ActionRemoved   Action = "removed"
+	ActionChecked   Action = "checked"
+	ActionUnchecked Action = "unchecked"
+	// ActionUpdated is a change of the quantity or the note.
+	ActionUpdated Action = "updated"
+)
+
+// Change is a single modification of a shopping list made by an account.
+type Change struct {
+	ListID    int64
+	AccountID int64
+	ProductID int64
+	// ItemID and ItemName are set for free-text items, which have no product.
+	ItemID   int64
+	ItemName string
+	Action   Action
+}
+
+// item identifies the list item a change is about.
+type item struct {
+	productId int64
+	itemId    int64
+}
+
+func (c Change) item() item {
+	if c.ProductID != 0 {
+		return item{productId: c.ProductID}
+	}
+
+	return item{itemId: c.ItemID}
+}
+
+type Sender interface {
+	SendMessage(chatId int64, msg string) error
+}
+
+type digest struct {
+	changes  []Change
+	first    time.Time
+	deadline time.Time
+}
+
+// Notifier collects list changes and sends one digest message per list to the
+// subscribers of the list. A digest is sent when the list has not changed for
+// the debounce interval, but not later than maxDelay after the first change.
+type Notifier struct {
+	db       *db.Connect
+	sender   Sender
+	logger   zerolog.Logger
+	debounce time.Duration
+	maxDelay time.Duration
+	changes  chan Change
+}
+
+func NewNotifier(db *db.Connect, sender Sender, logger zerolog.Logger, debounce time.Duration, maxDelay time.Duration) *Notifier {
+	return &Notifier{
+		db:       db,
+		sender:   sender,
+		logger:   logger,
+		debounce: debounce,
+		maxDelay: maxDelay,
+		changes:  make(chan Change, 100),
+	}
+}
+
+// Run collects changes until the context is done. Digests which are not sent
+// yet are dropped on exit.
+func (n *Notifier) Run(ctx context.Context) error {
+	pending := map[int64]*digest{}
+
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	for {
+		select {
+		case c := <-n.changes:
+			now := time.Now()
+
+			d, ok := pending[c.ListID]
+			if !ok {
+				d = &digest{first: now}
+				pending[c.ListID] = d
+			}
+			d.changes = append(d.changes, c)
+			d.deadline = now.Add(n.debounce)
+			if limit := d.first.Add(n.maxDelay); d.deadline.After(limit) {
+				d.deadline = limit
+			}
+		case <-timer.C:
+			now := time.Now()
+			for listId, d := range pending {
+				if !d.deadline.After(now) {
+					delete(pending, listId)
+					go n.send(ctx, listId, d.changes)
+				}
+			}
+		case <-ctx.Done():
+			return nil
+		}
+
+		resetTimer(timer, pending)
+	}
+}
+
+// Notify queues a change. It gives up after a second, like the statistic
+// listener, so a stuck notifier never blocks the API.
+func (n *Notifier) Notify(ctx context.Context, c Change) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	select {
+	case n.changes <- c:
+	case <-ctx.Done():
+		n.logger.Error().Msgf("notification about list %d was dropped", c.ListID)
+	}
+}
+
+// send notifies the subscribers of a list, a list deleted after the changes,
+// e.g. merged into another one, is still found until it is purged.
+func (n *Notifier) send(ctx context.Context, listId int64, changes []Change) {
+	sl, err := n.db.FindNotPurgedShoppingListById(ctx, listId)
+	if err != nil {
+		n.logger.Error().Msg(err.Error())
+		return
+	}
+
+	subscribers, err := n.db.GetShoppingListSubscribers(ctx, listId)
+	if err != nil {
+		n.logger.Error().Msg(err.Error())
+		return
+	}
+
+	names := map[int64]string{}
+	for _, accountId := range subscribers {
+		others := summarize(changes, accountId)
+		if len(others) == 0 {
+			continue
+		}
+
+		var added, removed, checked, unchecked, updated []string
+		for _, c := range others {
+			name, err := n.itemName(ctx, names, c)
+			if err != nil {
+				n.logger.Error().Msg(err.Error())
+				continue
+			}
+
+			switch c.Action {
+			case ActionAdded:
+				added = append(added, name)
+			case ActionRemoved:
+				removed = append(removed, name)
+			case ActionChecked:
+				checked = append(checked, name)
+			case ActionUnchecked:
+				unchecked = append(unchecked, name)
+			case ActionUpdated:
+				updated = append(updated, name)
+			}
+		}
+
+		text := message.ListChangesMessage(sl.Name, added, removed, checked, unchecked, updated)
+		if err = n.sender.SendMessage(accountId, text); err != nil {
+			n.logger.Error().Msg(err.Error())
+		}
+	}
+}
+
+func (n *Notifier) itemName(ctx context.Context, names map[int64]string, c Change) (string, error) {
+	if c.ProductID == 0 {
+		return c.ItemName, nil
+	}
+	productId := c.ProductID
+
+	if name, ok := names[productId]; ok {
+		return name, nil
+	}
+
+	p, err := n.db.FindProductById(ctx, productId)
+	if err != nil {
+		return "", err
+	}
+	names[productId] = p.Name
+
+	return p.Name, nil
+}
+
+// summarize returns the resulting change of every item made by accounts
+// other than the recipient. Products which end up as they were, e.g. added and
+// removed or checked twice within the same digest, are left out.
+func summarize(changes []Change, recipientId int64) []Change {
+	var (
+		order []item
+		first = map[item]Action{}
+		last  = map[item]Change{}
+	)
+
+	for _, c := range changes {
+		if c.AccountID == recipientId {
+			continue
+		}
+		i := c.item()
+		if _, ok := last[i]; !ok {
+			order = append(order, i)
+			first[i] = c.Action
+		}
+		last[i] = c
+	}
+
+	var result []Change
+	for _, i := range order {
+		c := last[i]
+		if first[i] == ActionAdded {
+			if c.Action == ActionRemoved {
+				continue
+			}
+			c.Action = ActionAdded
+		}
+		if isToggle(first[i]) && isToggle(c.Action) && first[i] != c.Action {
+			continue
+		}
+		result = append(result, c)
+	}
+
+	return result
+}
+
+func isToggle(a Action) bool {
+	return a == ActionChecked || a == ActionUnchecked
+}
+
+func resetTimer(timer *time.Timer, pending map[int64]*digest) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	var next time.Time
+	for _, d := range pending {
+		if next.IsZero() || d.deadline.Before(next) {
+			next = d.deadline
+		}
+	}
+
+	if !next.IsZero() {
+		timer.Reset(time.Until(next))
+	}
+}
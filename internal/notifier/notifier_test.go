@@ -0,0 +1,215 @@
+package notifier
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"bybarcode/internal/db/dbtest"
+)
+
+const (
+	listId    = 3
+	author    = 1
+	recipient = 2
+)
+
+func TestSummarize(t *testing.T) {
+	product := func(id int64, a Action) Change {
+		return Change{ListID: listId, AccountID: author, ProductID: id, Action: a}
+	}
+	text := func(id int64, name string, a Action) Change {
+		return Change{ListID: listId, AccountID: author, ItemID: id, ItemName: name, Action: a}
+	}
+
+	tests := []struct {
+		name    string
+		changes []Change
+		want    []Change
+	}{
+		{
+			name:    "single change",
+			changes: []Change{product(7, ActionAdded)},
+			want:    []Change{product(7, ActionAdded)},
+		},
+		{
+			name:    "added and removed",
+			changes: []Change{product(7, ActionAdded), product(7, ActionRemoved)},
+		},
+		{
+			name:    "added and checked is added",
+			changes: []Change{product(7, ActionAdded), product(7, ActionChecked)},
+			want:    []Change{product(7, ActionAdded)},
+		},
+		{
+			name:    "checked twice",
+			changes: []Change{product(7, ActionChecked), product(7, ActionUnchecked)},
+		},
+		{
+			name:    "checked three times",
+			changes: []Change{product(7, ActionChecked), product(7, ActionUnchecked), product(7, ActionChecked)},
+			want:    []Change{product(7, ActionChecked)},
+		},
+		{
+			name:    "updated and removed",
+			changes: []Change{product(7, ActionUpdated), product(7, ActionRemoved)},
+			want:    []Change{product(7, ActionRemoved)},
+		},
+		{
+			name:    "changes of the recipient",
+			changes: []Change{{ListID: listId, AccountID: recipient, ProductID: 7, Action: ActionAdded}},
+		},
+		{
+			name:    "text items are told apart by id",
+			changes: []Change{text(11, "хлеб", ActionAdded), text(12, "молоко", ActionAdded), text(11, "хлеб", ActionRemoved)},
+			want:    []Change{text(12, "молоко", ActionAdded)},
+		},
+		{
+			name:    "a product and a text item with the same id",
+			changes: []Change{product(7, ActionAdded), text(7, "хлеб", ActionChecked)},
+			want:    []Change{product(7, ActionAdded), text(7, "хлеб", ActionChecked)},
+		},
+		{
+			name:    "order of the first change",
+			changes: []Change{product(8, ActionChecked), product(7, ActionAdded), product(8, ActionUpdated)},
+			want:    []Change{product(8, ActionUpdated), product(7, ActionAdded)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarize(tt.changes, recipient); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("summarize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type sentMessage struct {
+	chatId int64
+	text   string
+	at     time.Time
+}
+
+type chanSender chan sentMessage
+
+func (s chanSender) SendMessage(chatId int64, msg string) error {
+	s <- sentMessage{chatId: chatId, text: msg, at: time.Now()}
+	return nil
+}
+
+// runNotifier runs a notifier of a list with the recipient subscribed to it.
+func runNotifier(t *testing.T, debounce time.Duration, maxDelay time.Duration) (*Notifier, chanSender) {
+	t.Helper()
+
+	fdb, conn := dbtest.New(t)
+	fdb.On("FROM shopping_lists WHERE id = $1", []driver.Value{int64(listId), "Продукты", int64(author)})
+	fdb.On("FROM shopping_list_members m", []driver.Value{int64(author)}, []driver.Value{int64(recipient)})
+	fdb.On("FROM products WHERE id = $1", []driver.Value{int64(7), "Молоко", "4006381333931", int64(1), int64(1)})
+
+	sender := make(chanSender, 10)
+	n := NewNotifier(&conn, sender, zerolog.Nop(), debounce, maxDelay)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = n.Run(ctx) }()
+
+	return n, sender
+}
+
+func receive(t *testing.T, sender chanSender, timeout time.Duration) sentMessage {
+	t.Helper()
+
+	select {
+	case m := <-sender:
+		return m
+	case <-time.After(timeout):
+		t.Fatal("no digest was sent")
+		return sentMessage{}
+	}
+}
+
+func TestNotifierDebounce(t *testing.T) {
+	const debounce = 100 * time.Millisecond
+	n, sender := runNotifier(t, debounce, time.Minute)
+	ctx := context.Background()
+
+	n.Notify(ctx, Change{ListID: listId, AccountID: author, ProductID: 7, Action: ActionAdded})
+	time.Sleep(debounce / 2)
+	n.Notify(ctx, Change{ListID: listId, AccountID: author, ItemID: 11, ItemName: "хлеб", Action: ActionAdded})
+	last := time.Now()
+
+	m := receive(t, sender, time.Second)
+	if m.chatId != recipient {
+		t.Errorf("digest was sent to %d, want only the recipient %d", m.chatId, recipient)
+	}
+	if sent := m.at.Sub(last); sent < debounce {
+		t.Errorf("digest was sent %s after the last change, want at least %s", sent, debounce)
+	}
+	want := "🔔 Список «Продукты» изменился\nДобавлено: Молоко, хлеб"
+	if m.text != want {
+		t.Errorf("digest = %q, want %q", m.text, want)
+	}
+
+	select {
+	case m := <-sender:
+		t.Fatalf("second message %q, want one digest", m.text)
+	case <-time.After(2 * debounce):
+	}
+}
+
+func TestNotifierMaxDelay(t *testing.T) {
+	const (
+		debounce = 100 * time.Millisecond
+		maxDelay = 250 * time.Millisecond
+	)
+	n, sender := runNotifier(t, debounce, maxDelay)
+	ctx := context.Background()
+
+	first := time.Now()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(debounce / 4)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				n.Notify(ctx, Change{ListID: listId, AccountID: author, ItemID: 11, ItemName: "хлеб", Action: ActionUpdated})
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	m := receive(t, sender, 4*maxDelay)
+	if sent := m.at.Sub(first); sent > maxDelay+debounce {
+		t.Errorf("digest was sent %s after the first change, want about %s", sent, maxDelay)
+	}
+	if !strings.Contains(m.text, "хлеб") {
+		t.Errorf("digest %q does not mention the item", m.text)
+	}
+}
+
+func TestNotifierSkipsOwnChanges(t *testing.T) {
+	const debounce = 50 * time.Millisecond
+	n, sender := runNotifier(t, debounce, time.Minute)
+
+	n.Notify(context.Background(), Change{ListID: listId, AccountID: recipient, ItemID: 11, ItemName: "хлеб", Action: ActionAdded})
+
+	m := receive(t, sender, time.Second)
+	if m.chatId != author {
+		t.Errorf("digest was sent to %d, want only the other member %d", m.chatId, author)
+	}
+	select {
+	case m := <-sender:
+		t.Fatalf("the author got a digest of own changes: %q", m.text)
+	case <-time.After(2 * debounce):
+	}
+}
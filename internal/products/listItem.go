@@ -0,0 +1,133 @@
+package products
+
+import (
+	"io"
+
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/validation"
+)
+
+// Units of a quantity, pieces have no unit.
+const (
+	UnitPiece      = ""
+	UnitKilogram   = "kg"
+	UnitGram       = "g"
+	UnitLitre      = "l"
+	UnitMillilitre = "ml"
+)
+
+var Units = []string{UnitPiece, UnitKilogram, UnitGram, UnitLitre, UnitMillilitre}
+
+// maxAmount fits the numeric(10, 3) column.
+const maxAmount = 9999999.999
+
+// Quantity is how much of a product is on a list.
+type Quantity struct {
+	Amount float64 `json:"quantity"`
+	Unit   string  `json:"unit,omitempty"`
+}
+
+// OnePiece is the quantity of a product added without a quantity.
+var OnePiece = Quantity{Amount: 1}
+
+// MeasuredQuantity is the quantity of a scanned variable measure code. The
+// price of a package says nothing about its amount, so it is one piece.
+func MeasuredQuantity(m barcode.Measured) Quantity {
+	if m.Measure == barcode.MeasureWeight {
+		return Quantity{Amount: m.Value, Unit: UnitKilogram}
+	}
+
+	return OnePiece
+}
+
+func ValidUnit(unit string) bool {
+	for _, u := range Units {
+		if unit == u {
+			return true
+		}
+	}
+
+	return false
+}
+
+func amountRule(amount float64) validation.Rule {
+	return validation.Check("quantity", amount > 0 && amount <= maxAmount, "must be a positive number up to 9999999.999")
+}
+
+func unitRule(unit string) validation.Rule {
+	return validation.Check("unit", ValidUnit(unit), "must be one of: kg, g, l, ml or empty for pieces")
+}
+
+// ListItem is what is added to a list with a product. Adding a product which
+// is on the list already adds the quantity to it.
+type ListItem struct {
+	Quantity
+	Note string `json:"note"`
+}
+
+func (i *ListItem) Decode(r io.Reader) error {
+	return validation.DecodeJSON(r, i)
+}
+
+func (i *ListItem) Validate() error {
+	return validation.Validate(
+		amountRule(i.Amount),
+		unitRule(i.Unit),
+		validation.MaxLength("note", i.Note, 255),
+	)
+}
+
+// TextItem is an item without a product, e.g. "bread from the bakery". It is
+// replaced with a product added to the list which has the same name, ignoring
+// case and extra spaces, and the same category if the item has one.
+type TextItem struct {
+	Name       string `json:"name"`
+	CategoryId int64  `json:"category_id,omitempty"`
+	ListItem
+}
+
+func (i *TextItem) Decode(r io.Reader) error {
+	return validation.DecodeJSON(r, i)
+}
+
+// Validate checks the fields a client sets. Whether the category exists is up
+// to the caller.
+func (i *TextItem) Validate() error {
+	return validation.Validate(
+		validation.Required("name", i.Name),
+		validation.MaxLength("name", i.Name, 255),
+		validation.Check("category_id", i.CategoryId >= 0, "must be a positive number"),
+		amountRule(i.Amount),
+		unitRule(i.Unit),
+		validation.MaxLength("note", i.Note, 255),
+	)
+}
+
+// ListItemUpdate sets the quantity or the note of a product on a list. Fields
+// which are left out don't change.
+type ListItemUpdate struct {
+	Amount *float64 `json:"quantity"`
+	Unit   *string  `json:"unit"`
+	Note   *string  `json:"note"`
+}
+
+func (u *ListItemUpdate) Decode(r io.Reader) error {
+	return validation.DecodeJSON(r, u)
+}
+
+func (u *ListItemUpdate) Validate() error {
+	rules := []validation.Rule{
+		validation.Check("quantity", u.Amount != nil || u.Unit != nil || u.Note != nil, "quantity, unit or note is required"),
+	}
+	if u.Amount != nil {
+		rules = append(rules, amountRule(*u.Amount))
+	}
+	if u.Unit != nil {
+		rules = append(rules, unitRule(*u.Unit))
+	}
+	if u.Note != nil {
+		rules = append(rules, validation.MaxLength("note", *u.Note, 255))
+	}
+
+	return validation.Validate(rules...)
+}
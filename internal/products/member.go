@@ -0,0 +1,88 @@
+package products
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"bybarcode/internal/validation"
+)
+
+const (
+	ListRoleOwner  = "owner"
+	ListRoleEditor = "editor"
+	ListRoleViewer = "viewer"
+)
+
+const (
+	// ListInviteTTL is how long an invitation link to a shopping list stays valid.
+	ListInviteTTL = 7 * 24 * time.Hour
+	// ListInviteStartPrefix starts the /start parameter of invitation links.
+	ListInviteStartPrefix = "invite_"
+)
+
+// ListMember is an account with access to a shopping list. Every list has
+// exactly one owner.
+type ListMember struct {
+	ShoppingListId int64     `json:"shopping_list_id"`
+	AccountId      int64     `json:"account_id"`
+	Username       string    `json:"username"`
+	FirstName      string    `json:"first_name"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ListInvite lets anyone who has the code join the list with the role until
+// the invite expires.
+type ListInvite struct {
+	Code           string    `json:"code"`
+	ShoppingListId int64     `json:"shopping_list_id"`
+	Role           string    `json:"role"`
+	CreatedBy      int64     `json:"created_by"`
+	ExpireAt       time.Time `json:"expire_at"`
+	Link           string    `json:"link,omitempty"`
+}
+
+func NewListInvite(slId int64, role string, createdBy int64) (ListInvite, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ListInvite{}, err
+	}
+
+	return ListInvite{
+		Code:           hex.EncodeToString(b),
+		ShoppingListId: slId,
+		Role:           role,
+		CreatedBy:      createdBy,
+		ExpireAt:       time.Now().Add(ListInviteTTL),
+	}, nil
+}
+
+// DeepLink returns the link which opens the bot with the invite code.
+func (i *ListInvite) DeepLink(botUsername string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%s", botUsername, ListInviteStartPrefix, i.Code)
+}
+
+func (i *ListInvite) Encode() ([]byte, error) {
+	return json.Marshal(i)
+}
+
+func (i *ListInvite) Decode(r io.Reader) error {
+	return validation.DecodeJSON(r, i)
+}
+
+// Validate allows an empty role, which means an editor invite.
+func (i *ListInvite) Validate() error {
+	return validation.Validate(
+		validation.Check("role", i.Role == "" || ValidInviteRole(i.Role), "must be one of: editor, viewer"),
+	)
+}
+
+// ValidInviteRole reports whether members can be invited with the role.
+// Ownership is only transferred, never granted by an invite.
+func ValidInviteRole(role string) bool {
+	return role == ListRoleEditor || role == ListRoleViewer
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/validation"
 )
 
 type Category struct {
@@ -17,18 +20,23 @@ type Brand struct {
 }
 
 type Product struct {
-	ID         int64     `json:"id"`
-	Name       string    `json:"name"`
-	Upcean     int64     `json:"upcean"`
-	CategoryId int64     `json:"category_id"`
-	BrandId    int64     `json:"brand_id"`
-	Category   *Category `json:"category,omitempty"`
-	Brand      *Brand    `json:"brand,omitempty"`
+	ID         int64        `json:"id"`
+	Name       string       `json:"name"`
+	Upcean     barcode.GTIN `json:"upcean"`
+	CategoryId int64        `json:"category_id"`
+	BrandId    int64        `json:"brand_id"`
+	Category   *Category    `json:"category,omitempty"`
+	Brand      *Brand       `json:"brand,omitempty"`
 }
 
+// ProductInList is an item of a shopping list. Free-text items have no
+// product, their ID is 0 and Name and CategoryId come from the item.
 type ProductInList struct {
+	ItemID int64 `json:"item_id"`
 	Product
-	Checked bool `json:"checked,omitempty"`
+	Quantity
+	Note    string `json:"note,omitempty"`
+	Checked bool   `json:"checked,omitempty"`
 }
 
 func (p *ProductInList) MarshalJSON() ([]byte, error) {
@@ -58,9 +66,17 @@ func (p *Product) Encode() ([]byte, error) {
 }
 
 func (p *Product) Decode(r io.Reader) error {
-	if err := json.NewDecoder(r).Decode(&p); err != nil {
-		return err
-	}
+	return validation.DecodeJSON(r, p)
+}
 
-	return nil
+// Validate checks the fields a client sets. Whether the category and the brand
+// exist is up to the caller.
+func (p *Product) Validate() error {
+	return validation.Validate(
+		validation.Required("name", p.Name),
+		validation.MaxLength("name", p.Name, 255),
+		validation.Barcode("upcean", p.Upcean),
+		validation.Positive("category_id", p.CategoryId),
+		validation.Positive("brand_id", p.BrandId),
+	)
 }
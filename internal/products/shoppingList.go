@@ -1,17 +1,25 @@
 package products
 
 import (
-	"bybarcode/internal/auth"
 	"encoding/json"
 	"io"
+	"time"
+
+	"bybarcode/internal/auth"
+	"bybarcode/internal/validation"
 )
 
 type ShoppingList struct {
 	ID        int64         `json:"id"`
 	Name      string        `json:"name"`
 	AccountId int64         `json:"account_id"`
+	Role      string        `json:"role,omitempty"`
 	Account   *auth.Account `json:"account,omitempty"`
 	Products  []Product     `json:"products,omitempty"`
+	// ArchivedAt is set for finished lists, which are hidden by default.
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	// DeletedAt is set for deleted lists, which can be restored for a while.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (sl *ShoppingList) Encode() ([]byte, error) {
@@ -19,9 +27,63 @@ func (sl *ShoppingList) Encode() ([]byte, error) {
 }
 
 func (sl *ShoppingList) Decode(r io.Reader) error {
-	if err := json.NewDecoder(r).Decode(&sl); err != nil {
-		return err
+	return validation.DecodeJSON(r, sl)
+}
+
+func (sl *ShoppingList) Validate() error {
+	return validation.Validate(
+		validation.Required("name", sl.Name),
+		validation.MaxLength("name", sl.Name, 255),
+	)
+}
+
+// CanEdit reports whether the items of the list may be changed with the role.
+func (sl ShoppingList) CanEdit() bool {
+	return sl.Role == ListRoleOwner || sl.Role == ListRoleEditor
+}
+
+func (sl ShoppingList) IsOwner() bool {
+	return sl.Role == ListRoleOwner
+}
+
+// ListDuplicate is a request to copy a list with its items into a new one.
+type ListDuplicate struct {
+	Name string `json:"name"`
+	// UncheckedOnly leaves out the items which were bought.
+	UncheckedOnly bool `json:"unchecked_only"`
+}
+
+func (d *ListDuplicate) Decode(r io.Reader) error {
+	return validation.DecodeJSON(r, d)
+}
+
+func (d *ListDuplicate) Validate() error {
+	return validation.Validate(
+		validation.Required("name", d.Name),
+		validation.MaxLength("name", d.Name, 255),
+	)
+}
+
+// ListSplit is a request to move items of a list to a new one.
+type ListSplit struct {
+	Name    string  `json:"name"`
+	ItemIDs []int64 `json:"item_ids"`
+}
+
+func (s *ListSplit) Decode(r io.Reader) error {
+	return validation.DecodeJSON(r, s)
+}
+
+func (s *ListSplit) Validate() error {
+	positive := true
+	for _, id := range s.ItemIDs {
+		positive = positive && id > 0
 	}
 
-	return nil
+	return validation.Validate(
+		validation.Required("name", s.Name),
+		validation.MaxLength("name", s.Name, 255),
+		validation.Check("item_ids", len(s.ItemIDs) > 0, "is required"),
+		validation.Check("item_ids", positive, "must be positive numbers"),
+	)
 }
@@ -0,0 +1,51 @@
+package products
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"bybarcode/internal/barcode"
+	"bybarcode/internal/validation"
+)
+
+const (
+	SubmissionPending  = "pending"
+	SubmissionApproved = "approved"
+	SubmissionRejected = "rejected"
+)
+
+// Submission is a product proposed by a user. It gets into the catalog only
+// after a moderator approves it.
+type Submission struct {
+	ID           int64        `json:"id"`
+	Upcean       barcode.GTIN `json:"upcean"`
+	Name         string       `json:"name"`
+	BrandName    string       `json:"brand_name"`
+	CategoryName string       `json:"category_name"`
+	AccountId    int64        `json:"account_id"`
+	Status       string       `json:"status"`
+	Reason       string       `json:"reason,omitempty"`
+	ProductId    int64        `json:"product_id,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+func (s *Submission) Encode() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+func (s *Submission) Decode(r io.Reader) error {
+	return validation.DecodeJSON(r, s)
+}
+
+func (s *Submission) Validate() error {
+	return validation.Validate(
+		validation.Barcode("upcean", s.Upcean),
+		validation.Required("name", s.Name),
+		validation.MaxLength("name", s.Name, 255),
+		validation.MaxLength("brand_name", s.BrandName, 255),
+		validation.MaxLength("category_name", s.CategoryName, 255),
+		validation.MaxLength("reason", s.Reason, 1000),
+	)
+}
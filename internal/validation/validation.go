@@ -0,0 +1,131 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"bybarcode/internal/barcode"
+)
+
+// Errors maps a field of a request to what is wrong with it.
+type Errors map[string]string
+
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for f := range e {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, 0, len(fields))
+	for _, f := range fields {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", f, e[f]))
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Rule checks a single field. It returns the message when the field is invalid.
+type Rule struct {
+	Field string
+	check func() (string, bool)
+}
+
+// Validate runs the rules and returns Errors with the first problem of every
+// field, or nil when all the rules pass.
+func Validate(rules ...Rule) error {
+	errs := Errors{}
+	for _, r := range rules {
+		if _, failed := errs[r.Field]; failed {
+			continue
+		}
+		if msg, ok := r.check(); !ok {
+			errs[r.Field] = msg
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// Check is a rule for conditions the other rules don't cover.
+func Check(field string, ok bool, msg string) Rule {
+	return Rule{Field: field, check: func() (string, bool) {
+		return msg, ok
+	}}
+}
+
+func Required(field string, value string) Rule {
+	return Check(field, strings.TrimSpace(value) != "", "is required")
+}
+
+func MaxLength(field string, value string, max int) Rule {
+	return Check(field, utf8.RuneCountInString(value) <= max, fmt.Sprintf("must be at most %d characters", max))
+}
+
+func Positive(field string, value int64) Rule {
+	return Check(field, value > 0, "must be a positive number")
+}
+
+func OneOf(field string, value string, allowed ...string) Rule {
+	for _, a := range allowed {
+		if value == a {
+			return Check(field, true, "")
+		}
+	}
+
+	return Check(field, false, fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", ")))
+}
+
+// Barcode accepts EAN-8, UPC-A, UPC-E, EAN-13 and GTIN-14 codes with a correct
+// check digit.
+func Barcode(field string, value barcode.GTIN) Rule {
+	return Check(field, value.Valid(), "must be an EAN-8, UPC-A, UPC-E, EAN-13 or GTIN-14 code with a valid check digit")
+}
+
+// DecodeJSON decodes a request body into v. Unknown fields and values of a
+// wrong type are reported as Errors, so the client learns which field is
+// wrong. An empty body is io.EOF.
+func DecodeJSON(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return Errors{typeErr.Field: fmt.Sprintf("must be %s", jsonTypeName(typeErr.Type.Kind().String()))}
+	}
+
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return Errors{strings.Trim(field, `"`): "unknown field"}
+	}
+
+	return err
+}
+
+func jsonTypeName(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case kind == "slice", kind == "array":
+		return "an array"
+	}
+
+	return "an object"
+}
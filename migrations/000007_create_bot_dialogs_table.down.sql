@@ -0,0 +1 @@
+DROP TABLE IF EXISTS bot_dialogs;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS bot_dialogs
+(
+    chat_id    BIGINT PRIMARY KEY REFERENCES account (id) ON DELETE CASCADE,
+    state      VARCHAR(100) NOT NULL,
+    data       JSONB        NOT NULL    DEFAULT '{}',
+    expire_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT (now() AT TIME ZONE 'utc'::text)
+);
\ No newline at end of file
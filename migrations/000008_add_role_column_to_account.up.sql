@@ -0,0 +1,2 @@
+ALTER TABLE account
+    ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
\ No newline at end of file
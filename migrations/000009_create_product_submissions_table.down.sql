@@ -0,0 +1 @@
+DROP TABLE IF EXISTS product_submissions;
\ No newline at end of file
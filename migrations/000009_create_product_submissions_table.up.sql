@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS product_submissions
+(
+    id            SERIAL PRIMARY KEY,
+    upcean        BIGINT       NOT NULL,
+    name          VARCHAR(255) NOT NULL,
+    brand_name    VARCHAR(255) NOT NULL,
+    category_name VARCHAR(255) NOT NULL,
+    account_id    BIGINT REFERENCES account (id) ON DELETE SET NULL,
+    status        VARCHAR(20)  NOT NULL    DEFAULT 'pending',
+    reason        TEXT         NOT NULL    DEFAULT '',
+    product_id    BIGINT REFERENCES products (id) ON DELETE SET NULL,
+    created_at    TIMESTAMP WITH TIME ZONE DEFAULT (now() AT TIME ZONE 'utc'::text),
+    updated_at    TIMESTAMP WITH TIME ZONE DEFAULT (now() AT TIME ZONE 'utc'::text)
+);
+
+CREATE INDEX IF NOT EXISTS product_submissions_status_idx ON product_submissions (status);
\ No newline at end of file
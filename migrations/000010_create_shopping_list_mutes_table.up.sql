@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS shopping_list_mutes
+(
+    shopping_list_id INT REFERENCES shopping_lists (id) ON DELETE CASCADE,
+    account_id       BIGINT REFERENCES account (id) ON DELETE CASCADE,
+    PRIMARY KEY (shopping_list_id, account_id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS shopping_list_invites;
+DROP TABLE IF EXISTS shopping_list_members;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS shopping_list_members
+(
+    shopping_list_id INT REFERENCES shopping_lists (id) ON DELETE CASCADE,
+    account_id       BIGINT REFERENCES account (id) ON DELETE CASCADE,
+    role             VARCHAR(20) NOT NULL,
+    created_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (shopping_list_id, account_id)
+);
+
+INSERT INTO shopping_list_members (shopping_list_id, account_id, role)
+SELECT id, account_id, 'owner'
+FROM shopping_lists
+WHERE account_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS shopping_list_invites
+(
+    code             VARCHAR(64) PRIMARY KEY,
+    shopping_list_id INT REFERENCES shopping_lists (id) ON DELETE CASCADE,
+    role             VARCHAR(20) NOT NULL,
+    created_by       BIGINT REFERENCES account (id) ON DELETE CASCADE,
+    expire_at        TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS sessions_family_id_idx;
+DROP INDEX IF EXISTS sessions_refresh_token_idx;
+DROP INDEX IF EXISTS sessions_token_idx;
+
+ALTER TABLE sessions
+    DROP COLUMN revoked_at,
+    DROP COLUMN rotated_at,
+    DROP COLUMN refresh_expire_at,
+    DROP COLUMN family_id;
@@ -0,0 +1,17 @@
+ALTER TABLE sessions
+    ADD COLUMN family_id         UUID,
+    ADD COLUMN refresh_expire_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN rotated_at        TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN revoked_at        TIMESTAMP WITH TIME ZONE;
+
+UPDATE sessions
+SET family_id         = id,
+    refresh_expire_at = expire_at;
+
+ALTER TABLE sessions
+    ALTER COLUMN family_id SET NOT NULL,
+    ALTER COLUMN refresh_expire_at SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_token_idx ON sessions (token);
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_refresh_token_idx ON sessions (refresh_token);
+CREATE INDEX IF NOT EXISTS sessions_family_id_idx ON sessions (family_id);
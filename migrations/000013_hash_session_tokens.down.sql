@@ -0,0 +1,14 @@
+DELETE FROM sessions;
+
+DROP INDEX IF EXISTS sessions_refresh_expire_at_idx;
+DROP INDEX IF EXISTS sessions_refresh_token_hash_idx;
+DROP INDEX IF EXISTS sessions_token_hash_idx;
+
+ALTER TABLE sessions
+    DROP COLUMN refresh_token_hash,
+    DROP COLUMN token_hash,
+    ADD COLUMN token         UUID NOT NULL,
+    ADD COLUMN refresh_token UUID NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_token_idx ON sessions (token);
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_refresh_token_idx ON sessions (refresh_token);
@@ -0,0 +1,16 @@
+-- Raw tokens can't be hashed without the server key, so existing sessions are
+-- dropped and users log in again from the web app.
+DELETE FROM sessions;
+
+DROP INDEX IF EXISTS sessions_token_idx;
+DROP INDEX IF EXISTS sessions_refresh_token_idx;
+
+ALTER TABLE sessions
+    DROP COLUMN token,
+    DROP COLUMN refresh_token,
+    ADD COLUMN token_hash         VARCHAR(64) NOT NULL,
+    ADD COLUMN refresh_token_hash VARCHAR(64) NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_token_hash_idx ON sessions (token_hash);
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_refresh_token_hash_idx ON sessions (refresh_token_hash);
+CREATE INDEX IF NOT EXISTS sessions_refresh_expire_at_idx ON sessions (refresh_expire_at);
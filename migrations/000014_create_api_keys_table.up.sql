@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id           SERIAL PRIMARY KEY,
+    account_id   BIGINT       NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    name         VARCHAR(255) NOT NULL,
+    key_hash     VARCHAR(64)  NOT NULL,
+    scopes       VARCHAR(255) NOT NULL,
+    expire_at    TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_keys_key_hash_idx ON api_keys (key_hash);
+CREATE INDEX IF NOT EXISTS api_keys_account_id_idx ON api_keys (account_id);
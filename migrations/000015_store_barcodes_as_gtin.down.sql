@@ -0,0 +1,7 @@
+ALTER TABLE product_submissions
+    DROP CONSTRAINT IF EXISTS product_submissions_upcean_gtin_check,
+    ALTER COLUMN upcean TYPE BIGINT USING upcean::bigint;
+
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_upcean_gtin_check,
+    ALTER COLUMN upcean TYPE BIGINT USING upcean::bigint;
@@ -0,0 +1,40 @@
+-- codes are padded to GTIN-14, a code that can't be one stops the migration
+-- instead of being cut or stored with a wrong check digit
+CREATE FUNCTION pg_temp.is_gtin(code TEXT) RETURNS BOOLEAN AS $$
+    SELECT CASE
+        WHEN code !~ '^[0-9]{1,14}$' THEN FALSE
+        ELSE (
+            SELECT (10 - sum(substr(lpad(code, 14, '0'), i, 1)::int * CASE WHEN i % 2 = 1 THEN 3 ELSE 1 END) % 10) % 10
+            FROM generate_series(1, 13) AS i
+        ) = right(code, 1)::int
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+DO $$
+DECLARE
+    total   BIGINT;
+    invalid TEXT;
+BEGIN
+    CREATE TEMPORARY TABLE invalid_barcodes ON COMMIT DROP AS
+    SELECT 'products' AS tbl, id, upcean FROM products WHERE NOT pg_temp.is_gtin(upcean::text)
+    UNION ALL
+    SELECT 'product_submissions', id, upcean FROM product_submissions WHERE NOT pg_temp.is_gtin(upcean::text);
+
+    SELECT count(*) INTO total FROM invalid_barcodes;
+    SELECT string_agg(format('%s.id=%s upcean=%s', tbl, id, upcean), ', ')
+    INTO invalid
+    FROM (SELECT * FROM invalid_barcodes ORDER BY tbl, id LIMIT 20) first_rows;
+
+    IF total > 0 THEN
+        RAISE EXCEPTION '% barcodes are not valid GTINs, fix or delete them before migrating: %', total, invalid;
+    END IF;
+END
+$$;
+
+ALTER TABLE products
+    ALTER COLUMN upcean TYPE VARCHAR(14) USING lpad(upcean::text, 14, '0'),
+    ADD CONSTRAINT products_upcean_gtin_check CHECK (upcean ~ '^[0-9]{14}$');
+
+ALTER TABLE product_submissions
+    ALTER COLUMN upcean TYPE VARCHAR(14) USING lpad(upcean::text, 14, '0'),
+    ADD CONSTRAINT product_submissions_upcean_gtin_check CHECK (upcean ~ '^[0-9]{14}$');
@@ -0,0 +1,3 @@
+ALTER TABLE shopping_list__products
+    DROP COLUMN IF EXISTS unit,
+    DROP COLUMN IF EXISTS quantity;
@@ -0,0 +1,3 @@
+ALTER TABLE shopping_list__products
+    ADD COLUMN IF NOT EXISTS quantity NUMERIC(10, 3) NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS unit     VARCHAR(10)    NOT NULL DEFAULT '';
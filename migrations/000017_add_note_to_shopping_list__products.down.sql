@@ -0,0 +1,2 @@
+ALTER TABLE shopping_list__products
+    DROP COLUMN IF EXISTS note;
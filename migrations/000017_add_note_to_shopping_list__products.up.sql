@@ -0,0 +1,2 @@
+ALTER TABLE shopping_list__products
+    ADD COLUMN IF NOT EXISTS note VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+DELETE FROM shopping_list__products
+WHERE product_id IS NULL;
+ALTER TABLE shopping_list__products
+    DROP CONSTRAINT IF EXISTS shopping_list__products_product_or_name,
+    DROP CONSTRAINT IF EXISTS shopping_list__products_product_key,
+    DROP COLUMN IF EXISTS category_id,
+    DROP COLUMN IF EXISTS name,
+    DROP COLUMN IF EXISTS id;
+ALTER TABLE shopping_list__products
+    ADD PRIMARY KEY (shopping_list_id, product_id);
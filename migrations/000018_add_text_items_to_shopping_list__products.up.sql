@@ -0,0 +1,9 @@
+ALTER TABLE shopping_list__products
+    DROP CONSTRAINT IF EXISTS shopping_list__products_pkey;
+ALTER TABLE shopping_list__products
+    ADD COLUMN IF NOT EXISTS id          SERIAL PRIMARY KEY,
+    ADD COLUMN IF NOT EXISTS name        VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS category_id INT REFERENCES categories (id),
+    ALTER COLUMN product_id DROP NOT NULL,
+    ADD CONSTRAINT shopping_list__products_product_key UNIQUE (shopping_list_id, product_id),
+    ADD CONSTRAINT shopping_list__products_product_or_name CHECK (product_id IS NOT NULL OR name IS NOT NULL);
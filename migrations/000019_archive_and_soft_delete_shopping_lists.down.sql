@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS shopping_lists_account_id_name_key;
+
+ALTER TABLE shopping_lists
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS archived_at,
+    ADD CONSTRAINT shopping_lists_name_key UNIQUE (name);
@@ -0,0 +1,8 @@
+ALTER TABLE shopping_lists
+    DROP CONSTRAINT IF EXISTS shopping_lists_name_key,
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS deleted_at  TIMESTAMP WITH TIME ZONE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS shopping_lists_account_id_name_key
+    ON shopping_lists (account_id, name)
+    WHERE deleted_at IS NULL;
@@ -0,0 +1,12 @@
+DELETE FROM shopping_list_statistics sls
+WHERE NOT EXISTS (SELECT 1 FROM shopping_lists sl WHERE sl.id = sls.shopping_list_id);
+
+ALTER TABLE shopping_list_statistics
+    DROP COLUMN IF EXISTS shopping_list_name,
+    ADD CONSTRAINT shopping_list_statistics_shopping_list_id_fkey
+        FOREIGN KEY (shopping_list_id) REFERENCES shopping_lists (id);
+
+ALTER TABLE shopping_list__products
+    DROP CONSTRAINT IF EXISTS shopping_list__products_shopping_list_id_fkey,
+    ADD CONSTRAINT shopping_list__products_shopping_list_id_fkey
+        FOREIGN KEY (shopping_list_id) REFERENCES shopping_lists (id);
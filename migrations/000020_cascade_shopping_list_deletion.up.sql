@@ -0,0 +1,14 @@
+ALTER TABLE shopping_list__products
+    DROP CONSTRAINT IF EXISTS shopping_list__products_shopping_list_id_fkey,
+    ADD CONSTRAINT shopping_list__products_shopping_list_id_fkey
+        FOREIGN KEY (shopping_list_id) REFERENCES shopping_lists (id) ON DELETE CASCADE;
+
+-- statistics outlive their lists, the name is kept for lists which are gone
+ALTER TABLE shopping_list_statistics
+    DROP CONSTRAINT IF EXISTS shopping_list_statistics_shopping_list_id_fkey,
+    ADD COLUMN IF NOT EXISTS shopping_list_name VARCHAR(255);
+
+UPDATE shopping_list_statistics sls
+SET shopping_list_name = sl.name
+FROM shopping_lists sl
+WHERE sl.id = sls.shopping_list_id;
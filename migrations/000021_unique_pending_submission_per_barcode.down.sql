@@ -0,0 +1 @@
+DROP INDEX IF EXISTS product_submissions_pending_upcean_key;
@@ -0,0 +1,14 @@
+-- a barcode can have one pending submission, later duplicates are rejected
+UPDATE product_submissions ps
+SET status     = 'rejected',
+    reason     = 'duplicate submission',
+    updated_at = now()
+WHERE ps.status = 'pending'
+  AND EXISTS (
+    SELECT 1 FROM product_submissions earlier
+    WHERE earlier.status = 'pending' AND earlier.upcean = ps.upcean AND earlier.id < ps.id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_submissions_pending_upcean_key
+    ON product_submissions (upcean)
+    WHERE status = 'pending';